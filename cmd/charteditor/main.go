@@ -0,0 +1,398 @@
+// Command charteditor places notes on a beat grid and saves them as .sm charts for monsters to attack with.
+// Run it from the root of the repo so it can find the assets:
+//
+//	go run ./cmd/charteditor -chart rat.sm -keys 4 -difficulty Easy
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/maxproske/lyns-rhythm-dungeon/game/chart"
+	"github.com/maxproske/lyns-rhythm-dungeon/ui2d"
+	"github.com/veandco/go-sdl2/mix"
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+const (
+	winWidth      = 480
+	winHeight     = 640
+	colWidth      = 48 // Noteskin tiles drawn at twice their size
+	noteHeight    = 40
+	pixelsPerBeat = 96
+	receptorY     = 120 // The cursor sits on the receptors, and notes after it are below
+)
+
+// Snaps to pick from, as in 1/4, 1/8 and 1/16 notes
+var divisions = []int{4, 8, 16}
+
+// Note types that can be placed, in the order Tab goes through them
+var placeable = []chart.NoteType{chart.Tap, chart.Hold, chart.Roll, chart.Mine}
+
+var typeNames = map[chart.NoteType]string{chart.Tap: "Tap", chart.Hold: "Hold", chart.Roll: "Roll", chart.Mine: "Mine"}
+
+var columnKeys = []sdl.Scancode{sdl.SCANCODE_1, sdl.SCANCODE_2, sdl.SCANCODE_3, sdl.SCANCODE_4, sdl.SCANCODE_5, sdl.SCANCODE_6, sdl.SCANCODE_7, sdl.SCANCODE_8}
+
+type editor struct {
+	renderer      *sdl.Renderer
+	font          *ttf.Font
+	noteskinAtlas *sdl.Texture
+	noteskinIndex map[rune][]sdl.Rect
+	music         *mix.Music
+	hitsound      *mix.Chunk
+
+	filename   string
+	simfile    *chart.Simfile
+	chart      *chart.Chart
+	cursor     float64 // Beat the receptors are on
+	division   int     // Index into divisions
+	typ        int     // Index into placeable
+	holdStarts map[int]float64
+	dirty      bool
+	status     string
+
+	playing   bool
+	playStart uint64 // Ticks when playback started
+	playFrom  int    // Song position playback started at
+	lastMs    int    // So each note only ticks once
+
+	keyboardState     []uint8
+	prevKeyboardState []uint8
+}
+
+func main() {
+	filename := flag.String("chart", "", "the .sm file to edit, it's made if it doesn't exist")
+	musicFile := flag.String("music", "ui2d/assets/dungeon-theme.ogg", "music to play back, for new charts")
+	bpm := flag.Float64("bpm", 120, "tempo, for new charts")
+	keys := flag.Int("keys", game.NumKeys, "number of columns")
+	difficulty := flag.String("difficulty", "Medium", "which chart in the file to edit")
+	flag.Parse()
+	if !strings.EqualFold(filepath.Ext(*filename), ".sm") || *keys < 1 || *keys > len(columnKeys) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	simfile, err := chart.Load(*filename)
+	if errors.Is(err, os.ErrNotExist) {
+		// Music paths in charts are relative to the chart
+		music, err := filepath.Rel(filepath.Dir(*filename), *musicFile)
+		if err != nil {
+			music = *musicFile
+		}
+		simfile = &chart.Simfile{Title: strings.TrimSuffix(filepath.Base(*filename), filepath.Ext(*filename)), Music: music}
+		simfile.Timing.BPMs = []chart.BPMChange{{Beat: 0, BPM: *bpm}}
+	} else if err != nil {
+		panic(err)
+	}
+	c := simfile.Find(chart.StepsType(*keys), *difficulty)
+	if c == nil {
+		c = &chart.Chart{StepsType: chart.StepsType(*keys), Difficulty: *difficulty, Meter: 1, Columns: *keys, Timing: simfile.Timing}
+		simfile.Charts = append(simfile.Charts, c)
+	}
+
+	e := newEditor(*filename, simfile, c)
+	e.run()
+}
+
+func newEditor(filename string, simfile *chart.Simfile, c *chart.Chart) *editor {
+	e := &editor{filename: filename, simfile: simfile, chart: c, holdStarts: make(map[int]float64)}
+
+	// SDL, ttf and the mixer are set up when ui2d is imported
+	window, err := sdl.CreateWindow("Chart Editor - "+filepath.Base(filename), sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, winWidth, winHeight, sdl.WINDOW_SHOWN)
+	if err != nil {
+		panic(err)
+	}
+	e.renderer, err = sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
+	if err != nil {
+		panic(err)
+	}
+	e.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	e.noteskinAtlas, e.noteskinIndex = ui2d.LoadNoteskin(e.renderer)
+	e.font, err = ttf.OpenFont("ui2d/assets/gothic.ttf", 16)
+	if err != nil {
+		panic(err)
+	}
+
+	err = mix.OpenAudio(mix.DEFAULT_FREQUENCY, mix.DEFAULT_FORMAT, 4, 512)
+	if err != nil {
+		panic(err)
+	}
+	e.music, err = mix.LoadMUS(filepath.Join(filepath.Dir(filename), simfile.Music))
+	if err != nil {
+		e.status = "No music: " + err.Error()
+	}
+	e.hitsound, err = mix.LoadWAV("ui2d/assets/hitsound.ogg")
+	if err != nil {
+		panic(err)
+	}
+
+	e.keyboardState = sdl.GetKeyboardState()
+	e.prevKeyboardState = make([]uint8, len(e.keyboardState))
+	return e
+}
+
+func (e *editor) run() {
+	for {
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			if _, ok := event.(*sdl.QuitEvent); ok {
+				return
+			}
+		}
+		if quit := e.update(); quit {
+			return
+		}
+		e.draw()
+		copy(e.prevKeyboardState, e.keyboardState)
+		sdl.Delay(10)
+	}
+}
+
+func (e *editor) keyDownOnce(key sdl.Scancode) bool {
+	return e.keyboardState[key] == 1 && e.prevKeyboardState[key] == 0
+}
+
+func (e *editor) step() float64 {
+	return 4 / float64(divisions[e.division])
+}
+
+// update handles keys, and moves the cursor along with the music while playing. Returns true to quit.
+func (e *editor) update() bool {
+	ctrl := e.keyboardState[sdl.SCANCODE_LCTRL] == 1 || e.keyboardState[sdl.SCANCODE_RCTRL] == 1
+	switch {
+	case e.keyDownOnce(sdl.SCANCODE_ESCAPE):
+		if e.dirty && !strings.HasPrefix(e.status, "Unsaved") {
+			e.status = "Unsaved changes, ESC again to quit anyway"
+			return false
+		}
+		return true
+	case e.keyDownOnce(sdl.SCANCODE_S) && ctrl:
+		e.save()
+	case e.keyDownOnce(sdl.SCANCODE_SPACE):
+		e.togglePlayback()
+	case e.playing:
+		// Everything else waits until playback stops
+	case e.keyDownOnce(sdl.SCANCODE_UP):
+		e.cursor = math.Max(0, e.cursor-e.step())
+	case e.keyDownOnce(sdl.SCANCODE_DOWN):
+		e.cursor += e.step()
+	case e.keyDownOnce(sdl.SCANCODE_PAGEUP):
+		e.cursor = math.Max(0, e.cursor-4)
+	case e.keyDownOnce(sdl.SCANCODE_PAGEDOWN):
+		e.cursor += 4
+	case e.keyDownOnce(sdl.SCANCODE_HOME):
+		e.cursor = 0
+	case e.keyDownOnce(sdl.SCANCODE_LEFT):
+		e.division = max(e.division-1, 0)
+		e.cursor = chart.Snap(e.cursor, divisions[e.division])
+	case e.keyDownOnce(sdl.SCANCODE_RIGHT):
+		e.division = min(e.division+1, len(divisions)-1)
+	case e.keyDownOnce(sdl.SCANCODE_TAB):
+		e.typ = (e.typ + 1) % len(placeable)
+		e.holdStarts = make(map[int]float64)
+	default:
+		for col := 0; col < e.chart.Columns; col++ {
+			if e.keyDownOnce(columnKeys[col]) {
+				e.place(col)
+			}
+		}
+	}
+
+	if e.playing {
+		ms := e.playFrom + int(sdl.GetTicks64()-e.playStart)
+		// Tick on every note as it goes past, like an assist clap
+		for _, n := range e.chart.Notes {
+			if n.Time > e.lastMs && n.Time <= ms && n.Typ != chart.Mine {
+				e.hitsound.Play(-1, 0)
+				break
+			}
+		}
+		e.lastMs = ms
+		e.cursor = e.chart.Timing.BeatAt(ms)
+	}
+	return false
+}
+
+// place toggles a note on a column at the cursor. Holds and rolls take two presses, one on the head and one on the end.
+func (e *editor) place(col int) {
+	typ := placeable[e.typ]
+	if typ != chart.Hold && typ != chart.Roll {
+		e.toggle(chart.Note{Column: col, Typ: typ, Beat: e.cursor})
+		return
+	}
+	start, ok := e.holdStarts[col]
+	if !ok {
+		// Pressing on an existing head removes it
+		if i := e.chart.NoteAt(col, e.cursor); i >= 0 {
+			e.toggle(e.chart.Notes[i])
+			return
+		}
+		e.holdStarts[col] = e.cursor
+		return
+	}
+	delete(e.holdStarts, col)
+	if e.cursor <= start {
+		return // Changed our mind
+	}
+	e.toggle(chart.Note{Column: col, Typ: typ, Beat: start, EndBeat: e.cursor})
+}
+
+// toggle adds or removes a note, or says why it can't go there
+func (e *editor) toggle(n chart.Note) {
+	if _, err := e.chart.Toggle(n); err != nil {
+		e.status = "Can't place that: it would overlap a hold"
+		return
+	}
+	e.dirty = true
+}
+
+func (e *editor) togglePlayback() {
+	if e.music == nil {
+		return
+	}
+	if e.playing {
+		mix.HaltMusic()
+		e.playing = false
+		e.cursor = math.Max(0, chart.Snap(e.cursor, divisions[e.division]))
+		return
+	}
+	e.playFrom = max(e.chart.Timing.TimeAt(e.cursor), 0)
+	e.lastMs = e.playFrom - 1
+	e.music.Play(1)
+	mix.SetMusicPosition(int64(e.playFrom / 1000))
+	e.playFrom = e.playFrom / 1000 * 1000 // Ogg only seeks to whole seconds
+	e.playStart = sdl.GetTicks64()
+	e.playing = true
+}
+
+func (e *editor) save() {
+	if err := e.simfile.Save(e.filename); err != nil {
+		e.status = "Couldn't save: " + err.Error()
+		return
+	}
+	e.dirty = false
+	e.status = "Saved " + e.filename
+}
+
+// beatY is where a beat is on screen
+func (e *editor) beatY(beat float64) int32 {
+	return receptorY + int32((beat-e.cursor)*pixelsPerBeat)
+}
+
+func (e *editor) offsetX() int32 {
+	return winWidth/2 - int32(e.chart.Columns)*colWidth/2
+}
+
+func (e *editor) draw() {
+	e.renderer.SetDrawColor(0, 0, 0, 255)
+	e.renderer.Clear()
+	e.drawRuler()
+
+	// Receptors mark the cursor
+	receptor := e.noteskinIndex[game.Receptor][0]
+	e.noteskinAtlas.SetColorMod(128, 128, 128)
+	for col := 0; col < e.chart.Columns; col++ {
+		e.renderer.Copy(e.noteskinAtlas, &receptor, &sdl.Rect{e.offsetX() + int32(col)*colWidth, receptorY - noteHeight/2, colWidth, noteHeight})
+	}
+	e.noteskinAtlas.SetColorMod(255, 255, 255)
+
+	for _, n := range e.chart.Notes {
+		e.drawNote(n)
+	}
+	// Hold heads waiting for their end
+	e.noteskinAtlas.SetColorMod(128, 255, 128)
+	for col, beat := range e.holdStarts {
+		e.drawNote(chart.Note{Column: col, Typ: chart.Tap, Beat: beat})
+	}
+	e.noteskinAtlas.SetColorMod(255, 255, 255)
+
+	snap := "1/" + strconv.Itoa(divisions[e.division])
+	e.drawText(fmt.Sprintf("Beat %.2f  Snap %s  Placing %s", e.cursor, snap, typeNames[placeable[e.typ]]), 8, winHeight-60)
+	e.drawText("1-"+strconv.Itoa(e.chart.Columns)+" place  TAB type  LEFT/RIGHT snap  SPACE play  CTRL+S save", 8, winHeight-40)
+	if e.status != "" {
+		e.drawText(e.status, 8, winHeight-20)
+	}
+	e.renderer.Present()
+}
+
+// Lines on every snap, brighter on beats and brightest on measures
+func (e *editor) drawRuler() {
+	step := e.step()
+	first := math.Floor((e.cursor - float64(receptorY)/pixelsPerBeat) / step)
+	last := e.cursor + float64(winHeight-receptorY)/pixelsPerBeat
+	left := e.offsetX() - 8
+	right := e.offsetX() + int32(e.chart.Columns)*colWidth + 8
+	for i := first; i*step <= last; i++ {
+		beat := i * step
+		if beat < 0 {
+			continue
+		}
+		y := e.beatY(beat)
+		switch {
+		case math.Mod(beat, 4) == 0:
+			e.renderer.SetDrawColor(255, 255, 255, 255)
+			e.drawText(strconv.Itoa(int(beat/4)+1), left-32, y-8) // Measure number
+		case math.Mod(beat, 1) == 0:
+			e.renderer.SetDrawColor(128, 128, 128, 255)
+		default:
+			e.renderer.SetDrawColor(48, 48, 48, 255)
+		}
+		e.renderer.DrawLine(left, y, right, y)
+	}
+	// Cursor
+	e.renderer.SetDrawColor(255, 255, 0, 255)
+	e.renderer.DrawLine(left, receptorY, right, receptorY)
+}
+
+func (e *editor) drawNote(n chart.Note) {
+	x := e.offsetX() + int32(n.Column)*colWidth
+	y := e.beatY(n.Beat)
+	if n.Typ == chart.Hold || n.Typ == chart.Roll {
+		body := game.HoldBody
+		if n.Typ == chart.Roll {
+			body = game.RollBody
+		}
+		bodySrc := e.noteskinIndex[body][0]
+		endY := e.beatY(n.EndBeat)
+		e.renderer.Copy(e.noteskinAtlas, &bodySrc, &sdl.Rect{x, y, colWidth, endY - y})
+		endSrc := e.noteskinIndex[game.HoldEnd][0]
+		e.renderer.Copy(e.noteskinAtlas, &endSrc, &sdl.Rect{x, endY - noteHeight/2, colWidth, noteHeight})
+	}
+	src := e.noteskinIndex[noteRune(n)][0]
+	e.renderer.Copy(e.noteskinAtlas, &src, &sdl.Rect{x, y - noteHeight/2, colWidth, noteHeight})
+}
+
+// Colour notes by where they are in the beat, so it's easy to read the rhythm
+func noteRune(n chart.Note) rune {
+	switch {
+	case n.Typ == chart.Mine:
+		return game.MineSprite
+	case math.Abs(n.Beat-math.Round(n.Beat)) < 1e-3:
+		return game.Red
+	case math.Abs(n.Beat*2-math.Round(n.Beat*2)) < 1e-3:
+		return game.Blue
+	default:
+		return game.Yellow
+	}
+}
+
+func (e *editor) drawText(s string, x, y int32) {
+	surface, err := e.font.RenderUTF8Blended(s, sdl.Color{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		panic(err)
+	}
+	defer surface.Free()
+	tex, err := e.renderer.CreateTextureFromSurface(surface)
+	if err != nil {
+		panic(err)
+	}
+	defer tex.Destroy()
+	e.renderer.Copy(tex, nil, &sdl.Rect{X: x, Y: y, W: surface.W, H: surface.H})
+}
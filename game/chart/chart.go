@@ -0,0 +1,147 @@
+// Package chart loads authored note charts, so fights can follow a song instead of a random stream.
+package chart
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NoteType is what the player has to do with a note
+type NoteType int
+
+const (
+	// Tap is pressed once
+	Tap NoteType = iota
+	// Hold is pressed and held until the end beat
+	Hold
+	// Roll is tapped repeatedly until the end beat
+	Roll
+	// Mine hurts if it is pressed
+	Mine
+	// Lift is released instead of pressed
+	Lift
+	// Fake is drawn but never judged
+	Fake
+)
+
+// Note is a single arrow in a chart
+type Note struct {
+	Column  int
+	Typ     NoteType
+	Beat    float64
+	Time    int     // Milliseconds from the start of the song
+	EndBeat float64 // Holds and rolls only
+	EndTime int
+}
+
+// BPMChange starts a new tempo at a beat
+type BPMChange struct {
+	Beat float64
+	BPM  float64
+}
+
+// Stop pauses the scroll at a beat
+type Stop struct {
+	Beat    float64
+	Seconds float64
+}
+
+// Timing converts beats to song time
+type Timing struct {
+	Offset float64 // Seconds, beat 0 is at -Offset
+	BPMs   []BPMChange
+	Stops  []Stop
+}
+
+// Chart is one difficulty of a song
+type Chart struct {
+	StepsType  string // dance-single, dance-double, ...
+	Difficulty string // Beginner, Easy, Medium, Hard, Challenge, Edit
+	Author     string
+	Meter      int
+	Columns    int
+	Notes      []Note // Sorted by time, then column
+	Timing     Timing
+}
+
+// Simfile is a song and all of its charts
+type Simfile struct {
+	Title  string
+	Artist string
+	Music  string
+	Timing Timing
+	Charts []*Chart
+}
+
+// Load reads a .sm or .ssc file
+func Load(filename string) (*Simfile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".ssc") {
+		return ParseSSC(string(data))
+	}
+	return ParseSM(string(data))
+}
+
+// Find returns the first chart with the given steps type and difficulty
+func (s *Simfile) Find(stepsType, difficulty string) *Chart {
+	for _, c := range s.Charts {
+		if strings.EqualFold(c.StepsType, stepsType) && strings.EqualFold(c.Difficulty, difficulty) {
+			return c
+		}
+	}
+	return nil
+}
+
+// TimeAt converts a beat to milliseconds from the start of the song
+func (t *Timing) TimeAt(beat float64) int {
+	seconds := -t.Offset
+	bpms := t.BPMs
+	if len(bpms) == 0 {
+		bpms = []BPMChange{{0, 60}} // Avoid dividing by zero in broken files
+	}
+
+	// Add up each tempo segment before the beat
+	for i, change := range bpms {
+		if change.Beat >= beat && i > 0 {
+			break
+		}
+		end := beat
+		if i+1 < len(bpms) && bpms[i+1].Beat < beat {
+			end = bpms[i+1].Beat
+		}
+		start := change.Beat
+		if i == 0 {
+			start = 0 // The first tempo also covers anything before it
+		}
+		seconds += (end - start) * 60 / change.BPM
+	}
+
+	// Stops before this beat push it later
+	for _, stop := range t.Stops {
+		if stop.Beat < beat {
+			seconds += stop.Seconds
+		}
+	}
+	return int(seconds*1000 + 0.5)
+}
+
+// BPMAt returns the tempo at a beat
+func (t *Timing) BPMAt(beat float64) float64 {
+	bpm := 60.0
+	for i, change := range t.BPMs {
+		if i == 0 || change.Beat <= beat {
+			bpm = change.BPM
+		}
+	}
+	return bpm
+}
+
+func (t *Timing) sort() {
+	sort.Slice(t.BPMs, func(i, j int) bool { return t.BPMs[i].Beat < t.BPMs[j].Beat })
+	sort.Slice(t.Stops, func(i, j int) bool { return t.Stops[i].Beat < t.Stops[j].Beat })
+}
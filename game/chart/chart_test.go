@@ -0,0 +1,273 @@
+package chart
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLoadSM(t *testing.T) {
+	s, err := Load("testdata/sample.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Dungeon Theme" || s.Music != "dungeon-theme.ogg" {
+		t.Errorf("Header not read, got %q %q", s.Title, s.Music)
+	}
+	if len(s.Charts) != 2 {
+		t.Fatalf("Expected 2 charts, got %d", len(s.Charts))
+	}
+	if len(s.Timing.BPMs) != 2 || s.Timing.BPMs[1].BPM != 240 {
+		t.Errorf("BPMs not read, got %v", s.Timing.BPMs)
+	}
+
+	c := s.Find("dance-single", "beginner")
+	if c == nil {
+		t.Fatal("Couldn't find beginner chart")
+	}
+	if c.Columns != 4 || c.Meter != 2 {
+		t.Errorf("Expected 4 columns meter 2, got %d columns meter %d", c.Columns, c.Meter)
+	}
+
+	// 4 taps, a hold, a mine and a jump
+	expected := []struct {
+		col  int
+		typ  NoteType
+		beat float64
+	}{
+		{0, Tap, 0}, {1, Tap, 1}, {2, Tap, 2}, {3, Tap, 3},
+		{0, Hold, 4}, {1, Mine, 6}, {0, Tap, 8}, {3, Tap, 8},
+	}
+	if len(c.Notes) != len(expected) {
+		t.Fatalf("Expected %d notes, got %d", len(expected), len(c.Notes))
+	}
+	for i, e := range expected {
+		n := c.Notes[i]
+		if n.Column != e.col || n.Typ != e.typ || n.Beat != e.beat {
+			t.Errorf("Note %d: expected %v, got %+v", i, e, n)
+		}
+	}
+	if c.Notes[4].EndBeat != 5 {
+		t.Errorf("Hold should end on beat 5, got %v", c.Notes[4].EndBeat)
+	}
+
+	if d := s.Find("dance-double", "Hard"); d == nil || d.Columns != 8 {
+		t.Error("Doubles chart should have 8 columns")
+	}
+}
+
+func TestTiming(t *testing.T) {
+	s, err := Load("testdata/sample.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := s.Charts[0]
+	tests := []struct {
+		note int
+		time int
+	}{
+		{0, 100},  // Offset pushes beat 0 later
+		{1, 600},  // 120bpm is 500ms a beat
+		{4, 2100}, // Beat 4 is the stop, notes on it aren't delayed
+		{5, 3600}, // Beat 6 is after the 0.5s stop
+		{6, 4600}, // Beat 8 switches to 240bpm
+	}
+	for _, tc := range tests {
+		if got := c.Notes[tc.note].Time; got != tc.time {
+			t.Errorf("Note %d: expected %dms, got %dms", tc.note, tc.time, got)
+		}
+	}
+	if c.Notes[4].EndTime != 3100 {
+		t.Errorf("Hold should end at 3100ms, got %d", c.Notes[4].EndTime)
+	}
+	if bpm := c.Timing.BPMAt(10); bpm != 240 {
+		t.Errorf("Expected 240bpm at beat 10, got %v", bpm)
+	}
+	if ms := c.Timing.TimeAt(10); ms != 5100 {
+		t.Errorf("Expected beat 10 at 5100ms, got %d", ms)
+	}
+}
+
+func TestLoadSSC(t *testing.T) {
+	s, err := Load("testdata/sample.ssc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	easy := s.Find("dance-single", "Easy")
+	hard := s.Find("dance-single", "Hard")
+	if easy == nil || hard == nil {
+		t.Fatal("Expected easy and hard charts")
+	}
+	if easy.Meter != 3 || hard.Meter != 8 {
+		t.Errorf("Meters not read, got %d and %d", easy.Meter, hard.Meter)
+	}
+
+	// Easy uses the song's 60bpm
+	if easy.Notes[1].Time != 1000 {
+		t.Errorf("Expected 1000ms at 60bpm, got %d", easy.Notes[1].Time)
+	}
+	roll := easy.Notes[4]
+	if roll.Typ != Roll || roll.Time != 4000 || roll.EndTime != 6000 {
+		t.Errorf("Expected a roll from 4000ms to 6000ms, got %+v", roll)
+	}
+
+	// Hard has its own 120bpm, and the song's timing is left alone
+	if hard.Notes[2].Time != 500 {
+		t.Errorf("Expected 500ms at 120bpm, got %d", hard.Notes[2].Time)
+	}
+	if s.Timing.BPMs[0].BPM != 60 {
+		t.Error("Split timing shouldn't change the song's timing")
+	}
+}
+
+func TestSSCChartFreezes(t *testing.T) {
+	s, err := ParseSSC("#BPMS:0=60;\n#NOTEDATA:;\n#STEPSTYPE:dance-single;\n#DIFFICULTY:Easy;\n#FREEZES:1=0.5;\n#NOTES:\n1000\n0000\n0100\n0000\n;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Freezes are the old name for stops, and split timing like them
+	if n := s.Charts[0].Notes[1]; n.Time != 2500 {
+		t.Errorf("Expected the chart's freeze to push the note to 2500ms, got %d", n.Time)
+	}
+	if len(s.Timing.Stops) != 0 {
+		t.Error("The chart's freezes shouldn't change the song's timing")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := []string{
+		"#TITLE:No BPMs;",
+		"#BPMS:0=120;#NOTES:dance-single:::1:",                    // Missing fields
+		"#BPMS:0=120;#NOTES:dance-single::Easy:1::1000\n01000\n;", // Uneven rows
+		"#BPMS:0=120;#NOTES:dance-single::Easy:1::3000\n;",        // Hold end without start
+		"#BPMS:0=120;#NOTES:dance-single::Easy:1::2000\n;",        // Hold never ends
+		"#BPMS:0=120;#NOTES:dance-single::Easy:1::X000\n;",        // Unknown note
+		"#BPMS:zero=120;#NOTES:dance-single::Easy:1::1000\n;",     // Bad number
+	}
+	for _, data := range bad {
+		if _, err := ParseSM(data); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestTagValuesWithHashes(t *testing.T) {
+	// A # only starts a tag at the start of a line, which also ends a tag missing its semicolon
+	s, err := ParseSM("#TITLE:Prelude in C#;\n#MUSIC:prelude.ogg\n  #BPMS:0=120;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Prelude in C#" || s.Music != "prelude.ogg" || len(s.Timing.BPMs) != 1 {
+		t.Errorf("Expected tags split at line starts, got %q %q %v", s.Title, s.Music, s.Timing.BPMs)
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	s, err := Load("testdata/sample.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := s.Charts[0]
+	// Sixteenths and triplets need finer measures
+	c.Add(Note{Column: 2, Typ: Tap, Beat: 9.25})
+	c.Add(Note{Column: 1, Typ: Roll, Beat: 12 + 1.0/3, EndBeat: 14})
+
+	filename := t.TempDir() + "/saved.sm"
+	if err := s.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Title != s.Title || loaded.Timing.Offset != s.Timing.Offset || len(loaded.Timing.Stops) != 1 || len(loaded.Charts) != 2 {
+		t.Errorf("Header not saved, got %+v", loaded)
+	}
+	saved := loaded.Charts[0]
+	if saved.Author != "maxproske" || saved.Meter != 2 || saved.Difficulty != "Beginner" {
+		t.Errorf("Chart info not saved, got %q %d %q", saved.Author, saved.Meter, saved.Difficulty)
+	}
+	if len(saved.Notes) != len(c.Notes) {
+		t.Fatalf("Expected %d notes, got %d", len(c.Notes), len(saved.Notes))
+	}
+	for i, n := range c.Notes {
+		if saved.Notes[i].Time != n.Time || saved.Notes[i].Typ != n.Typ || saved.Notes[i].EndTime != n.EndTime {
+			t.Errorf("Note %d: expected %+v, got %+v", i, n, saved.Notes[i])
+		}
+	}
+
+	if err := s.Save(t.TempDir() + "/saved.ssc"); err == nil {
+		t.Error("Expected an error saving as .ssc")
+	}
+}
+
+func TestSaveOverlaps(t *testing.T) {
+	c := &Chart{StepsType: "dance-single", Columns: 4, Timing: Timing{BPMs: []BPMChange{{0, 120}}}}
+	c.Add(Note{Column: 0, Typ: Hold, Beat: 0, EndBeat: 2})
+	c.Add(Note{Column: 1, Typ: Tap, Beat: 2}) // Same beat as the tail, but another column
+	c.Add(Note{Column: 0, Typ: Tap, Beat: 2.5})
+	s := &Simfile{Timing: c.Timing, Charts: []*Chart{c}}
+
+	filename := t.TempDir() + "/charts/overlap.sm" // Made if it isn't there
+	if err := s.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved := loaded.Charts[0].Notes; len(saved) != 3 || saved[0].EndBeat != 2 || saved[1].Column != 1 || saved[2].Beat != 2.5 {
+		t.Errorf("Expected the hold tail and both taps back, got %+v", saved)
+	}
+
+	// A tap on the tail would lose one of them, so saving refuses and leaves the old file alone
+	c.Add(Note{Column: 0, Typ: Tap, Beat: 2})
+	if err := s.Save(filename); !errors.Is(err, ErrOverlap) {
+		t.Errorf("Expected an overlap error, got %v", err)
+	}
+	if loaded, err := Load(filename); err != nil || len(loaded.Charts[0].Notes) != 3 {
+		t.Errorf("Expected the old file to be kept, got %v", err)
+	}
+}
+
+func TestEditing(t *testing.T) {
+	if beat := Snap(1.3, 4); beat != 1 {
+		t.Errorf("Expected 1.3 to snap to beat 1 on quarters, got %v", beat)
+	}
+	if beat := Snap(1.3, 16); beat != 1.25 {
+		t.Errorf("Expected 1.3 to snap to 1.25 on sixteenths, got %v", beat)
+	}
+
+	c := &Chart{Columns: 4, Timing: Timing{BPMs: []BPMChange{{0, 120}}}}
+	c.Toggle(Note{Column: 1, Beat: 2})
+	c.Toggle(Note{Column: 0, Beat: 1})
+	if len(c.Notes) != 2 || c.Notes[0].Beat != 1 || c.Notes[1].Time != 1000 {
+		t.Errorf("Expected notes in order with times, got %+v", c.Notes)
+	}
+	if added, err := c.Toggle(Note{Column: 1, Beat: 2}); added || err != nil || len(c.Notes) != 1 {
+		t.Errorf("Expected toggling a note twice to remove it, got %+v", c.Notes)
+	}
+
+	// Nothing can go on a hold, from its head to its tail
+	if _, err := c.Toggle(Note{Column: 2, Typ: Hold, Beat: 2, EndBeat: 3}); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []Note{{Column: 2, Beat: 3}, {Column: 2, Beat: 2.5}, {Column: 2, Typ: Roll, Beat: 1, EndBeat: 2.5}} {
+		if _, err := c.Toggle(n); err != ErrOverlap {
+			t.Errorf("Expected %+v to overlap the hold, got %v", n, err)
+		}
+	}
+	if added, err := c.Toggle(Note{Column: 2, Beat: 3.25}); !added || err != nil {
+		t.Errorf("Expected a note just after the hold to fit, got %v", err)
+	}
+
+	s, err := Load("testdata/sample.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, beat := range []float64{0, 3.5, 4, 6, 10} {
+		if got := s.Timing.BeatAt(s.Timing.TimeAt(beat)); math.Abs(got-beat) > 0.01 {
+			t.Errorf("Expected beat %v back, got %v", beat, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package chart
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Snap rounds a beat to the nearest 1/division note, so 4 is one a beat and 16 is four a beat
+func Snap(beat float64, division int) float64 {
+	step := 4 / float64(division)
+	return math.Round(beat/step) * step
+}
+
+// Close enough to be the same beat, beats get rounded when they're written out
+const beatEpsilon = 1e-3
+
+// NoteAt finds the note starting on a column at a beat. Returns -1 if there isn't one.
+func (c *Chart) NoteAt(column int, beat float64) int {
+	for i, n := range c.Notes {
+		if n.Column == column && math.Abs(n.Beat-beat) < beatEpsilon {
+			return i
+		}
+	}
+	return -1
+}
+
+// ErrOverlap is a note landing on a hold or roll in the same column, which a .sm row can't hold both of
+var ErrOverlap = errors.New("chart: notes overlap a hold or roll on the same column")
+
+// Toggle removes the note on a column at a beat if there is one, or adds n if there isn't.
+// Returns true if the note was added, or ErrOverlap if it can't be.
+func (c *Chart) Toggle(n Note) (bool, error) {
+	if i := c.NoteAt(n.Column, n.Beat); i >= 0 {
+		c.Notes = append(c.Notes[:i], c.Notes[i+1:]...)
+		return false, nil
+	}
+	if c.Overlaps(n) {
+		return false, ErrOverlap
+	}
+	c.Add(n)
+	return true, nil
+}
+
+// Overlaps is true if n starts, ends or lies on another note on its column, counting holds and rolls from head to tail
+func (c *Chart) Overlaps(n Note) bool {
+	for _, other := range c.Notes {
+		if other.Column != n.Column {
+			continue
+		}
+		if n.Beat <= other.end()+beatEpsilon && other.Beat <= n.end()+beatEpsilon {
+			return true
+		}
+	}
+	return false
+}
+
+// The last beat a note takes up
+func (n Note) end() float64 {
+	if n.Typ == Hold || n.Typ == Roll {
+		return n.EndBeat
+	}
+	return n.Beat
+}
+
+// Add puts a note in the chart, keeping the notes in order and working out its time
+func (c *Chart) Add(n Note) {
+	n.Time = c.Timing.TimeAt(n.Beat)
+	if n.Typ == Hold || n.Typ == Roll {
+		n.EndTime = c.Timing.TimeAt(n.EndBeat)
+	}
+	c.Notes = append(c.Notes, n)
+	sort.SliceStable(c.Notes, func(i, j int) bool {
+		if c.Notes[i].Beat == c.Notes[j].Beat {
+			return c.Notes[i].Column < c.Notes[j].Column
+		}
+		return c.Notes[i].Beat < c.Notes[j].Beat
+	})
+}
+
+// BeatAt converts milliseconds from the start of the song back to a beat
+func (t *Timing) BeatAt(ms int) float64 {
+	// TimeAt only ever goes up, so search for the beat
+	lo, hi := -1024.0, 1024.0
+	for t.TimeAt(hi) < ms {
+		hi *= 2
+	}
+	for t.TimeAt(lo) > ms {
+		lo *= 2
+	}
+	for i := 0; i < 64; i++ {
+		mid := (lo + hi) / 2
+		if t.TimeAt(mid) < ms {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
@@ -0,0 +1,281 @@
+package chart
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tag is a single #NAME:VALUE; pair
+type tag struct {
+	name  string
+	value string
+}
+
+// Split a simfile into its tags, dropping // comments
+func splitTags(data string) []tag {
+	var b strings.Builder
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	data = b.String()
+
+	tags := make([]tag, 0)
+	for {
+		start := strings.Index(data, "#")
+		if start < 0 {
+			break
+		}
+		data = data[start+1:]
+		end := strings.Index(data, ";")
+		next := nextLineTag(data)
+		// Some files forget the semicolon, so the next tag ends this one
+		if end < 0 || (next >= 0 && next < end) {
+			end = next
+		}
+		if end < 0 {
+			end = len(data)
+		}
+		body := data[:end]
+		data = data[end:]
+
+		colon := strings.Index(body, ":")
+		if colon < 0 {
+			continue
+		}
+		tags = append(tags, tag{strings.ToUpper(strings.TrimSpace(body[:colon])), strings.TrimSpace(body[colon+1:])})
+	}
+	return tags
+}
+
+// Where the next tag starts, only counting a # at the start of a line so values can have them in
+func nextLineTag(data string) int {
+	for i := 0; i < len(data); {
+		nl := strings.Index(data[i:], "\n")
+		if nl < 0 {
+			return -1
+		}
+		i += nl + 1
+		if strings.HasPrefix(strings.TrimLeft(data[i:], " \t\r"), "#") {
+			return i + strings.Index(data[i:], "#")
+		}
+	}
+	return -1
+}
+
+// ParseSM reads the older .sm format, where every chart is a single #NOTES tag
+func ParseSM(data string) (*Simfile, error) {
+	s := &Simfile{}
+	for _, t := range splitTags(data) {
+		if t.name == "NOTES" {
+			// type:author:difficulty:meter:radar:notes
+			fields := strings.SplitN(t.value, ":", 6)
+			if len(fields) != 6 {
+				return nil, fmt.Errorf("chart: #NOTES has %d fields, expected 6", len(fields))
+			}
+			c := &Chart{
+				StepsType:  strings.TrimSpace(fields[0]),
+				Author:     strings.TrimSpace(fields[1]),
+				Difficulty: strings.TrimSpace(fields[2]),
+			}
+			c.Meter, _ = strconv.Atoi(strings.TrimSpace(fields[3])) // Meter is only for display
+			if err := c.parseNotes(fields[5]); err != nil {
+				return nil, err
+			}
+			s.Charts = append(s.Charts, c)
+			continue
+		}
+		if err := s.parseHeader(t); err != nil {
+			return nil, err
+		}
+	}
+	return s.finish()
+}
+
+// ParseSSC reads the newer .ssc format, where each chart starts with #NOTEDATA and can have its own timing
+func ParseSSC(data string) (*Simfile, error) {
+	s := &Simfile{}
+	var c *Chart
+	var ownTiming bool
+	for _, t := range splitTags(data) {
+		if t.name == "NOTEDATA" {
+			c = &Chart{}
+			ownTiming = false
+			s.Charts = append(s.Charts, c)
+			continue
+		}
+		if c == nil {
+			if err := s.parseHeader(t); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var err error
+		switch t.name {
+		case "STEPSTYPE":
+			c.StepsType = t.value
+		case "DIFFICULTY":
+			c.Difficulty = t.value
+		case "CREDIT":
+			c.Author = t.value
+		case "METER":
+			c.Meter, _ = strconv.Atoi(t.value)
+		case "NOTES":
+			err = c.parseNotes(t.value)
+		case "OFFSET", "BPMS", "STOPS", "FREEZES":
+			// Split timing, this chart doesn't follow the song's timing
+			if !ownTiming {
+				c.Timing = s.Timing
+				ownTiming = true
+			}
+			err = c.Timing.parse(t)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.finish()
+}
+
+func (s *Simfile) parseHeader(t tag) error {
+	switch t.name {
+	case "TITLE":
+		s.Title = t.value
+	case "ARTIST":
+		s.Artist = t.value
+	case "MUSIC":
+		s.Music = t.value
+	default:
+		return s.Timing.parse(t)
+	}
+	return nil
+}
+
+// Fill in timing and note times once every tag has been read
+func (s *Simfile) finish() (*Simfile, error) {
+	s.Timing.sort()
+	if len(s.Timing.BPMs) == 0 {
+		return nil, fmt.Errorf("chart: missing #BPMS")
+	}
+	for _, c := range s.Charts {
+		if c.Timing.BPMs == nil {
+			c.Timing = s.Timing
+		}
+		c.Timing.sort()
+		for i := range c.Notes {
+			n := &c.Notes[i]
+			n.Time = c.Timing.TimeAt(n.Beat)
+			if n.Typ == Hold || n.Typ == Roll {
+				n.EndTime = c.Timing.TimeAt(n.EndBeat)
+			}
+		}
+	}
+	return s, nil
+}
+
+func (t *Timing) parse(tg tag) error {
+	var err error
+	switch tg.name {
+	case "OFFSET":
+		t.Offset, err = strconv.ParseFloat(tg.value, 64)
+	case "BPMS":
+		t.BPMs = nil // Don't write over the song's timing if this chart copied it
+		err = parsePairs(tg.value, func(beat, value float64) {
+			t.BPMs = append(t.BPMs, BPMChange{beat, value})
+		})
+	case "STOPS", "FREEZES":
+		t.Stops = nil
+		err = parsePairs(tg.value, func(beat, value float64) {
+			t.Stops = append(t.Stops, Stop{beat, value})
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("chart: #%s: %v", tg.name, err)
+	}
+	return nil
+}
+
+// Read "beat=value,beat=value"
+func parsePairs(value string, add func(beat, value float64)) error {
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("bad pair %q", pair)
+		}
+		beat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return err
+		}
+		add(beat, v)
+	}
+	return nil
+}
+
+// Measures are separated by commas, and each measure is 4 beats split evenly between its rows
+func (c *Chart) parseNotes(data string) error {
+	heads := make(map[int]int) // Column to index of an unfinished hold or roll
+	for measure, block := range strings.Split(data, ",") {
+		rows := strings.Fields(block)
+		for r, row := range rows {
+			if c.Columns == 0 {
+				c.Columns = len(row)
+			} else if len(row) != c.Columns {
+				return fmt.Errorf("chart: measure %d row %d has %d columns, expected %d", measure, r, len(row), c.Columns)
+			}
+			beat := float64(measure)*4 + float64(r)*4/float64(len(rows))
+			for col, ch := range row {
+				switch ch {
+				case '1':
+					c.Notes = append(c.Notes, Note{Column: col, Typ: Tap, Beat: beat})
+				case '2', '4':
+					typ := Hold
+					if ch == '4' {
+						typ = Roll
+					}
+					heads[col] = len(c.Notes)
+					c.Notes = append(c.Notes, Note{Column: col, Typ: typ, Beat: beat})
+				case '3':
+					i, ok := heads[col]
+					if !ok {
+						return fmt.Errorf("chart: measure %d row %d has a hold end without a start", measure, r)
+					}
+					c.Notes[i].EndBeat = beat
+					delete(heads, col)
+				case 'M':
+					c.Notes = append(c.Notes, Note{Column: col, Typ: Mine, Beat: beat})
+				case 'L':
+					c.Notes = append(c.Notes, Note{Column: col, Typ: Lift, Beat: beat})
+				case 'F':
+					c.Notes = append(c.Notes, Note{Column: col, Typ: Fake, Beat: beat})
+				case '0', 'K': // Empty, keysounds aren't supported
+				default:
+					return fmt.Errorf("chart: measure %d row %d has unknown note %q", measure, r, ch)
+				}
+			}
+		}
+	}
+	if len(heads) > 0 {
+		return fmt.Errorf("chart: %d holds never end", len(heads))
+	}
+	sort.SliceStable(c.Notes, func(i, j int) bool {
+		if c.Notes[i].Beat == c.Notes[j].Beat {
+			return c.Notes[i].Column < c.Notes[j].Column
+		}
+		return c.Notes[i].Beat < c.Notes[j].Beat
+	})
+	return nil
+}
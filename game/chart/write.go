@@ -0,0 +1,158 @@
+package chart
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Rows per measure that .sm files use, smallest first. Each measure gets the smallest one its notes fit on.
+var measureRows = []int{4, 8, 12, 16, 24, 32, 48, 64, 96, 192}
+
+// stepsTypes are the usual names for each column count
+var stepsTypes = map[int]string{
+	3: "dance-threepanel",
+	4: "dance-single",
+	5: "pump-single",
+	6: "dance-solo",
+	7: "kb7-single",
+	8: "dance-double",
+}
+
+// StepsType names the steps type for a column count
+func StepsType(columns int) string {
+	if t, ok := stepsTypes[columns]; ok {
+		return t
+	}
+	return "kb" + strconv.Itoa(columns) + "-single"
+}
+
+var noteChars = map[NoteType]byte{Tap: '1', Hold: '2', Roll: '4', Mine: 'M', Lift: 'L', Fake: 'F'}
+
+// Save writes a .sm file, which Load can read back, making the directory if it isn't there yet
+func (s *Simfile) Save(filename string) error {
+	if !strings.EqualFold(filepath.Ext(filename), ".sm") {
+		return fmt.Errorf("chart: can only save .sm files, not %q", filename)
+	}
+	// Write it all out first, so a chart that can't be saved doesn't wipe the old file
+	var b bytes.Buffer
+	if err := s.WriteSM(&b); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b.Bytes(), 0644)
+}
+
+// WriteSM writes the older .sm format. Charts with their own timing get the song's timing instead, since .sm can't split it.
+func (s *Simfile) WriteSM(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "#TITLE:%s;\n", s.Title)
+	fmt.Fprintf(b, "#ARTIST:%s;\n", s.Artist)
+	fmt.Fprintf(b, "#MUSIC:%s;\n", s.Music)
+	fmt.Fprintf(b, "#OFFSET:%.3f;\n", s.Timing.Offset)
+	bpms := make([]string, len(s.Timing.BPMs))
+	for i, change := range s.Timing.BPMs {
+		bpms[i] = fmt.Sprintf("%.3f=%.3f", change.Beat, change.BPM)
+	}
+	fmt.Fprintf(b, "#BPMS:%s;\n", strings.Join(bpms, ","))
+	stops := make([]string, len(s.Timing.Stops))
+	for i, stop := range s.Timing.Stops {
+		stops[i] = fmt.Sprintf("%.3f=%.3f", stop.Beat, stop.Seconds)
+	}
+	fmt.Fprintf(b, "#STOPS:%s;\n", strings.Join(stops, ","))
+
+	for _, c := range s.Charts {
+		fmt.Fprintf(b, "#NOTES:\n     %s:\n     %s:\n     %s:\n     %d:\n     0.0,0.0,0.0,0.0,0.0:\n", c.StepsType, c.Author, c.Difficulty, c.Meter)
+		measures, err := c.measures()
+		if err != nil {
+			return err
+		}
+		b.WriteString(measures)
+		b.WriteString(";\n")
+	}
+	return b.Flush()
+}
+
+// Turn notes back into measures of rows, each measure as coarse as its notes allow.
+// Two notes in the same cell, like a tap on the end of a hold, is an error instead of losing one.
+func (c *Chart) measures() (string, error) {
+	columns := c.Columns
+	if columns == 0 {
+		columns = 4
+	}
+
+	// Every beat something happens on, including the ends of holds
+	type mark struct {
+		beat   float64
+		column int
+		char   byte
+	}
+	marks := make([]mark, 0, len(c.Notes))
+	last := 0.0
+	for _, n := range c.Notes {
+		marks = append(marks, mark{n.Beat, n.Column, noteChars[n.Typ]})
+		last = math.Max(last, n.Beat)
+		if n.Typ == Hold || n.Typ == Roll {
+			marks = append(marks, mark{n.EndBeat, n.Column, '3'})
+			last = math.Max(last, n.EndBeat)
+		}
+	}
+
+	numMeasures := int(last/4) + 1
+	var out strings.Builder
+	for m := 0; m < numMeasures; m++ {
+		start := float64(m) * 4
+		inMeasure := make([]mark, 0)
+		for _, mk := range marks {
+			if mk.beat >= start && mk.beat < start+4 {
+				inMeasure = append(inMeasure, mk)
+			}
+		}
+		rows := measureRows[len(measureRows)-1]
+		for _, r := range measureRows {
+			fits := true
+			for _, mk := range inMeasure {
+				pos := (mk.beat - start) * float64(r) / 4
+				if math.Abs(pos-math.Round(pos)) > 1e-3 {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				rows = r
+				break
+			}
+		}
+
+		grid := make([][]byte, rows)
+		for i := range grid {
+			grid[i] = []byte(strings.Repeat("0", columns))
+		}
+		for _, mk := range inMeasure {
+			if mk.column >= columns {
+				continue // Doesn't fit the steps type
+			}
+			row := min(int(math.Round((mk.beat-start)*float64(rows)/4)), rows-1)
+			if grid[row][mk.column] != '0' {
+				return "", fmt.Errorf("%w, column %d at beat %.3f", ErrOverlap, mk.column, mk.beat)
+			}
+			grid[row][mk.column] = mk.char
+		}
+		if m > 0 {
+			out.WriteString(",\n")
+		}
+		for _, row := range grid {
+			out.Write(row)
+			out.WriteString("\n")
+		}
+	}
+	return out.String(), nil
+}
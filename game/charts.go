@@ -0,0 +1,53 @@
+package game
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game/chart"
+)
+
+// Monsters attack with a chart from here named after them, like game/charts/rat.sm, instead of random patterns
+const chartsDir = "game/charts"
+
+// monsterChart loads the first playable chart for a kind of monster. No file just means random patterns.
+func monsterChart(name string) (*chart.Chart, error) {
+	for _, ext := range []string{".ssc", ".sm"} {
+		filename := filepath.Join(chartsDir, strings.ToLower(name)+ext)
+		s, err := chart.Load(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range s.Charts {
+			if ValidKeyMode(c.Columns) {
+				return c, nil
+			}
+		}
+		return nil, errors.New(filename + " has no chart the game can play")
+	}
+	return nil, nil
+}
+
+// loadCharts gives every monster its chart. Monsters of a kind share one, and each keeps their own place in it.
+// A broken chart is logged and the monster goes back to random patterns.
+func (game *Game) loadCharts() {
+	charts := make(map[string]*chart.Chart)
+	for _, name := range game.levelNames() {
+		for _, m := range game.Levels[name].sortedMonsters() {
+			c, ok := charts[m.Name]
+			if !ok {
+				var err error
+				if c, err = monsterChart(m.Name); err != nil {
+					game.Log.Add(SystemMessage, "Couldn't load the "+m.Name+"'s chart: "+err.Error())
+				}
+				charts[m.Name] = c
+			}
+			m.Chart = c
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeChart(t *testing.T, name, content string) {
+	dir := filepath.Join("game", "charts")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMonstersAttackWithCharts(t *testing.T) {
+	setupTestWorld(t)
+	// Rats get a chart of one column after another, spiders still make their own patterns
+	writeChart(t, "rat.sm", "#BPMS:0=120;\n#NOTES:dance-single::Easy:1::\n1000\n0100\n0010\n0001\n;")
+	game := NewSeededGame(1, 1)
+	level := game.CurrentLevel
+	rat := level.Monsters[Pos{1, 2}]
+	spider := game.Levels["second"].Monsters[Pos{3, 1}]
+	if rat.Chart == nil || rat.Chart.Columns != 4 {
+		t.Fatal("Expected the rat to have its chart")
+	}
+	if spider.Chart != nil {
+		t.Error("Expected no chart for the spider")
+	}
+
+	level.Attack(&rat.Character, &level.Player.Character)
+	notes := rat.Burst.Notes
+	if len(notes) < 4 {
+		t.Fatalf("Expected a burst from the chart, got %v", notes)
+	}
+	for i, n := range notes[:4] {
+		if n.Column != i || n.Typ != TapNote || n.Time != i*500 {
+			t.Errorf("Expected note %d from the chart, got %+v", i, n)
+		}
+	}
+}
+
+func TestBrokenChartIsLogged(t *testing.T) {
+	setupTestWorld(t)
+	writeChart(t, "rat.sm", "#BPMS:0=120;\n#NOTES:dance-single::Easy:1::\nX000\n;")
+	game := NewSeededGame(1, 1)
+	if rat := game.CurrentLevel.Monsters[Pos{1, 2}]; rat.Chart != nil {
+		t.Error("Expected the rat to go back to random patterns")
+	}
+	if texts := messageTexts(game.CurrentLevel); len(texts) != 1 || texts[0][:30] != "Couldn't load the Rat's chart:" {
+		t.Errorf("Expected the broken chart to be logged, got %v", texts)
+	}
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Where the classes to pick from on the new game screen are written down
+const classesPath = "game/classes.json"
+
+// Class is what a run starts as: stats, a starting kit and a passive
+type Class struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Hitpoints   int      `json:"hitpoints"`
+	Stamina     int      `json:"stamina"`
+	SightRange  int      `json:"sight_range"`
+	Weapon      string   `json:"weapon,omitempty"` // Item runes, like on maps
+	Helmet      string   `json:"helmet,omitempty"`
+	Items       []string `json:"items,omitempty"`
+	Passive     Passive  `json:"passive"`
+}
+
+// Passive is how a class plays the rhythm differently. Anything left out does nothing.
+type Passive struct {
+	Name        string  `json:"name,omitempty"`
+	HealEvery   int     `json:"heal_every,omitempty"`   // Heal a hitpoint every this many notes in a row
+	JudgeScale  float64 `json:"judge_scale,omitempty"`  // Multiplies the Perfect to Good windows, under 1 is tighter
+	BonusDamage int     `json:"bonus_damage,omitempty"` // Extra damage every turn of a fight
+}
+
+// LoadClasses reads every class there is, in the order they're listed
+func LoadClasses() ([]*Class, error) {
+	data, err := os.ReadFile(classesPath)
+	if err != nil {
+		return nil, err
+	}
+	var classes []*Class
+	if err := json.Unmarshal(data, &classes); err != nil {
+		return nil, err
+	}
+	if len(classes) == 0 {
+		return nil, errors.New("no classes in " + classesPath)
+	}
+	// Make every kit now, so a typo shows up before anyone picks the class
+	for _, class := range classes {
+		if err := (&Player{}).setClass(class); err != nil {
+			return nil, fmt.Errorf("%s: %w", class.Name, err)
+		}
+	}
+	return classes, nil
+}
+
+// FindClass looks a class up by name
+func FindClass(classes []*Class, name string) *Class {
+	for _, class := range classes {
+		if class.Name == name {
+			return class
+		}
+	}
+	return nil
+}
+
+// SetClass starts the player over as a class, with its stats and kit
+func (game *Game) SetClass(class *Class) error {
+	p := game.CurrentLevel.Player
+	if err := p.setClass(class); err != nil {
+		return err
+	}
+	game.CurrentLevel.lineOfSight() // Sight might have changed
+	return nil
+}
+
+func (p *Player) setClass(class *Class) error {
+	weapon, err := classItem(class.Weapon)
+	if err != nil {
+		return err
+	}
+	helmet, err := classItem(class.Helmet)
+	if err != nil {
+		return err
+	}
+	var items []*Item
+	for _, r := range class.Items {
+		item, err := classItem(r)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+	p.Class = class.Name
+	p.Hitpoints, p.MaxHitpoints = class.Hitpoints, class.Hitpoints
+	p.Stamina, p.MaxStamina = class.Stamina, class.Stamina
+	p.SightRange = class.SightRange
+	p.Weapon, p.Helmet, p.Items = weapon, helmet, items
+	p.Passive = class.Passive
+	return nil
+}
+
+// classItem makes a starting item from its rune, or nothing for an empty string
+func classItem(s string) (*Item, error) {
+	if s == "" {
+		return nil, nil
+	}
+	newItem, ok := itemsByRune[[]rune(s)[0]]
+	if !ok {
+		return nil, errors.New("unknown item " + s + " in class")
+	}
+	return newItem(Pos{}), nil
+}
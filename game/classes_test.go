@@ -0,0 +1,109 @@
+package game
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// The real classes, from the top of the repo like the game reads them
+func loadTestClasses(t *testing.T) []*Class {
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(oldWd) })
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	classes, err := LoadClasses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return classes
+}
+
+func TestLoadClasses(t *testing.T) {
+	classes := loadTestClasses(t)
+	for _, name := range []string{"Adventurer", "Bard", "Duelist"} {
+		class := FindClass(classes, name)
+		if class == nil {
+			t.Fatalf("Expected a %s class", name)
+		}
+		p := &Player{}
+		if err := p.setClass(class); err != nil {
+			t.Errorf("Expected the %s's kit to be made, got %v", name, err)
+		}
+	}
+	if FindClass(classes, "Wizard") != nil {
+		t.Error("Expected no class that isn't written down")
+	}
+}
+
+func TestLoadClassesWithUnknownItem(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("game", 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := `[{"name": "Adventurer", "hitpoints": 10}, {"name": "Tinker", "hitpoints": 10, "items": ["+", "?"]}]`
+	if err := os.WriteFile(classesPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadClasses(); err == nil || !strings.Contains(err.Error(), "Tinker") {
+		t.Errorf("Expected an error naming the class with the unknown item, got %v", err)
+	}
+}
+
+func TestSetClass(t *testing.T) {
+	game := createTestGame()
+	class := &Class{Name: "Tester", Hitpoints: 9, Stamina: 3, SightRange: 5, Weapon: "s", Items: []string{"+", "$"}, Passive: Passive{BonusDamage: 2}}
+	if err := game.SetClass(class); err != nil {
+		t.Fatal(err)
+	}
+	p := game.CurrentLevel.Player
+	if p.Class != "Tester" || p.Hitpoints != 9 || p.MaxHitpoints != 9 || p.MaxStamina != 3 || p.SightRange != 5 {
+		t.Errorf("Expected the class's stats, got %+v", p.Character)
+	}
+	if p.Weapon == nil || p.Weapon.Name != "Sword" || p.Helmet != nil || len(p.Items) != 2 || p.Items[0].Name != "Health Potion" {
+		t.Errorf("Expected the class's kit, got %v %v %v", p.Weapon, p.Helmet, p.Items)
+	}
+	if err := game.SetClass(&Class{Weapon: "?"}); err == nil {
+		t.Error("Expected an error for an item that doesn't exist")
+	}
+}
+
+func TestPassives(t *testing.T) {
+	// Tighter windows turn a Great into a Good, but Boo is still a Boo
+	duelist := &Character{Passive: Passive{JudgeScale: 0.75}}
+	if j := duelist.Judge(80); j != Good {
+		t.Errorf("Expected a tighter Great window, got %v", j)
+	}
+	if j := duelist.Judge(-170); j != Boo {
+		t.Errorf("Expected Boo to stay the same, got %v", j)
+	}
+	if j := (&Character{}).Judge(80); j != Great {
+		t.Errorf("Expected normal windows without a passive, got %v", j)
+	}
+
+	// Bonus damage on every turn
+	level := createTestLevel()
+	rat := NewRat(Pos{7, 6})
+	level.Monsters[rat.Pos] = rat
+	p := &level.Player.Character
+	p.Passive.BonusDamage = 1
+	level.Battle = &Battle{C1: p, C2: &rat.Character, Active: true}
+	level.ResolveDamage()
+	if rat.Hitpoints != 2 {
+		t.Errorf("Expected 2 damage, got %d", 4-rat.Hitpoints)
+	}
+
+	// A streak of 4 heals once
+	bard := &Character{Hitpoints: 5, MaxHitpoints: 10, Stamina: 10, Passive: Passive{HealEvery: 4}}
+	bard.Burst = &Burst{Notes: make([]Note, 8)}
+	for i := 0; i < 5; i++ {
+		bard.Burst.hit(bard, Perfect, 0)
+	}
+	if bard.Hitpoints != 6 {
+		t.Errorf("Expected a heal every 4 notes, got %d hitpoints", bard.Hitpoints)
+	}
+}
@@ -0,0 +1,119 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the position in the song in milliseconds, so battles can be judged against the music.
+// The UI that plays the music provides one, tests use a FakeClock.
+type Clock interface {
+	Now() int
+}
+
+// Tempo is the beat grid of the music, so bursts can start on a beat
+type Tempo struct {
+	BPM    float64
+	Offset int // Song position of a beat, the grid runs both ways from here
+}
+
+// TempoClock is a clock that knows the tempo of the music it follows.
+// Clocks that don't follow any music get a battleBPM grid from the start.
+type TempoClock interface {
+	Clock
+	Tempo() Tempo
+}
+
+// clockTempo is the tempo of the music a clock follows
+func clockTempo(clock Clock) Tempo {
+	if c, ok := clock.(TempoClock); ok && c.Tempo().BPM > 0 {
+		return c.Tempo()
+	}
+	return Tempo{BPM: battleBPM}
+}
+
+// WallClock counts from when it was made, for when there's no music playing
+type WallClock struct {
+	start time.Time
+}
+
+// NewWallClock starts counting from now
+func NewWallClock() *WallClock {
+	return &WallClock{start: time.Now()}
+}
+
+// Now ...
+func (c *WallClock) Now() int {
+	return int(time.Since(c.start).Milliseconds())
+}
+
+// FakeClock only moves when told to
+type FakeClock struct {
+	mu  sync.Mutex // Autoplay reads the clock from its own goroutine
+	now int
+}
+
+// Now ...
+func (c *FakeClock) Now() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set jumps to a song position
+func (c *FakeClock) Set(ms int) {
+	c.mu.Lock()
+	c.now = ms
+	c.mu.Unlock()
+}
+
+// Advance moves the song forward
+func (c *FakeClock) Advance(ms int) {
+	c.mu.Lock()
+	c.now += ms
+	c.mu.Unlock()
+}
+
+// offsetClock applies the global offset to the song position
+type offsetClock struct {
+	clock    Clock
+	settings *Settings
+}
+
+func (c *offsetClock) Now() int {
+	return c.clock.Now() - c.settings.GlobalOffset
+}
+
+// Tempo passes the music's tempo through, beats are heard at the same song position the notes are judged at
+func (c *offsetClock) Tempo() Tempo {
+	return clockTempo(c.clock)
+}
+
+// Used by levels that haven't been given a clock
+var systemClock = NewWallClock()
+
+// SongPosition is where we are in the music, with the global offset applied
+func (level *Level) SongPosition() int {
+	if level.Clock == nil {
+		return systemClock.Now()
+	}
+	return level.Clock.Now()
+}
+
+// SetClock syncs every level to the music, and applies the global offset from the settings
+func (game *Game) SetClock(clock Clock) {
+	for _, level := range game.Levels {
+		level.Clock = &offsetClock{clock: clock, settings: game.Settings}
+	}
+}
+
+// Wait until a song position, checking often enough that notes stay on time
+func (level *Level) waitUntil(ms int) {
+	for {
+		left := ms - level.SongPosition()
+		if left <= 0 {
+			return
+		}
+		time.Sleep(time.Millisecond * time.Duration(min(left, 5)))
+	}
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJudge(t *testing.T) {
+	tests := []struct {
+		offset   int
+		expected Judgement
+	}{
+		{0, Perfect},
+		{-45, Perfect},
+		{60, Great},
+		{-100, Good},
+		{180, Boo},
+		{-181, Miss},
+		{1000, Miss},
+	}
+	for _, tc := range tests {
+		if j := Judge(tc.offset); j != tc.expected {
+			t.Errorf("Judge(%d) expected %v, got %v", tc.offset, tc.expected, j)
+		}
+	}
+}
+
+func TestBurstStartsOnBeat(t *testing.T) {
+	tests := []struct {
+		tempo Tempo
+		beat  int
+	}{
+		{Tempo{BPM: battleBPM}, 500},
+		{Tempo{BPM: 150, Offset: 130}, 400},
+		{Tempo{BPM: 150, Offset: 90130}, 400}, // A later loop of the same song
+	}
+	for _, tc := range tests {
+		for _, now := range []int{0, 1, 499, 12345} {
+			start := burstStart(now, tc.tempo)
+			if (start-tc.tempo.Offset)%tc.beat != 0 || start < now+battleLeadIn || start >= now+battleLeadIn+tc.beat {
+				t.Errorf("Expected burst at %d to start on the first %v beat after the lead in, got %d", now, tc.tempo, start)
+			}
+		}
+	}
+}
+
+// musicClock is a FakeClock playing a song at a known tempo
+type musicClock struct {
+	FakeClock
+	tempo Tempo
+}
+
+func (c *musicClock) Tempo() Tempo {
+	return c.tempo
+}
+
+func TestBurstsFollowTheMusic(t *testing.T) {
+	game := createTestGame()
+	game.Settings = &Settings{GlobalOffset: 30}
+	clock := &musicClock{tempo: Tempo{BPM: 100, Offset: 250}}
+	game.SetClock(clock)
+	level := game.CurrentLevel
+	clock.Set(5000)
+
+	rat := NewRat(Pos{6, 7})
+	level.Monsters[rat.Pos] = rat
+	level.Attack(&rat.Character, &level.Player.Character)
+	// 4970 plus the lead in is 5970, and the beats are at 250, 850, ... 5650, 6250
+	if rat.Burst.Start != 6250 {
+		t.Errorf("Expected the burst to start on the music's beat at 6250, got %d", rat.Burst.Start)
+	}
+}
+
+func TestGlobalOffset(t *testing.T) {
+	game := createTestGame()
+	game.Settings = &Settings{GlobalOffset: 30}
+	clock := &FakeClock{}
+	game.SetClock(clock)
+	level := game.CurrentLevel
+	clock.Set(1000)
+	if pos := level.SongPosition(); pos != 970 {
+		t.Errorf("Expected song position 970 with a 30ms offset, got %d", pos)
+	}
+	// Changing the setting applies straight away
+	game.Settings.GlobalOffset = -20
+	if pos := level.SongPosition(); pos != 1020 {
+		t.Errorf("Expected song position 1020 with a -20ms offset, got %d", pos)
+	}
+}
+
+func TestTimedPress(t *testing.T) {
+	level := createTestLevel()
+	clock := &FakeClock{}
+	level.Clock = clock
+	c := &level.Player.Character
+	c.Stamina = 10
+	c.Burst = &Burst{Notes: []Note{{Column: 0, Time: 0}, {Column: 1, Time: 500}, {Column: 2, Time: 1000}}, MaxCombo: 3, Start: 2000}
+
+	// Way too early does nothing
+	if j := level.Press(c, 0, 1500); j != NoJudgement || len(c.Burst.Notes) != 3 {
+		t.Errorf("Expected early press to be ignored, got %v", j)
+	}
+	if j := level.Press(c, 0, 2070); j != Great || c.Burst.LastOffset != 70 {
+		t.Errorf("Expected Great 70ms late, got %v %dms", j, c.Burst.LastOffset)
+	}
+	// The second note goes by without being pressed
+	if missed := level.CheckMisses(c, 2700); missed != 1 {
+		t.Errorf("Expected 1 miss, got %d", missed)
+	}
+	if j := level.Press(c, 2, 2990); j != Perfect {
+		t.Errorf("Expected Perfect, got %v", j)
+	}
+	b := c.Burst
+	if !b.Done() || b.Judgements[Great] != 1 || b.Judgements[Miss] != 1 || b.Judgements[Perfect] != 1 || c.Stamina != 7 {
+		t.Errorf("Unexpected judgements %v with %d stamina", b.Judgements, c.Stamina)
+	}
+	if b.Streak != 1 || b.LastJudgement != Perfect || b.LastJudgedAt != 2990 {
+		t.Errorf("Expected a streak of 1 after the miss, got %d (last %v at %d)", b.Streak, b.LastJudgement, b.LastJudgedAt)
+	}
+}
+
+func TestAutoplayFollowsClock(t *testing.T) {
+	level := createTestLevel()
+	clock := &FakeClock{}
+	level.Clock = clock
+	level.Player.Hitpoints = 3 // Burst length
+	rat := NewRat(Pos{6, 7})
+	level.Monsters[rat.Pos] = rat
+	level.Attack(&rat.Character, &level.Player.Character)
+
+	done := make(chan bool)
+	go func() {
+		rat.Autoplay(level)
+		done <- true
+	}()
+
+	// Nothing happens until the music gets there
+	time.Sleep(20 * time.Millisecond)
+	if len(rat.Burst.Notes) != 3 {
+		t.Fatal("Monster played before the music reached the notes")
+	}
+	for {
+		select {
+		case <-done:
+			if !rat.Burst.Done() || rat.Burst.Judgements[Miss] != 0 {
+				t.Errorf("Expected every note to be hit, got %v", rat.Burst.Judgements)
+			}
+			return
+		default:
+			clock.Advance(10)
+			time.Sleep(200 * time.Microsecond)
+		}
+	}
+}
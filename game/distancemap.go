@@ -0,0 +1,106 @@
+package game
+
+import "github.com/maxproske/lyns-rhythm-dungeon/game/path"
+
+// unreachable marks tiles that can't be reached from the origin
+const unreachable = path.Unreachable
+
+// fleeFactor makes fleeing monsters prefer getting far away over hiding in the nearest corner
+const fleeFactor = -1.2
+
+// DistanceMap (a.k.a. Dijkstra map or flow field) stores the cost of reaching the origin from every tile.
+// Monsters step downhill on it to chase, instead of each running their own a*.
+type DistanceMap struct {
+	Origin Pos
+	Dist   [][]int
+}
+
+// Fill a map with the cheapest cost from each tile to the origin
+func (level *Level) newDistanceMap(origin Pos) *DistanceMap {
+	dm := &DistanceMap{Origin: origin, Dist: newDistGrid(level)}
+	dm.Dist[origin.Y][origin.X] = 0
+	level.relax(dm, []Pos{origin})
+	return dm
+}
+
+// Flee inverts a distance map, so going downhill moves away from the origin.
+// The inverted values are rescanned so monsters run past the player instead of into dead ends.
+func (dm *DistanceMap) Flee(level *Level) *DistanceMap {
+	flee := &DistanceMap{Origin: dm.Origin, Dist: newDistGrid(level)}
+	seeds := make([]Pos, 0)
+	for y, row := range dm.Dist {
+		for x, d := range row {
+			if d != unreachable {
+				flee.Dist[y][x] = int(float64(d) * fleeFactor)
+				seeds = append(seeds, Pos{x, y})
+			}
+		}
+	}
+	level.relax(flee, seeds)
+	return flee
+}
+
+// Downhill returns the cheapest free neighbour, or false if there's nowhere better to go
+func (dm *DistanceMap) Downhill(level *Level, from Pos) (Pos, bool) {
+	best := from
+	bestDist := dm.Dist[from.Y][from.X]
+	for _, p := range (&moverGraph{level: level}).Neighbors(path.Pos(from)) {
+		next := Pos(p)
+		// Don't queue behind another monster
+		if _, exists := level.Monsters[next]; exists {
+			continue
+		}
+		if d := dm.Dist[next.Y][next.X]; d < bestDist {
+			best = next
+			bestDist = d
+		}
+	}
+	return best, best != from
+}
+
+func newDistGrid(level *Level) [][]int {
+	dist := make([][]int, len(level.Map))
+	for y, row := range level.Map {
+		dist[y] = make([]int, len(row))
+		for x := range row {
+			dist[y][x] = unreachable
+		}
+	}
+	return dist
+}
+
+// Dijkstra outwards from the seeds
+func (level *Level) relax(dm *DistanceMap, seeds []Pos) {
+	pathSeeds := make([]path.Pos, len(seeds))
+	for i, seed := range seeds {
+		pathSeeds[i] = path.Pos(seed)
+	}
+	path.Dijkstra(&terrainGraph{moverGraph{level: level}}, dm.Dist, pathSeeds)
+}
+
+// Recalculate the map monsters use to find the player, if the player moved or the terrain changed since last time
+func (level *Level) updateDistanceMaps() {
+	target, ok := level.target()
+	if !ok {
+		return // Nobody to find
+	}
+	if level.PlayerMap != nil && level.PlayerMap.Origin == target {
+		return // Still good, like every tick of catching up after the first
+	}
+	level.PlayerMap = level.newDistanceMap(target)
+	level.FleeMap = nil // Made when someone first runs away
+}
+
+// fleeMap is the inverted player map, only worked out once a monster needs to run
+func (level *Level) fleeMap() *DistanceMap {
+	if level.FleeMap == nil {
+		level.FleeMap = level.PlayerMap.Flee(level)
+	}
+	return level.FleeMap
+}
+
+// terrainChanged throws the maps away after a door opens or a trap springs, since the costs are different now
+func (level *Level) terrainChanged() {
+	level.PlayerMap = nil
+	level.FleeMap = nil
+}
@@ -0,0 +1,181 @@
+package game
+
+import "testing"
+
+func TestDistanceMap(t *testing.T) {
+	level := createTestLevel()
+	level.Map[7][5].Rune = StoneWall
+	dm := level.newDistanceMap(level.Player.Pos)
+
+	if dm.Dist[7][7] != 0 {
+		t.Errorf("Origin should be 0, got %d", dm.Dist[7][7])
+	}
+	if dm.Dist[7][8] != costStep {
+		t.Errorf("Adjacent tile should be %d, got %d", costStep, dm.Dist[7][8])
+	}
+	if dm.Dist[7][5] != unreachable {
+		t.Error("Walls should be unreachable")
+	}
+
+	// Every tile should match a* from that tile
+	for _, start := range []Pos{{0, 0}, {14, 3}, {4, 7}, {3, 12}} {
+		path := level.findPath(start, level.Player.Pos, nil)
+		if dm.Dist[start.Y][start.X] != path.Cost {
+			t.Errorf("Distance from %v is %d, a* says %d", start, dm.Dist[start.Y][start.X], path.Cost)
+		}
+	}
+}
+
+func TestDistanceMapDownhill(t *testing.T) {
+	level := createTestLevel()
+	level.updateDistanceMaps()
+
+	// Chasing gets closer
+	from := Pos{X: 7, Y: 3}
+	next, ok := level.PlayerMap.Downhill(level, from)
+	if !ok || next != (Pos{X: 7, Y: 4}) {
+		t.Errorf("Expected to step to {7,4}, got %v", next)
+	}
+
+	// Wait behind another monster instead of walking into it
+	level.Monsters[Pos{X: 7, Y: 4}] = NewSpider(Pos{X: 7, Y: 4})
+	if next, ok = level.PlayerMap.Downhill(level, from); ok {
+		t.Errorf("Should wait behind another monster, got %v", next)
+	}
+	delete(level.Monsters, Pos{X: 7, Y: 4})
+
+	// Fleeing gets further away
+	next, ok = level.fleeMap().Downhill(level, from)
+	if !ok || level.PlayerMap.Dist[next.Y][next.X] <= level.PlayerMap.Dist[from.Y][from.X] {
+		t.Errorf("Fleeing should move away from the player, got %v", next)
+	}
+
+	// Standing on the player's tile is the bottom of the hill
+	if _, ok := level.PlayerMap.Downhill(level, level.Player.Pos); ok {
+		t.Error("There is nowhere lower than the origin")
+	}
+}
+
+func TestDistanceMapsReused(t *testing.T) {
+	level := createTestLevel()
+	level.Map[7][9].OverlayRune = ClosedDoor
+	level.updateDistanceMaps()
+	dm := level.PlayerMap
+	if dm == nil || level.FleeMap != nil {
+		t.Fatal("Expected a player map, and no flee map until someone runs")
+	}
+
+	// Nothing changed, nothing to do
+	level.updateDistanceMaps()
+	if level.PlayerMap != dm {
+		t.Error("Expected the map to be kept while the player stands still")
+	}
+	flee := level.fleeMap()
+	if flee == nil || level.fleeMap() != flee {
+		t.Error("Expected the flee map to be made once")
+	}
+
+	// Opening a door changes the costs
+	checkDoor(level, Pos{9, 7})
+	level.updateDistanceMaps()
+	if level.PlayerMap == dm || level.FleeMap != nil {
+		t.Error("Expected the maps to be thrown away when a door opens")
+	}
+
+	// So does the player moving
+	dm = level.PlayerMap
+	level.Player.Pos = Pos{6, 7}
+	level.updateDistanceMaps()
+	if level.PlayerMap == dm || level.PlayerMap.Origin != level.Player.Pos {
+		t.Error("Expected a new map from where the player is now")
+	}
+}
+
+func TestMonsterFlees(t *testing.T) {
+	level := createTestLevel()
+	rat := NewRat(Pos{X: 7, Y: 4})
+	level.Monsters[rat.Pos] = rat
+	rat.Hitpoints = rat.FleeHitpoints
+
+	before := abs(rat.X-level.Player.X) + abs(rat.Y-level.Player.Y)
+	rat.Update(level)
+	after := abs(rat.X-level.Player.X) + abs(rat.Y-level.Player.Y)
+	if after <= before {
+		t.Errorf("Wounded rat should run away, distance went from %d to %d", before, after)
+	}
+}
+
+// Big open level with pillars and lots of monsters
+func createBenchmarkLevel() *Level {
+	level := createTestLevel()
+	width, height := 120, 80
+	level.Map = make([][]Tile, height)
+	for y := range level.Map {
+		level.Map[y] = make([]Tile, width)
+		for x := range level.Map[y] {
+			level.Map[y][x] = Tile{Rune: DirtFloor}
+			if x%6 == 3 && y%4 != 0 {
+				level.Map[y][x].Rune = StoneWall
+			}
+		}
+	}
+	level.Player.Pos = Pos{X: width / 2, Y: height / 2}
+	for i := 0; i < 50; i++ {
+		pos := Pos{X: (i * 37) % width, Y: (i * 53) % height}
+		if level.Map[pos.Y][pos.X].Rune == DirtFloor && pos != level.Player.Pos {
+			level.Monsters[pos] = NewRat(pos)
+		}
+	}
+	return level
+}
+
+func BenchmarkMonsterPathsAstar(b *testing.B) {
+	level := createBenchmarkLevel()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, m := range level.Monsters {
+			level.findPath(m.Pos, level.Player.Pos, &m.Character)
+		}
+	}
+}
+
+// One tick on screen, where the player moved so the map is made again
+func BenchmarkMonsterPathsDistanceMap(b *testing.B) {
+	level := createBenchmarkLevel()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		level.terrainChanged()
+		level.updateDistanceMaps()
+		for _, m := range level.Monsters {
+			level.PlayerMap.Downhill(level, m.Pos)
+		}
+	}
+}
+
+// The same, with one monster running away so the flee map is made too
+func BenchmarkMonsterPathsFleeMap(b *testing.B) {
+	level := createBenchmarkLevel()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		level.terrainChanged()
+		level.updateDistanceMaps()
+		for _, m := range level.Monsters {
+			level.PlayerMap.Downhill(level, m.Pos)
+		}
+		level.fleeMap().Downhill(level, level.sortedMonsters()[0].Pos)
+	}
+}
+
+// Coming back to a level after a long time away
+func BenchmarkCatchUp(b *testing.B) {
+	game := createTestGame()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		level := createBenchmarkLevel()
+		level.Offscreen = true
+		level.LastSeen = &Pos{X: 1, Y: 1}
+		game.Ticks = catchUpTicks
+		b.StartTimer()
+		game.catchUp(level)
+	}
+}
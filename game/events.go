@@ -0,0 +1,110 @@
+package game
+
+import "sync"
+
+// GameEvent is what kind of thing happened
+type GameEvent int
+
+const (
+	// Move is a character taking a step
+	Move GameEvent = iota
+	// DoorOpen is the player opening a door
+	DoorOpen
+	// Trap is the player stepping on a trap
+	Trap
+	// Portal is the player taking a portal to another level, or a monster following them
+	Portal
+	// PickUp is a character picking an item up
+	PickUp
+	// Drop is a character dropping an item
+	Drop
+	// Attack is a character starting a fight
+	Attack
+	// Damage is a character getting hit in a fight
+	Damage
+	// Kill is a character being slain in a fight
+	Kill
+	// Mine is a mine going off under a character
+	Mine
+	// TurnOver is the attacker finishing their turn of a fight, with how it went
+	TurnOver
+	// Experience is a character earning experience
+	Experience
+	// LevelUp is a character reaching a new level
+	LevelUp
+)
+
+// Event is something that happened, and who and what it happened to. Only the fields that make sense for the kind are set.
+type Event struct {
+	Typ    GameEvent
+	Who    *Character    // Who did it
+	Target *Character    // Who it was done to
+	Pos    Pos           // Where it happened
+	Item   *Item         // What was picked up or dropped
+	Amount int           // How much damage was done, experience earned or the level reached
+	Result *BattleResult // How a turn of a fight went
+}
+
+// EventBus hands every event to everyone listening, in the order they happened
+type EventBus struct {
+	subscribers []func(Event)
+}
+
+// Subscribe listens to every event from now on
+func (bus *EventBus) Subscribe(subscriber func(Event)) {
+	bus.subscribers = append(bus.subscribers, subscriber)
+}
+
+// Publish hands an event to every subscriber
+func (bus *EventBus) Publish(e Event) {
+	for _, subscriber := range bus.subscribers {
+		subscriber(e)
+	}
+}
+
+// EventQueue holds on to events from the bus for a subscriber on another goroutine, like the UI's sounds
+type EventQueue struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Record queues an event, subscribe it to the bus
+func (q *EventQueue) Record(e Event) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+}
+
+// Take hands over every event since the last time
+func (q *EventQueue) Take() []Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	events := q.events
+	q.events = nil
+	return events
+}
+
+// Emit records an event for this turn, and lets the log and stats know about it
+func (level *Level) Emit(e Event) {
+	level.Events = append(level.Events, e)
+	level.bus().Publish(e)
+}
+
+// Levels made on their own, like practice, get a bus for their own log and stats
+func (level *Level) bus() *EventBus {
+	if level.Bus == nil {
+		level.Bus = &EventBus{}
+		level.Bus.Subscribe(func(e Event) { level.log().Record(e) })
+		level.Bus.Subscribe(func(e Event) {
+			if level.Stats != nil {
+				level.Stats.Record(e)
+			}
+		})
+	}
+	return level.Bus
+}
+
+// newTurn forgets last turn's events, once the UIs have seen them
+func (level *Level) newTurn() {
+	level.Events = nil
+}
@@ -0,0 +1,73 @@
+package game
+
+import "testing"
+
+func TestEventBus(t *testing.T) {
+	level := createTestLevel()
+	level.Stats = &RunStats{}
+	var heard []GameEvent
+	level.bus().Subscribe(func(e Event) { heard = append(heard, e.Typ) })
+
+	rat := NewRat(Pos{1, 1})
+	level.Emit(Event{Typ: Damage, Who: &level.Player.Character, Target: &rat.Character, Amount: 1})
+	level.Emit(Event{Typ: TurnOver, Who: &level.Player.Character, Target: &rat.Character, Result: &BattleResult{Attacker: "You", Hits: 3}})
+	if len(heard) != 2 || heard[0] != Damage || heard[1] != TurnOver {
+		t.Errorf("Expected subscribers to hear events in order, got %v", heard)
+	}
+	if len(level.Events) != 2 {
+		t.Errorf("Expected both events this turn, got %d", len(level.Events))
+	}
+	if texts := messageTexts(level); len(texts) != 2 || texts[0] != "You hit the Rat for 1 damage." {
+		t.Errorf("Expected the log to hear about the hit, got %v", texts)
+	}
+	if len(level.Stats.Battles) != 1 || level.Stats.NotesHit != 3 {
+		t.Errorf("Expected the stats to hear about the turn, got %+v", level.Stats)
+	}
+
+	level.newTurn()
+	if len(level.Events) != 0 {
+		t.Error("Expected a new turn to start with no events")
+	}
+}
+
+func TestEventsInOneTurn(t *testing.T) {
+	setupTestWorld(t)
+	game := NewGame(1)
+	level := game.CurrentLevel
+	level.Player.Pos = Pos{2, 1} // Next to the door
+	rat := level.Monsters[Pos{1, 2}]
+	rat.ActionPoints = 1 // Enough to step and attack once the player's turn is over
+
+	level.newTurn()
+	game.handleInput(&Input{Typ: Right})
+
+	want := []GameEvent{DoorOpen, Move, Attack}
+	if len(level.Events) != len(want) {
+		t.Fatalf("Expected the door, the rat stepping and the rat attacking in one turn, got %+v", level.Events)
+	}
+	for i, typ := range want {
+		if level.Events[i].Typ != typ {
+			t.Errorf("Expected event %d to be %d, got %+v", i, typ, level.Events[i])
+		}
+	}
+	if e := level.Events[0]; e.Who != &level.Player.Character || e.Pos != (Pos{3, 1}) {
+		t.Errorf("Expected the player to open the door, got %+v", e)
+	}
+	if e := level.Events[2]; e.Who != &rat.Character || e.Target != &level.Player.Character {
+		t.Errorf("Expected the rat to attack the player, got %+v", e)
+	}
+}
+
+func TestEventQueue(t *testing.T) {
+	bus := &EventBus{}
+	queue := &EventQueue{}
+	bus.Subscribe(queue.Record)
+	bus.Publish(Event{Typ: Move})
+	bus.Publish(Event{Typ: DoorOpen})
+	if events := queue.Take(); len(events) != 2 || events[0].Typ != Move || events[1].Typ != DoorOpen {
+		t.Errorf("Expected both events in order, got %+v", events)
+	}
+	if events := queue.Take(); len(events) != 0 {
+		t.Errorf("Expected the queue to be empty once taken, got %+v", events)
+	}
+}
@@ -0,0 +1,52 @@
+package game
+
+import "math"
+
+// Each level takes this much more experience than the last
+const xpPerLevel = 50
+
+// Sight stops growing here, past that the screen is all map
+const maxSightRange = 10
+
+// XPToNext is how much experience a character needs to go from their level to the next
+func XPToNext(level int) int {
+	return xpPerLevel * level
+}
+
+// killXP is what slaying a monster is worth. A sloppy fight is worth half, a perfect one half again.
+func killXP(m *Monster, accuracy float64) int {
+	return int(math.Round(float64(m.XPValue) * (0.5 + accuracy/100)))
+}
+
+// GainXP adds experience, levelling up as many times as it pays for
+func (level *Level) GainXP(c *Character, xp int) {
+	if xp <= 0 {
+		return
+	}
+	c.XP += xp
+	level.Emit(Event{Typ: Experience, Who: c, Pos: c.Pos, Amount: xp})
+	for c.XP >= XPToNext(c.CharLevel) {
+		c.XP -= XPToNext(c.CharLevel)
+		c.levelUp()
+		level.Emit(Event{Typ: LevelUp, Who: c, Pos: c.Pos, Amount: c.CharLevel})
+	}
+}
+
+// levelUp grows a character's stats: more hitpoints every level, stamina every other level and sight every third
+func (c *Character) levelUp() {
+	c.CharLevel++
+	c.MaxHitpoints += 4
+	c.Heal(4) // The new hitpoints come full
+	if c.CharLevel%2 == 0 {
+		c.MaxStamina++
+		c.Stamina++
+	}
+	if c.CharLevel%3 == 0 {
+		c.SightRange = min(c.SightRange+1, maxSightRange)
+	}
+}
+
+// Heal gives hitpoints back, up to the character's max
+func (c *Character) Heal(amount int) {
+	c.Hitpoints = min(c.Hitpoints+amount, c.MaxHitpoints)
+}
@@ -0,0 +1,70 @@
+package game
+
+import "testing"
+
+func TestKillXP(t *testing.T) {
+	rat, spider := NewRat(Pos{}), NewSpider(Pos{})
+	if killXP(rat, 0) != 5 || killXP(rat, 50) != 10 || killXP(rat, 100) != 15 || killXP(spider, 100) != 45 {
+		t.Errorf("Expected experience to scale with the monster and accuracy, got %d %d %d %d", killXP(rat, 0), killXP(rat, 50), killXP(rat, 100), killXP(spider, 100))
+	}
+}
+
+func TestGainXP(t *testing.T) {
+	level := createTestLevel()
+	p := &level.Player.Character
+	p.Hitpoints, p.MaxHitpoints, p.MaxStamina, p.SightRange = 10, 20, 2, 7
+
+	// 50 for level 2, 100 for level 3, and some left over
+	level.GainXP(p, 160)
+	if p.CharLevel != 3 || p.XP != 10 {
+		t.Errorf("Expected level 3 with 10 experience, got level %d with %d", p.CharLevel, p.XP)
+	}
+	if p.MaxHitpoints != 28 || p.Hitpoints != 18 || p.MaxStamina != 3 || p.SightRange != 8 {
+		t.Errorf("Expected stats to grow, got %+v", *p)
+	}
+	want := []GameEvent{Experience, LevelUp, LevelUp}
+	if len(level.Events) != len(want) {
+		t.Fatalf("Expected %v, got %+v", want, level.Events)
+	}
+	for i, e := range level.Events {
+		if e.Typ != want[i] {
+			t.Errorf("Expected %v, got %+v", want, level.Events)
+		}
+	}
+	if texts := messageTexts(level); texts[0] != "You gain 160 experience." || texts[2] != "You reach level 3!" {
+		t.Errorf("Expected experience messages, got %v", texts)
+	}
+
+	// Healing stops at the max
+	p.Heal(100)
+	if p.Hitpoints != p.MaxHitpoints {
+		t.Errorf("Expected healing to stop at %d, got %d", p.MaxHitpoints, p.Hitpoints)
+	}
+}
+
+func TestKillGivesXP(t *testing.T) {
+	level := createTestLevel()
+	p := &level.Player.Character
+	rat := NewRat(Pos{7, 6})
+	rat.Hitpoints = 1
+	level.Monsters[rat.Pos] = rat
+	level.Battle = &Battle{C1: p, C2: &rat.Character, Active: true}
+	p.Burst = &Burst{}
+	p.Burst.Turn.Judgements[Perfect] = 4
+
+	level.ResolveDamage()
+	if level.Monsters[rat.Pos] != nil || p.XP != 15 {
+		t.Errorf("Expected a perfect kill to be worth 15 experience, got %d", p.XP)
+	}
+
+	// Nobody learns anything from practice
+	level.Practice = true
+	rat = NewRat(Pos{7, 6})
+	rat.Hitpoints = 1
+	level.Monsters[rat.Pos] = rat
+	level.Battle.C2 = &rat.Character
+	level.ResolveDamage()
+	if p.XP != 15 {
+		t.Errorf("Expected no experience from practice, got %d", p.XP)
+	}
+}
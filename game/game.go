@@ -10,6 +10,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game/chart"
+	"github.com/maxproske/lyns-rhythm-dungeon/game/path"
 )
 
 // Game contains channels for game and UI threads
@@ -18,6 +21,16 @@ type Game struct {
 	InputChan    chan *Input   // Receieve input from multiple UIs
 	Levels       map[string]*Level
 	CurrentLevel *Level
+	Settings     *Settings
+	SettingsPath string // Where settings are saved, or empty to not save them
+	Stats        *RunStats
+	Log          *MessageLog // Every message of the run, whatever level it happened on
+	Bus          *EventBus   // Every event of the run goes through here
+	Replay       *Replay     // Every event of the run, written down
+	Seed         int64       // Where every pattern in the run comes from
+	SavePath     string      // Where the run is saved on quit, or empty to not save it
+	Ticks        int         // How much time has gone by, so levels the player left can catch up
+	SaveErr      error       // Why the run couldn't be saved on quit, for the UIs once the level channels close
 }
 
 // NewGame needs to know how many channels to take in
@@ -29,8 +42,27 @@ func NewGame(numWindows int) *Game {
 	inputChan := make(chan *Input)
 	levels := loadLevels()
 
-	game := &Game{levelChans, inputChan, levels, nil}
-	game.loadWorldFile()            // Load world file
+	game := &Game{LevelChans: levelChans, InputChan: inputChan, Levels: levels}
+	game.Stats = &RunStats{}
+	game.Log = &MessageLog{}
+	game.loadSettings()
+	game.Bus = &EventBus{}
+	game.Bus.Subscribe(game.Log.Record)
+	game.Bus.Subscribe(game.Stats.Record)
+	game.Replay = &Replay{}
+	game.Bus.Subscribe(func(e Event) { game.Replay.Record(game.Ticks, e) })
+	for _, level := range levels {
+		level.Stats = game.Stats
+		level.Log = game.Log
+		level.Bus = game.Bus
+	}
+	if savePath, err := DefaultSavePath(); err == nil {
+		game.SavePath = savePath
+	}
+	game.SetClock(NewWallClock()) // Until a UI starts playing music
+	game.loadWorldFile()          // Load world file
+	game.enterLevel(game.CurrentLevel)
+	game.loadCharts()
 	game.CurrentLevel.lineOfSight() // Draw visible tiles without moving
 
 	return game
@@ -38,8 +70,9 @@ func NewGame(numWindows int) *Game {
 
 // Battle tracks the position of two characters
 type Battle struct {
-	C1 *Character
-	C2 *Character
+	C1     *Character
+	C2     *Character
+	Active bool // Until the attacker's turn is over, and nobody can walk away
 }
 
 // InputType is a tagged union/discriminating union/sum type
@@ -70,6 +103,20 @@ const (
 	EquipItem
 	// Search input type
 	Search
+	// UpLeft input type (eight-way movement only)
+	UpLeft
+	// UpRight input type (eight-way movement only)
+	UpRight
+	// DownLeft input type (eight-way movement only)
+	DownLeft
+	// DownRight input type (eight-way movement only)
+	DownRight
+	// NotePressed input type (a column key went down during battle)
+	NotePressed
+	// NoteReleased input type (a column key went up, to let go of holds)
+	NoteReleased
+	// UpdateSettings input type (replaces the settings and saves them)
+	UpdateSettings
 )
 
 // Input ...
@@ -78,6 +125,9 @@ type Input struct {
 	Item         *Item // Item will be the data, not the position of a click
 	Monster      *Monster
 	LevelChannel chan *Level
+	Time         int // Milliseconds when the key went down or up, for timing holds
+	Column       int // Which column a note input is for
+	Settings     *Settings
 }
 
 // Tile enum is just an alias for a rune (a character in Go)
@@ -116,6 +166,31 @@ type Pos struct {
 	X, Y int
 }
 
+// MoveMode decides how many neighbours a tile has
+type MoveMode int
+
+const (
+	// FourWay only allows orthogonal steps
+	FourWay MoveMode = iota
+	// EightWay also allows diagonal steps
+	EightWay
+)
+
+// Movement costs are scaled by 10 so diagonals stay integers
+const (
+	costStep     = 10  // Orthogonal step onto an empty tile
+	costDiagonal = 14  // Roughly costStep * sqrt(2)
+	costDoor     = 20  // Closed doors take time to open
+	costOccupied = 30  // Wait for another monster to move out of the way
+	costHazard   = 100 // Only walk over a known trap if there is no other way
+)
+
+// Path is the result of a pathfinding search
+type Path struct {
+	Steps []Pos // Includes the start and goal
+	Cost  int
+}
+
 // LevelPos ...
 type LevelPos struct {
 	*Level
@@ -132,47 +207,53 @@ type Entity struct {
 // Player ...
 type Player struct {
 	Character
+	Class string // What the run started as, empty for the old default
 }
 
 // Character ...
 type Character struct {
 	Entity
 	Hitpoints    int
+	MaxHitpoints int // Healing stops here
+	XP           int // Experience towards the next level
+	CharLevel    int // Character level, starting at 1
 	MaxStamina   int
-	Stamina      int // How many notes a character can hit per battle
-	Speed        float64
-	ActionPoints float64 // How many tiles a character can move per turn
+	Stamina      int      // How many notes a character can hit per battle
+	Speed        float64  // Energy gained each tick, so 2 is twice as many actions as the player
+	ActionPoints float64  // Energy saved up, every action costs 1
+	Effects      []Effect // Haste, slow and the like
 	SightRange   int
 	Items        []*Item
 	Helmet       *Item
 	Weapon       *Item
-	PatternRNG   *rand.Rand // Each character has rand value seperate from ui
+	PatternRNG   *rand.Rand     // Each character has rand value seperate from ui
+	patternSrc   *patternSource // Where PatternRNG is up to in its seed, nil if it wasn't seeded by the game
 	Burst        *Burst
+	Chart        *chart.Chart   // Authored notes to attack with instead of random streams
+	ChartPos     int            // Next note in the chart
+	Keys         int            // How many columns to attack with, the default keymode if not set
+	Patterns     PatternWeights // Which patterns to throw, plain streams if empty
+	Difficulty   int
+	Passive      Passive // What the class does differently in fights
 }
 
 // Burst tracks note length to preserve colour order
 type Burst struct {
-	Notes    []int
-	MaxCombo int
-	Combo    int
+	Notes         []Note
+	MaxCombo      int
+	Combo         int
+	Keys          int        // How many columns the burst is played on
+	Start         int        // Song position the burst starts at, note times are relative to this
+	Held          []HeldNote // Holds and rolls that are being held down
+	Judgements    [NumJudgements]int
+	LastJudgement Judgement
+	LastJudgedAt  int // Song position of the last judgement, so the UI knows when to fade it out
+	LastOffset    int // How early (negative) or late the last note was hit
+	Streak        int // Notes hit in a row without missing, what rhythm games call a combo
+	BPM           int // Tempo the notes are written at, for scroll speed
+	Turn          TurnStats
 }
 
-// GameEvent provides visibility of events to UI2D
-type GameEvent int
-
-const (
-	// NoEvent
-	NoEvent GameEvent = iota
-	Move
-	DoorOpen
-	Attack
-	Hit
-	Portal
-	PickUp
-	Drop
-	Damage
-)
-
 // Level holds the 2D array that represents the map
 type Level struct {
 	Map       [][]Tile
@@ -180,11 +261,21 @@ type Level struct {
 	Monsters  map[Pos]*Monster // Pos as key, get back monster
 	Items     map[Pos][]*Item  // Allow multiple items per tile
 	Portals   map[Pos]*LevelPos
-	Events    []string
-	EventPos  int
 	Debug     map[Pos]bool // Map x/y positions to true/false
-	LastEvent GameEvent    // Events not visible to the player
+	Events    []Event      // What happened this turn, in order
+	Bus       *EventBus    // Shared by every level, so the log and stats hear about everything
 	Battle    *Battle
+	MoveMode  MoveMode     // Four or eight way movement
+	PlayerMap *DistanceMap // How far every tile is from the player, shared by all monsters
+	FleeMap   *DistanceMap // Inverted player map for monsters running away, nil until one does
+	Clock     Clock        // Song position for battles
+	Settings  *Settings    // Shared by every level, so the UI can show them
+	Stats     *RunStats    // Shared by every level, for the end of the run
+	Log       *MessageLog  // Shared by every level, so history survives portals
+	Practice  bool         // Nobody gets hurt
+	Offscreen bool         // The player is on another level
+	LastSeen  *Pos         // Where the player left from, or nil if they've never been here
+	LeftAt    int          // Game tick the player left on, to catch up from when they come back
 }
 
 // DropItem ...
@@ -196,7 +287,7 @@ func (level *Level) DropItem(itemToDrop *Item, character *Character) {
 			// Reverse order of MoveItem function
 			character.Items = append(character.Items[:i], character.Items[i+1:]...) // Delete item from world
 			level.Items[pos] = append(level.Items[pos], item)                       // Add to inventory
-			level.AddEvent(character.Name + " dropped 1x " + item.Name)
+			level.Emit(Event{Typ: Drop, Who: character, Pos: pos, Item: item})
 			return
 		}
 	}
@@ -213,7 +304,7 @@ func (level *Level) MoveItem(itemToMove *Item, character *Character) {
 			items = append(items[:i], items[i+1:]...)       // Delete item from world
 			level.Items[pos] = items                        // Update the map
 			character.Items = append(character.Items, item) // Add to inventory
-			level.AddEvent(character.Name + " picked up 1x " + item.Name)
+			level.Emit(Event{Typ: PickUp, Who: character, Pos: pos, Item: item})
 			return // Return early
 		}
 	}
@@ -224,17 +315,24 @@ func (level *Level) MoveItem(itemToMove *Item, character *Character) {
 func (level *Level) Attack(c1, c2 *Character) {
 	level.Battle.C1 = c1
 	level.Battle.C2 = c2
-	level.LastEvent = Attack
+	level.Battle.Active = true
 	// Attach new stream pattern to attacking character
-	if c1.Burst == nil || c1.Burst != nil && len(c1.Burst.Notes) == 0 {
+	if c1.Burst == nil || c1.Burst != nil && c1.Burst.Done() {
 		streamLength := c2.Hitpoints
-		c1.Burst = &Burst{c1.MakeStream(streamLength), streamLength, 0}
-	}
-	if c1.Name == "You" {
-		level.AddEvent(c1.Name + " attack the " + c2.Name + ".")
-	} else {
-		level.AddEvent("The " + c1.Name + " attacks you.")
+		keys := battleKeys(c1, c2)
+		start := burstStart(level.SongPosition(), clockTempo(level.Clock))
+		c1.Burst = &Burst{MaxCombo: streamLength, Keys: keys, Start: start, BPM: battleBPM}
+		if c1.Chart != nil {
+			c1.Burst.Notes = c1.MakeChartStream(streamLength)
+			c1.Burst.BPM = int(c1.Chart.Timing.BPMAt(0))
+		} else if c1.Patterns != (PatternWeights{}) {
+			c1.Burst.Notes = c1.makePatterns(streamLength, c1.Patterns, c1.Difficulty, keys)
+		} else {
+			c1.Burst.Notes = c1.makeStream(streamLength, keys)
+		}
 	}
+	c1.Burst.Turn = TurnStats{}
+	level.Emit(Event{Typ: Attack, Who: c1, Target: c2, Pos: c2.Pos})
 }
 
 // ResolveDamage calculates damage dealt after an attack
@@ -242,7 +340,6 @@ func (level *Level) ResolveDamage() {
 	c1 := level.Battle.C1
 	c2 := level.Battle.C2
 	// a1 damaging a2 first
-	c1.ActionPoints--
 	c1AttackPower := 1 // c1.MaxStamina - c1.Stamina
 
 	// // (temporarily disabled)
@@ -259,22 +356,25 @@ func (level *Level) ResolveDamage() {
 
 	// // Apply damage
 	// c2.Hitpoints -= damage
-	damage := c1AttackPower
+	damage := c1AttackPower + c1.Passive.BonusDamage
 	c2.Hitpoints -= damage
-
-	if c1.Name == "You" {
-		level.AddEvent(c1.Name + " hit the " + c2.Name + " for " + strconv.Itoa(damage) + " damage.")
-	} else {
-		level.AddEvent("The " + c1.Name + " hits you for " + strconv.Itoa(damage) + " damage.")
+	if c1.Burst != nil {
+		c1.Burst.Turn.DamageDealt += damage
 	}
 
+	level.Emit(Event{Typ: Damage, Who: c1, Target: c2, Pos: c2.Pos, Amount: damage})
+
 	if c2.Hitpoints <= 0 {
-		if c1.Name == "You" {
-			level.AddEvent("The " + c2.Name + " collapses!")
-		} else {
-			level.AddEvent(c2.Name + " were slain by the " + c1.Name + "!")
-		}
+		level.Emit(Event{Typ: Kill, Who: c1, Target: c2, Pos: c2.Pos})
+		m, isMonster := level.Monsters[c2.Pos]
 		level.Kill(c2)
+		if isMonster && c1 == &level.Player.Character && !level.Practice {
+			accuracy := 0.0
+			if c1.Burst != nil {
+				accuracy = Accuracy(c1.Burst.Turn.Judgements)
+			}
+			level.GainXP(c1, killXP(m, accuracy))
+		}
 	}
 }
 
@@ -298,16 +398,6 @@ func (level *Level) Kill(c *Character) {
 	}
 }
 
-// AddEvent handles events list
-func (level *Level) AddEvent(event string) {
-	level.Events[level.EventPos] = event
-	level.EventPos++
-	if level.EventPos == len(level.Events) {
-		level.EventPos = 0 // Loop around to overwrite stale events
-	}
-
-}
-
 func (level *Level) lineOfSight() {
 	pos := level.Player.Pos
 	dist := level.Player.SightRange // Radius
@@ -453,6 +543,8 @@ func loadLevels() map[string]*Level {
 	player.MaxStamina = 2
 	player.Stamina = player.MaxStamina
 	player.Hitpoints = 20
+	player.MaxHitpoints = player.Hitpoints
+	player.CharLevel = 1
 	player.Name = "You"
 	player.Rune = '@'
 	player.Speed = 1.0
@@ -499,10 +591,9 @@ func loadLevels() map[string]*Level {
 
 		level := &Level{}
 		level.Debug = make(map[Pos]bool)
-		level.Events = make([]string, 10)
 		level.Player = player
 		level.Map = make([][]Tile, len(levelLines))
-		level.Battle = &Battle{nil, nil}
+		level.Battle = &Battle{}
 		level.Monsters = make(map[Pos]*Monster)
 		level.Items = make(map[Pos][]*Item)
 		level.Portals = make(map[Pos]*LevelPos)
@@ -641,7 +732,8 @@ func checkDoor(level *Level, pos Pos) {
 	t := level.Map[pos.Y][pos.X]
 	if t.OverlayRune == ClosedDoor {
 		level.Map[pos.Y][pos.X].OverlayRune = OpenDoor // Player has opened a door
-		level.LastEvent = OpenDoor
+		level.terrainChanged()
+		level.Emit(Event{Typ: DoorOpen, Who: &level.Player.Character, Pos: pos})
 		level.lineOfSight() // Check line of sight without moving a tile
 	}
 }
@@ -651,26 +743,25 @@ func checkTrap(level *Level, pos Pos) {
 	t := level.Map[pos.Y][pos.X]
 	if t.OverlayRune == ClosedTrap {
 		level.Map[pos.Y][pos.X].OverlayRune = OpenTrap // Player has stepped on a trap
-		level.LastEvent = OpenTrap
+		level.terrainChanged()
+		level.Emit(Event{Typ: Trap, Who: &level.Player.Character, Pos: pos})
 		level.Kill(&level.Player.Character)
 	}
 }
 
 // Move moves the player unless a monster exists in that location
 func (game *Game) Move(to Pos) {
-	if game.CurrentLevel.LastEvent != Attack {
+	if !game.CurrentLevel.Fighting() {
 		level := game.CurrentLevel
 		player := level.Player
 
 		// Check position we are moving to for portals
 		levelAndPos := level.Portals[to]
 		if levelAndPos != nil {
-			game.CurrentLevel = levelAndPos.Level
-			game.CurrentLevel.Player.Pos = levelAndPos.Pos
-			game.CurrentLevel.lineOfSight()
+			game.takePortal(to, levelAndPos)
 		} else {
 			player.Pos = to // Player has moved
-			level.LastEvent = Move
+			level.Emit(Event{Typ: Move, Who: &player.Character, Pos: to})
 			// Draw line of sight
 			for y, row := range level.Map {
 				for x := range row {
@@ -686,7 +777,7 @@ func (game *Game) Move(to Pos) {
 func (game *Game) resolveMovement(pos Pos) {
 	level := game.CurrentLevel
 	if level.Player.Speed > 0 {
-		if level.LastEvent != Attack {
+		if !level.Fighting() {
 			monster, exists := level.Monsters[pos]
 			if exists {
 				level.Attack(&level.Player.Character, &monster.Character) // Attacked
@@ -696,6 +787,7 @@ func (game *Game) resolveMovement(pos Pos) {
 				checkDoor(level, pos)
 				checkTrap(level, pos)
 			}
+			game.endPlayerTurn()
 		}
 	}
 }
@@ -720,37 +812,22 @@ func equip(c *Character, itemToEquip *Item) {
 func (game *Game) handleInput(input *Input) {
 	level := game.CurrentLevel
 	p := level.Player
-	if level.LastEvent == Attack && level.Battle.C1 == &p.Character {
+	if level.Fighting() && level.Battle.C1 == &p.Character {
 		burst := level.Player.Burst
-		if len(burst.Notes) > 0 && p.Stamina > 0 {
-			pos := -1
+		if !burst.Done() && p.Stamina > 0 {
 			switch input.Typ {
 			case Left:
-				pos = 0
+				level.Press(&p.Character, 0, input.Time)
 			case Down:
-				pos = 1
+				level.Press(&p.Character, 1, input.Time)
 			case Up:
-				pos = 2
+				level.Press(&p.Character, 2, input.Time)
 			case Right:
-				pos = 3
-			}
-			// Hit correct note
-			if burst.Notes[0] == pos {
-				p.Stamina--
-				p.Burst.Combo++ // Maintains note colour
-				p.Burst.Notes = burst.Notes[1:]
-				// Passed burst
-				if len(burst.Notes) == 0 {
-					p.Burst.Combo = 0
-					//p.Stamina = p.MaxStamina // Restore stamina
-					// level.LastEvent = Damage // More thump sound on damage?
-					return
-				}
-				if p.Stamina <= 0 {
-					//p.Stamina = p.MaxStamina // Restore stamina
-					// level.LastEvent = Damage
-					return
-				}
+				level.Press(&p.Character, 3, input.Time)
+			case NotePressed:
+				level.Press(&p.Character, input.Column, input.Time)
+			case NoteReleased:
+				level.Release(&p.Character, input.Column, input.Time)
 			}
 		}
 	} else {
@@ -768,12 +845,26 @@ func (game *Game) handleInput(input *Input) {
 		case Right:
 			newPos := Pos{p.X + 1, p.Y}
 			game.resolveMovement(newPos)
+		case UpLeft, UpRight, DownLeft, DownRight:
+			newPos := Pos{p.X - 1, p.Y - 1}
+			switch input.Typ {
+			case UpRight:
+				newPos = Pos{p.X + 1, p.Y - 1}
+			case DownLeft:
+				newPos = Pos{p.X - 1, p.Y + 1}
+			case DownRight:
+				newPos = Pos{p.X + 1, p.Y + 1}
+			}
+			// Ignore diagonals in four-way mode, and don't squeeze past corners
+			if canStep(level, p.Pos, newPos) {
+				game.resolveMovement(newPos)
+			}
 		case TakeItem:
 			level.MoveItem(input.Item, &p.Character)
-			level.LastEvent = PickUp
+			game.endPlayerTurn()
 		case DropItem:
 			level.DropItem(input.Item, &level.Player.Character)
-			level.LastEvent = Drop // Update activity log
+			game.endPlayerTurn()
 		case TakeAll:
 			var lastItem *Item
 			for _, item := range level.Items[p.Pos] {
@@ -783,9 +874,14 @@ func (game *Game) handleInput(input *Input) {
 				level.MoveItem(item, &p.Character)
 				lastItem = item
 			}
-			level.LastEvent = PickUp
+			if lastItem != nil {
+				game.endPlayerTurn()
+			}
 		case EquipItem:
 			equip(&level.Player.Character, input.Item)
+			game.endPlayerTurn()
+		case UpdateSettings:
+			game.updateSettings(input.Settings)
 		case CloseWindow:
 			close(input.LevelChannel) // Close level input game from
 			chanIndex := 0
@@ -801,112 +897,124 @@ func (game *Game) handleInput(input *Input) {
 	}
 }
 
-// Breath-first search for a* algorithm
+// Breath-first search for the nearest floor tile
 // Return just the rune so the overlay is not overwritten
 func (level *Level) bfsFloor(start Pos) rune {
-	frontier := make([]Pos, 0, 8)      // Start at 8 instead of growing/shrinking frontier
-	frontier = append(frontier, start) // Put in front of queue
-	visited := make(map[Pos]bool)
-	visited[start] = true // We have already visited start, we are on it
-
-	for len(frontier) > 0 {
-		current := frontier[0]
-
-		// If there are no surrounding tiles, search for the nearest floor tile
-		currentTile := level.Map[current.Y][current.X]
-		switch currentTile.Rune {
-		case DirtFloor:
-			return DirtFloor
-		default:
-		}
-
-		frontier = frontier[1:] // But first
-		for _, next := range getNeighbors(level, current) {
-			// Check if it is already visited
-			if !visited[next] {
-				frontier = append(frontier, next)
-				visited[next] = true
-			}
-		}
+	// If there are no surrounding tiles, search for the nearest floor tile
+	pos, found := path.BFS(&walkGraph{level: level}, path.Pos(start), func(pos path.Pos) bool {
+		return level.Map[pos.Y][pos.X].Rune == DirtFloor
+	})
+	if found {
+		return level.Map[pos.Y][pos.X].Rune
 	}
 	return DirtFloor
 }
 
-// Return slice of positions that are adjacent
-func getNeighbors(level *Level, pos Pos) []Pos {
-	neighbors := make([]Pos, 0, 4)
-	dirs := make([]Pos, 0, 4)
-	dirs = append(dirs, Pos{pos.X - 1, pos.Y})
-	dirs = append(dirs, Pos{pos.X + 1, pos.Y})
-	dirs = append(dirs, Pos{pos.X, pos.Y - 1})
-	dirs = append(dirs, Pos{pos.X, pos.Y + 1})
+// Orthogonal directions first, so four-way mode can use the first half
+var dirOffsets = [8]Pos{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
 
-	for _, dir := range dirs {
-		if canWalk(level, dir) {
-			neighbors = append(neighbors, dir)
-		}
+// How many of dirOffsets can be used
+func (level *Level) numDirs() int {
+	if level.MoveMode == EightWay {
+		return 8
 	}
+	return 4
+}
 
-	return neighbors
+// Diagonal steps are only allowed in eight-way mode, and can't cut around corners
+func canStep(level *Level, from, to Pos) bool {
+	if from.X == to.X || from.Y == to.Y {
+		return true
+	}
+	if level.MoveMode != EightWay {
+		return false
+	}
+	return !isSolid(level, Pos{to.X, from.Y}) && !isSolid(level, Pos{from.X, to.Y})
 }
 
-func (level *Level) astar(start, goal Pos) []Pos {
-	frontier := make(pqueue, 0, 8) // Start at 8 instead of growing/shrinking frontier
-	frontier = frontier.push(start, 1)
+// Is there something nobody can ever walk through?
+func isSolid(level *Level, pos Pos) bool {
+	if !inRange(level, pos) {
+		return true
+	}
+	t := level.Map[pos.Y][pos.X]
+	switch t.Rune {
+	case StoneWall, Blank:
+		return true
+	}
+	return t.OverlayRune == ClosedDoor
+}
 
-	cameFrom := make(map[Pos]Pos) // Keep a map of where we came from
-	cameFrom[start] = start       // Start didn't come from anywhere
+// Closed doors and traps can be pathed through, walls can't
+func canPath(level *Level, from, to Pos) bool {
+	if !inRange(level, to) || !canStep(level, from, to) {
+		return false
+	}
+	switch level.Map[to.Y][to.X].Rune {
+	case StoneWall, Blank:
+		return false
+	}
+	return true
+}
 
-	costSoFar := make(map[Pos]int) // Read to handle varying costs of travel
-	costSoFar[start] = 0
+// Cost of a single step, including whatever is on the tile we step onto
+func (level *Level) moveCost(from, to Pos, mover *Character) int {
+	// Monsters know where their own traps are, but the player doesn't
+	knowsTraps := mover != nil && mover.Name != "You"
+	cost := stepCost(from, to) + level.terrainCost(to, knowsTraps)
+	if m, exists := level.Monsters[to]; exists && (mover == nil || &m.Character != mover) {
+		cost += costOccupied
+	}
+	return cost
+}
 
-	//level.Debug = make(map[Pos]bool)
+func stepCost(from, to Pos) int {
+	if from.X != to.X && from.Y != to.Y {
+		return costDiagonal
+	}
+	return costStep
+}
 
-	var current Pos
-	for len(frontier) > 0 {
-		frontier, current = frontier.pop() // Get starting node from the beginning of it
+// Extra cost of entering a tile because of what is on it
+func (level *Level) terrainCost(pos Pos, knowsTraps bool) int {
+	switch level.Map[pos.Y][pos.X].OverlayRune {
+	case ClosedDoor:
+		return costDoor
+	case OpenTrap:
+		return costHazard // Sprung traps are known to everyone
+	case ClosedTrap:
+		if knowsTraps {
+			return costHazard
+		}
+	}
+	return 0
+}
 
-		if current == goal {
-			// Reverse slice
-			path := make([]Pos, 0)
+// Estimate of the remaining cost, never larger than the real cost
+func (level *Level) heuristic(a, b Pos) int {
+	return level.pathHeuristic()(path.Pos(a), path.Pos(b))
+}
 
-			// We've found our path
-			p := current
-			for p != start {
-				path = append(path, p)
-				p = cameFrom[p]
-			}
-			path = append(path, p)
+// The heuristic for the level's move mode, octile when diagonals are allowed
+func (level *Level) pathHeuristic() path.Heuristic {
+	if level.MoveMode == EightWay {
+		return path.Octile(costStep, costDiagonal)
+	}
+	return path.Manhattan(costStep)
+}
 
-			// Reverse slice
-			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
-				path[i], path[j] = path[j], path[i]
-			}
+func (level *Level) astar(start, goal Pos) []Pos {
+	return level.findPath(start, goal, nil).Steps
+}
 
-			//level.Debug = make(map[Pos]bool) // Clear debug tiles
-			// for _, pos := range path {
-			// 	level.Debug[pos] = true
-			// }
-			return path
-		}
-
-		for _, next := range getNeighbors(level, current) {
-			newCost := costSoFar[current] + 1 // Always 1 for now
-			_, exists := costSoFar[next]
-			if !exists || newCost < costSoFar[next] {
-				costSoFar[next] = newCost
-				// Manhatten distance (how many nodes in a straight line)
-				xDist := int(math.Abs(float64(goal.X - next.X)))
-				yDist := int(math.Abs(float64(goal.Y - next.Y)))
-				priority := newCost + xDist + yDist
-				frontier = frontier.push(next, priority) // Stick a new priority onto the queue
-				//level.Debug[next] = true
-				cameFrom[next] = current // Update where we came from
-			}
-		}
+// findPath finds the cheapest path for the mover, who may be nil if nobody is moving
+func (level *Level) findPath(start, goal Pos, mover *Character) Path {
+	found := path.AStar(&moverGraph{level: level, mover: mover}, path.Pos(start), path.Pos(goal), level.pathHeuristic())
+	steps := make([]Pos, len(found.Steps))
+	for i, step := range found.Steps {
+		steps[i] = Pos(step)
 	}
-
-	return nil
+	return Path{Steps: steps, Cost: found.Cost}
 }
 
 // Run loads the level from file
@@ -920,15 +1028,16 @@ func (game *Game) Run() {
 	// Get an input out of our input channel
 	for input := range game.InputChan {
 		if input.Typ == QuitGame {
+			game.SaveErr = game.saveOrForget()
+			// Let the UIs know we're done, even if the save didn't work
+			for _, lchan := range game.LevelChans {
+				close(lchan)
+			}
 			return
 		}
 
-		game.handleInput(input) // Pass along the input we got
-
-		// Update monsters
-		for _, monster := range game.CurrentLevel.Monsters {
-			monster.Update(game.CurrentLevel)
-		}
+		game.CurrentLevel.newTurn()
+		game.handleInput(input) // Pass along the input we got, monsters get their turns once the player has acted
 
 		if len(game.LevelChans) == 0 {
 			// All the windows have been closed
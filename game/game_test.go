@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game/chart"
 )
 
 func createTestGame() *Game {
@@ -39,21 +41,32 @@ func createTestLevel() *Level {
 				Name: "You",
 				Rune: '@',
 			},
-			Hitpoints:  100,
-			SightRange: 3,
-			Speed:      1.0,
-			PatternRNG: mrand.New(mrand.NewSource(0)),
+			Hitpoints:    100,
+			MaxHitpoints: 100,
+			CharLevel:    1,
+			SightRange:   3,
+			Speed:        1.0,
+			PatternRNG:   mrand.New(mrand.NewSource(0)),
 		},
 	}
 	level.Monsters = make(map[Pos]*Monster)
 	level.Items = make(map[Pos][]*Item)
 	level.Debug = make(map[Pos]bool)
 	level.Battle = &Battle{}
-	level.Events = make([]string, 10)
+	level.Log = &MessageLog{}
 	level.Portals = make(map[Pos]*LevelPos)
 	return level
 }
 
+// Just the text of every message, oldest first
+func messageTexts(level *Level) []string {
+	texts := make([]string, len(level.Log.Messages))
+	for i, m := range level.Log.Messages {
+		texts[i] = m.Text
+	}
+	return texts
+}
+
 func TestGameCreation(t *testing.T) {
 	game := createTestGame()
 	if game == nil {
@@ -260,8 +273,8 @@ func TestLevelOperations(t *testing.T) {
 
 	// Test event handling
 	event := "Test event"
-	level.Events = append(level.Events, event)
-	if len(level.Events) == 0 || level.Events[len(level.Events)-1] != event {
+	level.AddMessage(SystemMessage, event)
+	if texts := messageTexts(level); len(texts) == 0 || texts[len(texts)-1] != event {
 		t.Error("Failed to add event to level")
 	}
 
@@ -345,15 +358,15 @@ func TestBattle(t *testing.T) {
 
 func TestPatternGeneration(t *testing.T) {
 	burst := &Burst{
-		Notes:    make([]int, 4),
+		Notes:    make([]Note, 4),
 		MaxCombo: 4,
 		Combo:    0,
 	}
 
 	// Test note pattern generation
 	for i := range burst.Notes {
-		burst.Notes[i] = i + 1
-		if burst.Notes[i] != i+1 {
+		burst.Notes[i] = Note{Column: i + 1}
+		if burst.Notes[i].Column != i+1 {
 			t.Errorf("Expected note %d at position %d", i+1, i)
 		}
 	}
@@ -438,13 +451,13 @@ func TestMonsterAI(t *testing.T) {
 	// Test monster autoplay during battle
 	monster.Stamina = 3
 	monster.Burst = &Burst{
-		Notes:    []int{1, 2, 3},
+		Notes:    []Note{{Column: 1}, {Column: 2}, {Column: 3}},
 		MaxCombo: 3,
 		Combo:    0,
 	}
 
 	// Set up battle conditions
-	level.LastEvent = Attack
+	level.Battle.Active = true
 	initialNotes := len(monster.Burst.Notes)
 	initialStamina := monster.Stamina
 
@@ -473,12 +486,11 @@ func TestGameEvents(t *testing.T) {
 	level := createTestLevel()
 
 	// Clear existing events
-	level.Events = make([]string, 10)
-	level.EventPos = 0
+	level.Log = &MessageLog{}
 
 	// Test move event
-	level.AddEvent("Player moved to (1,1)")
-	if level.Events[0] != "Player moved to (1,1)" {
+	level.AddMessage(MovementMessage, "Player moved to (1,1)")
+	if level.Log.Messages[0].Text != "Player moved to (1,1)" {
 		t.Error("Move event should be recorded")
 	}
 
@@ -486,27 +498,27 @@ func TestGameEvents(t *testing.T) {
 	doorPos := Pos{X: 1, Y: 0}
 	level.Map[doorPos.Y][doorPos.X].Rune = ClosedDoor
 	level.Map[doorPos.Y][doorPos.X].Rune = OpenDoor
-	level.AddEvent("Door opened at (1,0)")
+	level.AddMessage(MovementMessage, "Door opened at (1,0)")
 
 	if level.Map[doorPos.Y][doorPos.X].Rune != OpenDoor {
 		t.Error("Door state should be open")
 	}
-	if level.Events[1] != "Door opened at (1,0)" {
+	if level.Log.Messages[1].Text != "Door opened at (1,0)" {
 		t.Error("Door event not recorded correctly")
 	}
 
 	// Test attack event
-	level.AddEvent("Player attacked monster")
-	if level.Events[2] != "Player attacked monster" {
+	level.AddMessage(CombatMessage, "Player attacked monster")
+	if level.Log.Messages[2].Text != "Player attacked monster" || level.Log.Messages[2].Kind != CombatMessage {
 		t.Error("Attack event not recorded correctly")
 	}
 
-	// Test event position wrapping
+	// Old events are kept
 	for i := 0; i < 15; i++ {
-		level.AddEvent(fmt.Sprintf("Event %d", i))
+		level.AddMessage(SystemMessage, fmt.Sprintf("Event %d", i))
 	}
-	if level.EventPos >= len(level.Events) {
-		t.Error("Event position should wrap around")
+	if len(level.Log.Messages) != 18 || level.Log.Messages[0].Text != "Player moved to (1,1)" {
+		t.Errorf("Expected every event to be kept, got %d", len(level.Log.Messages))
 	}
 }
 
@@ -575,12 +587,12 @@ func TestEntityCollision(t *testing.T) {
 	// Test monster collision with player
 	playerPos := Pos{X: 2, Y: 0}
 	level.Player.Pos = playerPos
-	level.LastEvent = NoEvent
+	level.Battle.Active = false
 
 	// Move monster towards player
 	monster.Move(playerPos, level)
 
-	if level.LastEvent != Attack {
+	if !level.Battle.Active {
 		t.Error("Moving monster into player should trigger attack")
 	}
 
@@ -613,8 +625,11 @@ func TestDamageResolution(t *testing.T) {
 	// Test attack initiation
 	level.Attack(attacker, defender)
 
-	if level.LastEvent != Attack {
-		t.Error("Attack should set LastEvent to Attack")
+	if !level.Battle.Active {
+		t.Error("Attack should start a battle")
+	}
+	if len(level.Events) != 1 || level.Events[0] != (Event{Typ: Attack, Who: attacker, Target: defender, Pos: defender.Pos}) {
+		t.Errorf("Attack should emit an event, got %+v", level.Events)
 	}
 
 	if attacker.Burst == nil {
@@ -631,7 +646,7 @@ func TestDamageResolution(t *testing.T) {
 
 	// Verify battle event was logged
 	foundEvent := false
-	for _, event := range level.Events {
+	for _, event := range messageTexts(level) {
 		if event != "" && (event == "Attacker hit the Defender for 1 damage." ||
 			event == "The Attacker hits you for 1 damage.") {
 			foundEvent = true
@@ -784,6 +799,107 @@ func TestAdvancedPathfinding(t *testing.T) {
 	}
 }
 
+func TestWeightedPathfinding(t *testing.T) {
+	level := createTestLevel()
+
+	// Straight line costs one step per tile
+	path := level.findPath(Pos{X: 0, Y: 0}, Pos{X: 4, Y: 0}, nil)
+	if len(path.Steps) != 5 {
+		t.Fatalf("Expected 5 steps, got %d", len(path.Steps))
+	}
+	if path.Cost != 4*costStep {
+		t.Errorf("Expected cost %d, got %d", 4*costStep, path.Cost)
+	}
+
+	// A known trap in a corridor is avoided when there is a way around
+	level.Map[0][2].OverlayRune = OpenTrap
+	path = level.findPath(Pos{X: 0, Y: 0}, Pos{X: 4, Y: 0}, nil)
+	for _, pos := range path.Steps {
+		if pos == (Pos{X: 2, Y: 0}) {
+			t.Error("Path should avoid a sprung trap")
+		}
+	}
+	if path.Cost != 6*costStep {
+		t.Errorf("Expected detour cost %d, got %d", 6*costStep, path.Cost)
+	}
+
+	// Hidden traps are only known to monsters
+	level.Map[0][2].OverlayRune = ClosedTrap
+	rat := NewRat(Pos{X: 0, Y: 0})
+	if cost := level.findPath(Pos{X: 0, Y: 0}, Pos{X: 4, Y: 0}, &rat.Character).Cost; cost != 6*costStep {
+		t.Errorf("Monster should walk around its own trap, got cost %d", cost)
+	}
+	if cost := level.findPath(Pos{X: 0, Y: 0}, Pos{X: 4, Y: 0}, &level.Player.Character).Cost; cost != 4*costStep {
+		t.Errorf("Player shouldn't know about a hidden trap, got cost %d", cost)
+	}
+	level.Map[0][2].OverlayRune = Blank
+
+	// Closed doors and occupied tiles cost extra
+	if cost := level.moveCost(Pos{X: 0, Y: 0}, Pos{X: 1, Y: 0}, nil); cost != costStep {
+		t.Errorf("Expected plain step cost %d, got %d", costStep, cost)
+	}
+	level.Map[0][1].OverlayRune = ClosedDoor
+	if cost := level.moveCost(Pos{X: 0, Y: 0}, Pos{X: 1, Y: 0}, nil); cost != costStep+costDoor {
+		t.Errorf("Expected door cost %d, got %d", costStep+costDoor, cost)
+	}
+	level.Map[0][1].OverlayRune = Blank
+	level.Monsters[Pos{X: 1, Y: 0}] = NewSpider(Pos{X: 1, Y: 0})
+	if cost := level.moveCost(Pos{X: 0, Y: 0}, Pos{X: 1, Y: 0}, &rat.Character); cost != costStep+costOccupied {
+		t.Errorf("Expected occupied cost %d, got %d", costStep+costOccupied, cost)
+	}
+
+	// No path reports zero cost
+	for y := range level.Map {
+		level.Map[y][10].Rune = StoneWall
+	}
+	path = level.findPath(Pos{X: 0, Y: 0}, Pos{X: 14, Y: 14}, nil)
+	if len(path.Steps) != 0 || path.Cost != 0 {
+		t.Error("Walled off goal should have an empty path")
+	}
+}
+
+func TestDiagonalMovement(t *testing.T) {
+	level := createTestLevel()
+	start := Pos{X: 0, Y: 0}
+	goal := Pos{X: 4, Y: 4}
+
+	// Four-way paths can't use diagonals
+	path := level.findPath(start, goal, nil)
+	if len(path.Steps) != 9 || path.Cost != 8*costStep {
+		t.Errorf("Expected 9 steps costing %d, got %d costing %d", 8*costStep, len(path.Steps), path.Cost)
+	}
+
+	// Eight-way paths go straight along the diagonal
+	level.MoveMode = EightWay
+	path = level.findPath(start, goal, nil)
+	if len(path.Steps) != 5 || path.Cost != 4*costDiagonal {
+		t.Errorf("Expected 5 steps costing %d, got %d costing %d", 4*costDiagonal, len(path.Steps), path.Cost)
+	}
+	if h := level.heuristic(start, goal); h > path.Cost {
+		t.Errorf("Octile heuristic %d overestimates cost %d", h, path.Cost)
+	}
+
+	// Can't cut around a wall corner
+	level.Map[0][1].Rune = StoneWall
+	if canStep(level, Pos{X: 0, Y: 0}, Pos{X: 1, Y: 1}) {
+		t.Error("Diagonal step should not squeeze past a wall")
+	}
+
+	// Player input respects the move mode
+	game := createTestGame()
+	p := game.CurrentLevel.Player
+	initialPos := p.Pos
+	game.handleInput(&Input{Typ: UpLeft})
+	if p.Pos != initialPos {
+		t.Error("Diagonal input should be ignored in four-way mode")
+	}
+	game.CurrentLevel.MoveMode = EightWay
+	game.handleInput(&Input{Typ: UpLeft})
+	if p.Pos != (Pos{initialPos.X - 1, initialPos.Y - 1}) {
+		t.Errorf("Expected player at %v, got %v", Pos{initialPos.X - 1, initialPos.Y - 1}, p.Pos)
+	}
+}
+
 func TestPatternMechanics(t *testing.T) {
 	// Test pattern generation
 	char := &Character{
@@ -798,15 +914,15 @@ func TestPatternMechanics(t *testing.T) {
 		}
 		// Verify each note is within valid range (0 to NumKeys-1)
 		for i, note := range stream {
-			if note < 0 || note >= NumKeys {
-				t.Errorf("Note at position %d is out of valid range: %d", i, note)
+			if note.Column < 0 || note.Column >= NumKeys {
+				t.Errorf("Note at position %d is out of valid range: %d", i, note.Column)
 			}
 		}
 	}
 
 	// Test burst mechanics with combo logic
 	burst := &Burst{
-		Notes:    []int{1, 2, 3, 0},
+		Notes:    []Note{{Column: 1}, {Column: 2}, {Column: 3}, {Column: 0}},
 		MaxCombo: 4,
 		Combo:    0,
 	}
@@ -831,7 +947,7 @@ func TestPatternMechanics(t *testing.T) {
 
 	// Test empty burst handling
 	emptyBurst := &Burst{
-		Notes:    []int{},
+		Notes:    []Note{},
 		MaxCombo: 4,
 		Combo:    0,
 	}
@@ -840,6 +956,133 @@ func TestPatternMechanics(t *testing.T) {
 	}
 }
 
+func TestChartStream(t *testing.T) {
+	s, err := chart.Load("chart/testdata/sample.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := createTestLevel()
+	spider := NewSpider(Pos{X: 7, Y: 6})
+	spider.Chart = s.Find("dance-single", "Beginner")
+	level.Monsters[spider.Pos] = spider
+
+	// Taps, holds and mines in order, and it loops around
+	level.Attack(&spider.Character, &level.Player.Character)
+	expected := []Note{
+		{Column: 0, Typ: TapNote, Time: 0},
+		{Column: 1, Typ: TapNote, Time: 500},
+		{Column: 2, Typ: TapNote, Time: 1000},
+		{Column: 3, Typ: TapNote, Time: 1500},
+		{Column: 0, Typ: HoldNote, Time: 2000, EndTime: 3000},
+		{Column: 1, Typ: MineNote, Time: 3500},
+		{Column: 0, Typ: TapNote, Time: 4500},
+		{Column: 3, Typ: TapNote, Time: 4500},
+		{Column: 0, Typ: TapNote, Time: 4750}, // Loops after a gap
+	}
+	for i, n := range expected {
+		if spider.Burst.Notes[i] != n {
+			t.Errorf("Note %d: expected %+v, got %+v", i, n, spider.Burst.Notes[i])
+		}
+	}
+	if hits := spider.Burst.Hits(); hits != level.Player.Hitpoints {
+		t.Errorf("Expected %d notes to hit, got %d", level.Player.Hitpoints, hits)
+	}
+
+	// The next burst continues where the last one stopped
+	spider.Burst.Notes = nil
+	spider.ChartPos = 2
+	level.Attack(&spider.Character, &level.Player.Character)
+	if spider.Burst.Notes[0].Column != 2 || spider.Burst.Notes[0].Time != 0 {
+		t.Errorf("Expected burst to continue from column 2 at time 0, got %+v", spider.Burst.Notes[0])
+	}
+}
+
+func TestChartStreamEndingOnHold(t *testing.T) {
+	c := newPatternCharacter(1)
+	c.Chart = &chart.Chart{Columns: 4, Notes: []chart.Note{
+		{Column: 1, Typ: chart.Tap, Time: 0},
+		{Column: 1, Typ: chart.Hold, Time: 500, EndTime: 2000},
+	}}
+	notes := c.MakeChartStream(3)
+	// The second loop waits for the hold to finish
+	if notes[2].Column != 1 || notes[2].Time != 2000+streamSpacing {
+		t.Errorf("Expected the chart to loop after the hold's tail, got %+v", notes[2])
+	}
+}
+
+func TestHoldsMinesAndRolls(t *testing.T) {
+	level := createTestLevel()
+	c := &level.Player.Character
+	c.Stamina = 10
+	c.Hitpoints = 10
+
+	// Holds only count once they're held long enough
+	c.Burst = &Burst{Notes: []Note{{Column: 1, Typ: HoldNote, Time: 0, EndTime: 1000}, {Column: 2, Time: 1250}}, MaxCombo: 2, Start: 5000}
+	if j := level.Press(c, 1, 5010); j != Perfect || len(c.Burst.Held) != 1 || len(c.Burst.Notes) != 1 {
+		t.Fatalf("Pressing a hold should start holding it, got %v", j)
+	}
+	level.Release(c, 2, 5950) // Wrong column does nothing
+	level.Release(c, 1, 5950) // Within leniency
+	if c.Burst.Combo != 1 || len(c.Burst.Held) != 0 || c.Stamina != 9 {
+		t.Errorf("Expected hold to be hit, got combo %d with %d held", c.Burst.Combo, len(c.Burst.Held))
+	}
+
+	// Letting go early drops the hold
+	c.Burst = &Burst{Notes: []Note{{Column: 0, Typ: HoldNote, Time: 0, EndTime: 1000}, {Column: 2, Time: 1250}}, MaxCombo: 2}
+	level.Press(c, 0, 0)
+	if j := level.Release(c, 0, 500); j != Miss {
+		t.Errorf("Expected dropped hold to be a miss, got %v", j)
+	}
+	if c.Burst.Combo != 0 || len(c.Burst.Notes) != 1 || len(c.Burst.Held) != 0 || c.Stamina != 8 {
+		t.Errorf("Expected dropped hold, got combo %d with %d notes left", c.Burst.Combo, len(c.Burst.Notes))
+	}
+
+	// Holding all the way to the end finishes it without letting go
+	c.Burst = &Burst{Notes: []Note{{Column: 0, Typ: HoldNote, Time: 0, EndTime: 1000}, {Column: 2, Time: 1250}}, MaxCombo: 2}
+	level.Press(c, 0, 0)
+	level.Press(c, 2, 1260)
+	if c.Burst.Combo != 0 || !c.Burst.Done() || c.Burst.Judgements[Perfect] != 2 {
+		t.Errorf("Expected hold and tap to be hit, got %v", c.Burst.Judgements)
+	}
+
+	// Rolls need a few taps
+	c.Stamina = 10
+	roll := Note{Column: 3, Typ: RollNote, Time: 0, EndTime: 500}
+	c.Burst = &Burst{Notes: []Note{roll, {Column: 2, Time: 750}}, MaxCombo: 2}
+	for i := 0; i < roll.RollTaps()-1; i++ {
+		level.Press(c, 3, i*rollInterval)
+	}
+	if c.Burst.Combo != 0 {
+		t.Error("Roll finished too early")
+	}
+	level.Press(c, 3, 375)
+	if c.Burst.Combo != 1 || len(c.Burst.Notes) != 1 {
+		t.Errorf("Expected roll to be hit after %d taps", roll.RollTaps())
+	}
+
+	// Not tapping a roll enough misses it
+	c.Burst = &Burst{Notes: []Note{roll, {Column: 2, Time: 750}}, MaxCombo: 2}
+	level.Press(c, 3, 0)
+	level.Press(c, 2, 750)
+	if c.Burst.Judgements[Miss] != 1 || !c.Burst.Done() {
+		t.Errorf("Expected unfinished roll to be missed, got %v", c.Burst.Judgements)
+	}
+
+	// Mines are dodged by not pressing their column, and hurt if you press it on the beat
+	c.Burst = &Burst{Notes: []Note{{Column: 0, Typ: MineNote}, {Column: 1}, {Column: 2, Typ: MineNote, Time: 250}, {Column: 3, Time: 500}}, MaxCombo: 2}
+	if c.Burst.Hits() != 2 {
+		t.Errorf("Expected mines not to count as hits, got %d", c.Burst.Hits())
+	}
+	level.Press(c, 1, 0)
+	if c.Hitpoints != 10 || len(c.Burst.Notes) != 3 {
+		t.Errorf("Expected the first mine to still be there, got %d hitpoints and %d notes left", c.Hitpoints, len(c.Burst.Notes))
+	}
+	level.Press(c, 2, 250)
+	if c.Hitpoints != 10-mineDamage || len(c.Burst.Notes) != 1 {
+		t.Errorf("Expected mine to explode and the first to be dodged, got %d hitpoints and %d notes left", c.Hitpoints, len(c.Burst.Notes))
+	}
+}
+
 func TestComplexBattleSequence(t *testing.T) {
 	level := createTestLevel()
 
@@ -868,7 +1111,7 @@ func TestComplexBattleSequence(t *testing.T) {
 		t.Fatal("Battle should generate burst pattern")
 	}
 
-	if level.LastEvent != Attack {
+	if !level.Battle.Active {
 		t.Error("Battle should start with Attack event")
 	}
 
@@ -902,7 +1145,7 @@ func TestComplexBattleSequence(t *testing.T) {
 	// Test battle event logging
 	hasAttackEvent := false
 	hasDamageEvent := false
-	for _, event := range level.Events {
+	for _, event := range messageTexts(level) {
 		if event != "" {
 			if event == "The Attacker attacks you." {
 				hasAttackEvent = true
@@ -1072,6 +1315,9 @@ func TestNewGame(t *testing.T) {
 		t.Fatalf("Failed to change working directory: %v", err)
 	}
 
+	// Keep settings away from the real config directory
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+
 	// Now initialize the game which should use our test files
 	game := NewGame(1)
 	if game == nil {
@@ -1123,7 +1369,7 @@ func TestDropItem(t *testing.T) {
 	}
 	expectedEvent := "You dropped 1x Sword"
 	found := false
-	for _, e := range level.Events {
+	for _, e := range messageTexts(level) {
 		if e == expectedEvent {
 			found = true
 			break
@@ -1151,7 +1397,7 @@ func TestMoveItem(t *testing.T) {
 	}
 	expectedEvent := "You picked up 1x Sword"
 	found := false
-	for _, e := range level.Events {
+	for _, e := range messageTexts(level) {
 		if e == expectedEvent {
 			found = true
 			break
@@ -1444,7 +1690,7 @@ func TestHandleInputCombatBurst(t *testing.T) {
 	monster := NewRat(monsterPos)
 	level.Monsters[monsterPos] = monster
 	level.Attack(&player.Character, &monster.Character)
-	player.Burst = &Burst{Notes: []int{2}, MaxCombo: 1, Combo: 0} // Assuming 2 corresponds to Up
+	player.Burst = &Burst{Notes: []Note{{Column: 2}}, MaxCombo: 1, Combo: 0} // Assuming 2 corresponds to Up
 	player.Stamina = 1
 
 	input := &Input{Typ: Up}
@@ -1505,8 +1751,8 @@ func TestRunUpdatesMonsters(t *testing.T) {
 
 	<-levelChan // Discard initial state sent on Run start
 
-	// Send dummy input to trigger game loop processing
-	game.InputChan <- &Input{Typ: None}
+	// Monsters only get a turn once the player acts, so attack the rat
+	game.InputChan <- &Input{Typ: Up}
 
 	updatedLevel := <-levelChan // Get state after update
 	updatedMonster, exists := updatedLevel.Monsters[monsterPos]
@@ -0,0 +1,60 @@
+package game
+
+import "github.com/maxproske/lyns-rhythm-dungeon/game/path"
+
+// Levels can be searched in a few different ways, depending on who is asking.
+// The path package has its own Pos, so convert at the edges.
+
+// walkGraph only steps onto tiles you could walk onto right now
+type walkGraph struct {
+	level *Level
+	buf   []path.Pos
+}
+
+func (g *walkGraph) Neighbors(p path.Pos) []path.Pos {
+	pos := Pos(p)
+	g.buf = g.buf[:0]
+	for _, offset := range dirOffsets[:g.level.numDirs()] {
+		next := Pos{pos.X + offset.X, pos.Y + offset.Y}
+		if canWalk(g.level, next) && canStep(g.level, pos, next) {
+			g.buf = append(g.buf, path.Pos(next))
+		}
+	}
+	return g.buf
+}
+
+func (g *walkGraph) Cost(from, to path.Pos) int {
+	return stepCost(Pos(from), Pos(to))
+}
+
+// moverGraph is what a single character sees, including doors, traps and other monsters
+type moverGraph struct {
+	level *Level
+	mover *Character // Can be nil if nobody in particular is moving
+	buf   []path.Pos
+}
+
+func (g *moverGraph) Neighbors(p path.Pos) []path.Pos {
+	pos := Pos(p)
+	g.buf = g.buf[:0]
+	for _, offset := range dirOffsets[:g.level.numDirs()] {
+		next := Pos{pos.X + offset.X, pos.Y + offset.Y}
+		if canPath(g.level, pos, next) {
+			g.buf = append(g.buf, path.Pos(next))
+		}
+	}
+	return g.buf
+}
+
+func (g *moverGraph) Cost(from, to path.Pos) int {
+	return g.level.moveCost(Pos(from), Pos(to), g.mover)
+}
+
+// terrainGraph is shared by every monster, so it ignores monsters since they will have moved by the time it's used
+type terrainGraph struct {
+	moverGraph
+}
+
+func (g *terrainGraph) Cost(from, to path.Pos) int {
+	return stepCost(Pos(from), Pos(to)) + g.level.terrainCost(Pos(to), true)
+}
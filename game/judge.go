@@ -0,0 +1,260 @@
+package game
+
+import "math"
+
+// Judgement is how close to the music a note was hit
+type Judgement int
+
+const (
+	// NoJudgement means the press didn't hit anything
+	NoJudgement Judgement = iota
+	// Perfect is right on the beat
+	Perfect
+	// Great is a little off
+	Great
+	// Good is noticeably off
+	Good
+	// Boo still counts, but only just
+	Boo
+	// Miss is a note that was never hit, or a hold that was let go too early
+	Miss
+	// NumJudgements is how many judgements there are
+	NumJudgements
+)
+
+var judgementNames = [NumJudgements]string{"", "Perfect", "Great", "Good", "Boo", "Miss"}
+
+func (j Judgement) String() string {
+	return judgementNames[j]
+}
+
+// judgeWindows are how many milliseconds early or late each judgement allows
+var judgeWindows = [NumJudgements]int{Perfect: 45, Great: 90, Good: 135, Boo: 180}
+
+const (
+	holdLeniency = 250  // Let go of a hold this many milliseconds early and still get it
+	battleLeadIn = 1000 // Time to read the first notes before they have to be hit
+	battleBPM    = 120  // Streams are 8th notes at this tempo, and it's the beat grid when there's no music
+)
+
+// Judge turns how early (negative) or late a press was into a judgement
+func Judge(offset int) Judgement {
+	if offset < 0 {
+		offset = -offset
+	}
+	for j := Perfect; j <= Boo; j++ {
+		if offset <= judgeWindows[j] {
+			return j
+		}
+	}
+	return Miss
+}
+
+// Judge is Judge with the character's passive. Boo stays the same so tighter windows don't lose notes.
+func (c *Character) Judge(offset int) Judgement {
+	scale := c.Passive.JudgeScale
+	if scale <= 0 {
+		return Judge(offset)
+	}
+	if offset < 0 {
+		offset = -offset
+	}
+	for j := Perfect; j < Boo; j++ {
+		if float64(offset) <= float64(judgeWindows[j])*scale {
+			return j
+		}
+	}
+	return Judge(offset)
+}
+
+// burstStart picks the first beat after the lead in, so bursts line up with the music
+func burstStart(now int, tempo Tempo) int {
+	beat := 60000 / tempo.BPM
+	beats := math.Ceil(float64(now+battleLeadIn-tempo.Offset) / beat)
+	return tempo.Offset + int(math.Round(beats*beat))
+}
+
+// HeldNote is a hold or roll that has been pressed but isn't finished yet
+type HeldNote struct {
+	Note
+	Judgement Judgement // How well the head was hit
+	Taps      int       // Rolls only
+}
+
+// Tempo is the BPM the burst was written at
+func (b *Burst) Tempo() int {
+	if b.BPM > 0 {
+		return b.BPM
+	}
+	return battleBPM
+}
+
+// At is the song position a note has to be hit at
+func (b *Burst) At(n Note) int {
+	return b.Start + n.Time
+}
+
+// Hits counts the notes you have to hit, so mines don't count
+func (b *Burst) Hits() int {
+	hits := 0
+	for _, n := range b.Notes {
+		if n.Typ != MineNote {
+			hits++
+		}
+	}
+	return hits + len(b.Held)
+}
+
+// Done is true once every note has been hit or missed
+func (b *Burst) Done() bool {
+	return len(b.Notes) == 0 && len(b.Held) == 0
+}
+
+// Press judges a key going down on a column at a song position
+func (level *Level) Press(c *Character, col, time int) Judgement {
+	b := c.Burst
+	level.CheckMisses(c, time)
+
+	// Keep tapping a roll until it's done
+	for i := range b.Held {
+		h := &b.Held[i]
+		if h.Column == col && h.Typ == RollNote {
+			h.Taps++
+			if h.Taps >= h.RollTaps() {
+				j := h.Judgement
+				b.Held = append(b.Held[:i], b.Held[i+1:]...)
+				b.hit(c, j, time)
+				return j
+			}
+			return NoJudgement
+		}
+	}
+
+	for i, n := range b.Notes {
+		offset := time - b.At(n)
+		if offset < -judgeWindows[Boo] {
+			break // Too early, and the rest of the notes are even later
+		}
+		if n.Column != col {
+			continue // Either half of a jump can be hit first
+		}
+		j := c.Judge(offset)
+		if n.Typ == MineNote {
+			// Mines only go off if you step on them close to the beat
+			if j <= Great {
+				b.Notes = append(b.Notes[:i], b.Notes[i+1:]...)
+				level.explodeMine(c)
+				return NoJudgement
+			}
+			continue
+		}
+		b.Notes = append(b.Notes[:i], b.Notes[i+1:]...)
+		b.LastOffset = offset
+		if n.Typ == TapNote {
+			b.hit(c, j, time)
+		} else {
+			b.Held = append(b.Held, HeldNote{Note: n, Judgement: j, Taps: 1})
+		}
+		return j
+	}
+	return NoJudgement
+}
+
+// Release judges a key going up on a column, which finishes holds
+func (level *Level) Release(c *Character, col, time int) Judgement {
+	b := c.Burst
+	level.CheckMisses(c, time)
+	for i, h := range b.Held {
+		if h.Column != col || h.Typ != HoldNote {
+			continue // Rolls don't care about letting go
+		}
+		b.Held = append(b.Held[:i], b.Held[i+1:]...)
+		if time >= b.At(h.Note)+h.Duration()-holdLeniency {
+			b.hit(c, h.Judgement, time)
+			return h.Judgement
+		}
+		// Let go too early
+		b.miss(c, time)
+		return Miss
+	}
+	return NoJudgement
+}
+
+// CheckMisses drops notes that can't be hit anymore, and finishes holds that were held long enough.
+// Returns how many notes were missed.
+func (level *Level) CheckMisses(c *Character, time int) int {
+	b := c.Burst
+	if b == nil {
+		return 0
+	}
+	missed := 0
+	for i := 0; i < len(b.Held); {
+		h := b.Held[i]
+		end := b.At(h.Note) + h.Duration()
+		switch {
+		case h.Typ == HoldNote && time >= end:
+			b.Held = append(b.Held[:i], b.Held[i+1:]...)
+			b.hit(c, h.Judgement, time)
+		case h.Typ == RollNote && time > end+judgeWindows[Boo]:
+			// Didn't tap fast enough
+			b.Held = append(b.Held[:i], b.Held[i+1:]...)
+			b.miss(c, time)
+			missed++
+		default:
+			i++
+		}
+	}
+	for len(b.Notes) > 0 && time-b.At(b.Notes[0]) > judgeWindows[Boo] {
+		n := b.Notes[0]
+		b.Notes = b.Notes[1:]
+		if n.Typ != MineNote { // Mines that go by were dodged
+			b.miss(c, time)
+			missed++
+		}
+	}
+	return missed
+}
+
+func (b *Burst) hit(c *Character, j Judgement, time int) {
+	c.Stamina--
+	b.Combo++ // Maintains note colour
+	b.Streak++
+	b.Turn.MaxStreak = max(b.Turn.MaxStreak, b.Streak)
+	if every := c.Passive.HealEvery; every > 0 && b.Streak%every == 0 {
+		c.Heal(1)
+	}
+	b.judge(j, time)
+	// Passed burst
+	if b.Done() {
+		b.Combo = 0
+	}
+}
+
+// Misses use up stamina, but don't change the combo so no damage is dealt
+func (b *Burst) miss(c *Character, time int) {
+	c.Stamina--
+	b.Streak = 0
+	b.judge(Miss, time)
+}
+
+func (b *Burst) judge(j Judgement, time int) {
+	b.Judgements[j]++
+	b.Turn.Judgements[j]++
+	b.LastJudgement = j
+	b.LastJudgedAt = time
+}
+
+func (level *Level) explodeMine(c *Character) {
+	if level.Practice {
+		level.Emit(Event{Typ: Mine, Target: c, Pos: c.Pos}) // No damage
+		return
+	}
+	c.Hitpoints -= mineDamage
+	if c.Burst != nil {
+		c.Burst.Turn.DamageTaken += mineDamage
+	}
+	level.Emit(Event{Typ: Mine, Target: c, Pos: c.Pos, Amount: mineDamage})
+	if c.Hitpoints <= 0 {
+		level.Kill(c)
+	}
+}
@@ -0,0 +1,135 @@
+package game
+
+import "strconv"
+
+// MessageKind is what a message is about, so the log can be filtered
+type MessageKind int
+
+const (
+	// CombatMessage is attacks, damage and battle results
+	CombatMessage MessageKind = iota
+	// LootMessage is picking things up and dropping them
+	LootMessage
+	// MovementMessage is doors, traps and taking portals
+	MovementMessage
+	// SystemMessage is anything about the game itself, like settings
+	SystemMessage
+	// NumMessageKinds is how many kinds there are
+	NumMessageKinds
+)
+
+// MessageKindNames are what the log viewer calls each kind
+var MessageKindNames = [NumMessageKinds]string{"Combat", "Loot", "Movement", "System"}
+
+// Oldest messages are forgotten past this, which is still hours of play
+const maxMessages = 2000
+
+// Message is one line of the log
+type Message struct {
+	Kind MessageKind `json:"kind"`
+	Turn int         `json:"turn"`
+	Text string      `json:"text"`
+}
+
+// MessageLog is every message of the run. It's shared by every level, so it survives taking portals.
+type MessageLog struct {
+	Messages []Message `json:"messages"`
+	Turn     int       `json:"turn"` // How many turns the player has finished
+}
+
+// Add logs a message on the current turn
+func (log *MessageLog) Add(kind MessageKind, text string) {
+	log.Messages = append(log.Messages, Message{kind, log.Turn, text})
+	if len(log.Messages) > maxMessages {
+		log.Messages = append(log.Messages[:0], log.Messages[len(log.Messages)-maxMessages:]...)
+	}
+}
+
+// Recent is up to the last n messages, oldest first
+func (log *MessageLog) Recent(n int) []Message {
+	return log.Messages[max(0, len(log.Messages)-n):]
+}
+
+// Filter is the messages of the kinds that are shown, oldest first
+func (log *MessageLog) Filter(shown [NumMessageKinds]bool) []Message {
+	filtered := make([]Message, 0, len(log.Messages))
+	for _, m := range log.Messages {
+		if shown[m.Kind] {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// Record logs the events the player should hear about
+func (log *MessageLog) Record(e Event) {
+	you := e.Who != nil && e.Who.Name == "You"
+	switch e.Typ {
+	case DoorOpen:
+		if you {
+			log.Add(MovementMessage, "You open the door.") // Monsters open doors out of sight
+		}
+	case Trap:
+		log.Add(MovementMessage, "You fall into a trap!")
+	case Portal:
+		if you {
+			log.Add(MovementMessage, "You go through the portal.")
+		} else {
+			log.Add(MovementMessage, "The "+e.Who.Name+" follows you through the portal.")
+		}
+	case PickUp:
+		log.Add(LootMessage, e.Who.Name+" picked up 1x "+e.Item.Name)
+	case Drop:
+		log.Add(LootMessage, e.Who.Name+" dropped 1x "+e.Item.Name)
+	case Attack:
+		if you {
+			log.Add(CombatMessage, "You attack the "+e.Target.Name+".")
+		} else {
+			log.Add(CombatMessage, "The "+e.Who.Name+" attacks you.")
+		}
+	case Damage:
+		if you {
+			log.Add(CombatMessage, "You hit the "+e.Target.Name+" for "+strconv.Itoa(e.Amount)+" damage.")
+		} else {
+			log.Add(CombatMessage, "The "+e.Who.Name+" hits you for "+strconv.Itoa(e.Amount)+" damage.")
+		}
+	case Kill:
+		if you {
+			log.Add(CombatMessage, "The "+e.Target.Name+" collapses!")
+		} else {
+			log.Add(CombatMessage, "You were slain by the "+e.Who.Name+"!")
+		}
+	case Mine:
+		switch {
+		case e.Amount == 0:
+			log.Add(CombatMessage, "A mine explodes!") // Practice mines don't hurt
+		case e.Target.Name == "You":
+			log.Add(CombatMessage, "A mine explodes! You take "+strconv.Itoa(e.Amount)+" damage.")
+		default:
+			log.Add(CombatMessage, "A mine explodes under the "+e.Target.Name+".")
+		}
+	case Experience:
+		log.Add(CombatMessage, "You gain "+strconv.Itoa(e.Amount)+" experience.")
+	case LevelUp:
+		log.Add(CombatMessage, "You reach level "+strconv.Itoa(e.Amount)+"!")
+	case TurnOver:
+		if you {
+			log.Add(CombatMessage, "Your attack: "+e.Result.String())
+		} else {
+			log.Add(CombatMessage, "The "+e.Who.Name+"'s attack: "+e.Result.String())
+		}
+	}
+}
+
+// AddMessage logs a message for the player to see
+func (level *Level) AddMessage(kind MessageKind, text string) {
+	level.log().Add(kind, text)
+}
+
+// Levels made on their own, like practice, keep their own log
+func (level *Level) log() *MessageLog {
+	if level.Log == nil {
+		level.Log = &MessageLog{}
+	}
+	return level.Log
+}
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestMessageLog(t *testing.T) {
+	log := &MessageLog{}
+	log.Add(CombatMessage, "You hit the Rat for 1 damage.")
+	log.Turn++
+	log.Add(LootMessage, "You picked up 1x Sword")
+	log.Add(MovementMessage, "You open the door.")
+
+	if got := log.Recent(2); len(got) != 2 || got[0].Text != "You picked up 1x Sword" || got[0].Turn != 1 {
+		t.Errorf("Expected the last two messages on turn 1, got %+v", got)
+	}
+	if got := log.Recent(10); len(got) != 3 {
+		t.Errorf("Expected every message when asking for more, got %d", len(got))
+	}
+
+	var shown [NumMessageKinds]bool
+	shown[CombatMessage] = true
+	shown[MovementMessage] = true
+	got := log.Filter(shown)
+	if len(got) != 2 || got[0].Kind != CombatMessage || got[1].Kind != MovementMessage {
+		t.Errorf("Expected only combat and movement, got %+v", got)
+	}
+
+	for i := 0; i < maxMessages; i++ {
+		log.Add(SystemMessage, strconv.Itoa(i))
+	}
+	if len(log.Messages) != maxMessages || log.Messages[0].Text != "0" {
+		t.Errorf("Expected the oldest messages to be forgotten, got %d starting with %q", len(log.Messages), log.Messages[0].Text)
+	}
+}
+
+func TestMessagesFollowThePlayer(t *testing.T) {
+	setupTestWorld(t)
+	game := NewGame(1)
+	first := game.CurrentLevel
+	first.AddMessage(SystemMessage, "Started")
+	if game.Levels["second"].Log != game.Log || first.Log != game.Log {
+		t.Fatal("Expected every level to share the game's log")
+	}
+
+	// Walking and opening doors take turns
+	delete(first.Monsters, Pos{1, 2}) // The rat would start a fight
+	first.Player.Speed = 1
+	game.resolveMovement(Pos{2, 1})
+	game.resolveMovement(Pos{3, 1})
+	last := game.Log.Messages[len(game.Log.Messages)-1]
+	if game.Log.Turn != 2 || last != (Message{MovementMessage, 1, "You open the door."}) {
+		t.Errorf("Expected the door to open on the second turn, got turn %d and %+v", game.Log.Turn, last)
+	}
+}
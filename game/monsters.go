@@ -13,10 +13,15 @@ const (
 	KeyPress
 )
 
+// How many milliseconds early or late monsters hit their notes
+const autoplaySloppiness = 60
+
 // Monster is an enemy entity
 type Monster struct {
 	Character
-	Typ MonsterInputType
+	Typ           MonsterInputType
+	FleeHitpoints int // Run away from the player at or below this many hitpoints
+	XPValue       int // Experience for slaying it
 }
 
 // NewRat spawns a slow monster
@@ -33,6 +38,7 @@ func NewRat(p Pos) *Monster {
 				Rune: 'R',
 			},
 			Hitpoints:    4,
+			MaxHitpoints: 4,
 			MaxStamina:   4,
 			Stamina:      4,
 			Speed:        1.5,
@@ -40,7 +46,12 @@ func NewRat(p Pos) *Monster {
 			SightRange:   10.0,
 			Items:        []*Item{NewBones(Pos{}), NewCredits(Pos{})},
 			PatternRNG:   rand.New(rand.NewSource(time.Now().UnixNano())),
+			Patterns:     ratWeights, // Simple stairs
+			Difficulty:   1,
+			Keys:         4,
 		},
+		FleeHitpoints: 1, // Cowardly
+		XPValue:       10,
 	}
 }
 
@@ -54,6 +65,7 @@ func NewSpider(p Pos) *Monster {
 				Rune: 'S',
 			},
 			Hitpoints:    12,
+			MaxHitpoints: 12,
 			MaxStamina:   8,
 			Stamina:      8,
 			Speed:        2.0,
@@ -61,80 +73,104 @@ func NewSpider(p Pos) *Monster {
 			SightRange:   10.0,
 			Items:        []*Item{NewCredits(Pos{}), NewPotion(Pos{})},
 			PatternRNG:   rand.New(rand.NewSource(time.Now().UnixNano())),
+			Patterns:     spiderWeights, // Lots of trills
+			Difficulty:   3,
+			Keys:         6, // Harder monsters use more columns
 		},
+		XPValue: 30,
 	}
 }
 
-// Update searches for player position
+// Update gains a tick of energy, and spends it stepping downhill on the level's distance map towards (or away from) the player
 func (m *Monster) Update(level *Level) {
-	m.ActionPoints += m.Speed
-	playerPos := level.Player.Pos
-	apInt := int(m.ActionPoints)
-	positions := level.astar(m.Pos, playerPos)
-	if len(positions) == 0 {
+	m.tick()
+	target, ok := level.target()
+	if !ok {
+		m.Pass() // Nobody's been here to chase
+		return
+	}
+	// Tests and the first turn might not have a map yet
+	if level.PlayerMap == nil || level.PlayerMap.Origin != target {
+		level.updateDistanceMaps()
+	}
+	dm := level.PlayerMap
+	if m.Hitpoints <= m.FleeHitpoints {
+		dm = level.fleeMap()
+	}
+	if _, ok := dm.Downhill(level, m.Pos); !ok {
 		// Nothing we can do, pass turn
 		m.Pass()
 		return
 	}
-	moveIndex := 1 // Move 1 position closer if we have a path, and we're not on top of the player (>1)
-	for i := 0; i < apInt; i++ {
-		if moveIndex < len(positions) {
-			m.Move(positions[moveIndex], level)
-			moveIndex++
-			m.ActionPoints--
+	for m.ActionPoints >= actionCost {
+		next, ok := dm.Downhill(level, m.Pos)
+		if !ok {
+			return // Arrived
 		}
+		m.Move(next, level)
+		m.ActionPoints -= actionCost
 	}
 }
 
-// Autoplay will automatically play the provided burst
+// Autoplay plays the burst in time with the music, a little sloppily to simulate a real player
 func (m *Monster) Autoplay(level *Level) {
-	// Pause before playing to simulate a real player
-	amt := time.Duration(50) // 400-500ms
-	time.Sleep(time.Millisecond * amt)
-	if level.LastEvent == Attack {
-		for {
-			// Wait random interval
-			amt := time.Duration(100 + rand.Intn(600)) // 100-700ms
-			time.Sleep(time.Millisecond * amt)
-			// Play note
-			if len(m.Burst.Notes) > 0 {
-				m.Stamina--
-				m.Typ = KeyPress
-				m.Burst.Notes = m.Burst.Notes[1:]
-				if len(m.Burst.Notes) == 0 {
-					//m.Stamina = m.MaxStamina
-					//level.LastEvent = Damage
-					//level.ResolveDamage()
-					return
-				}
-				if m.Stamina <= 0 {
-					//m.Stamina = m.MaxStamina
-					//level.LastEvent = Damage
-					//level.ResolveDamage()
-					return
-				}
+	if !level.Fighting() {
+		return
+	}
+	b := m.Burst
+	notes := append([]Note(nil), b.Notes...) // Press changes the burst as we go
+	for _, n := range notes {
+		if m.Stamina <= 0 {
+			return
+		}
+		// Monsters know where the mines are
+		if n.Typ == MineNote {
+			continue
+		}
+		level.waitUntil(b.At(n) + rand.Intn(2*autoplaySloppiness+1) - autoplaySloppiness)
+		if j := level.Press(&m.Character, n.Column, level.SongPosition()); n.Typ == TapNote && j != NoJudgement {
+			m.Typ = KeyPress
+		}
+		switch n.Typ {
+		case HoldNote:
+			level.waitUntil(b.At(n) + n.Duration())
+			level.Release(&m.Character, n.Column, level.SongPosition())
+			m.Typ = KeyPress
+		case RollNote:
+			for i := 1; i < n.RollTaps(); i++ {
+				level.waitUntil(b.At(n) + i*rollInterval)
+				level.Press(&m.Character, n.Column, level.SongPosition())
 			}
+			m.Typ = KeyPress
 		}
 	}
 }
 
 // Pass prevents monsters from building up large sums of action points
 func (m *Monster) Pass() {
-	m.ActionPoints -= m.Speed
+	m.ActionPoints -= m.EffectiveSpeed()
 }
 
 // Move moves towards the player position
 func (m *Monster) Move(to Pos, level *Level) {
-	if level.LastEvent != Attack && level.LastEvent != Damage {
+	if !level.Fighting() {
+		// Opening a door uses up the step
+		if level.Map[to.Y][to.X].OverlayRune == ClosedDoor {
+			level.Map[to.Y][to.X].OverlayRune = OpenDoor
+			level.terrainChanged()
+			level.Emit(Event{Typ: DoorOpen, Who: &m.Character, Pos: to})
+			return
+		}
 		_, exists := level.Monsters[to] // Is there something at the position we want to move to?
-		if !exists && to != level.Player.Pos {
+		if !exists && (level.Offscreen || to != level.Player.Pos) {
 			delete(level.Monsters, m.Pos) // Delete current, add new
 			level.Monsters[to] = m
 			m.Pos = to
+			level.Emit(Event{Typ: Move, Who: &m.Character, Pos: to})
 			return
 		}
 		// If there is another monster in the way, don't attack the player
-		if to == level.Player.Pos {
+		if to == level.Player.Pos && !level.Offscreen {
 			level.Attack(&m.Character, &level.Player.Character)
 			// Run blocking events in a seperate goroutine
 			go func() {
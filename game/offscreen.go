@@ -0,0 +1,111 @@
+package game
+
+import "github.com/maxproske/lyns-rhythm-dungeon/game/path"
+
+// Levels the player has left are only caught up on when they come back, and only this many ticks of it.
+// Past that the monsters have long since gathered where the player left.
+const catchUpTicks = 200
+
+// enterLevel makes a level the one the player is on, and every other level off screen
+func (game *Game) enterLevel(level *Level) {
+	game.CurrentLevel = level
+	for _, l := range game.Levels {
+		l.Offscreen = l != level
+	}
+	level.Offscreen = false // Tests make levels that aren't in Levels
+}
+
+// target is where the monsters are heading: the player, or where they were last seen if they've left.
+// Monsters on levels the player has never been to have nowhere to go.
+func (level *Level) target() (Pos, bool) {
+	if !level.Offscreen {
+		return level.Player.Pos, true
+	}
+	if level.LastSeen == nil {
+		return Pos{}, false
+	}
+	return *level.LastSeen, true
+}
+
+// monstersTick runs one tick for the monsters, without the player
+func (level *Level) monstersTick() {
+	if len(level.Monsters) == 0 {
+		return // Nobody needs a map
+	}
+	level.updateDistanceMaps() // Once per tick instead of once per monster
+	for _, m := range level.sortedMonsters() {
+		if level.Monsters[m.Pos] != m {
+			continue // Slain earlier this tick
+		}
+		m.Update(level)
+	}
+}
+
+// catchUp runs the ticks a level missed while the player was away, up to the budget
+func (game *Game) catchUp(level *Level) {
+	ticks := min(game.Ticks-level.LeftAt, catchUpTicks)
+	for i := 0; i < ticks; i++ {
+		level.monstersTick()
+	}
+	level.LeftAt = game.Ticks
+}
+
+// followers are the monsters next to the player that are up for a fight, so they'd follow them anywhere
+func (level *Level) followers() []*Monster {
+	var followers []*Monster
+	for _, m := range level.sortedMonsters() {
+		if m.Hitpoints <= m.FleeHitpoints {
+			continue // Glad to see them go
+		}
+		for _, offset := range dirOffsets[:level.numDirs()] {
+			if m.Pos == (Pos{level.Player.Pos.X + offset.X, level.Player.Pos.Y + offset.Y}) && canStep(level, m.Pos, level.Player.Pos) {
+				followers = append(followers, m)
+				break
+			}
+		}
+	}
+	return followers
+}
+
+// takePortal moves the player to another level. Whatever was next to them comes along if there's room.
+func (game *Game) takePortal(portal Pos, to *LevelPos) {
+	from := game.CurrentLevel
+	followers := from.followers()
+	from.LastSeen = &portal
+	from.LeftAt = game.Ticks
+
+	level := to.Level
+	game.catchUp(level) // While it's still off screen, so nobody attacks from the other side
+	game.enterLevel(level)
+	level.Player.Pos = to.Pos
+	level.newTurn() // Whatever happened here last time is old news
+	if m, ok := level.Monsters[to.Pos]; ok {
+		// Caught up monsters don't know where the player comes out
+		delete(level.Monsters, m.Pos)
+		if !level.placeNear(m, to.Pos) {
+			level.Monsters[m.Pos] = m // Boxed in, so the player will have to share
+		}
+	}
+	level.Emit(Event{Typ: Portal, Who: &level.Player.Character, Pos: to.Pos})
+
+	for _, m := range followers {
+		delete(from.Monsters, m.Pos)
+		if !level.placeNear(m, to.Pos) {
+			from.Monsters[m.Pos] = m // Nowhere to stand, they stay behind
+			continue
+		}
+		level.Emit(Event{Typ: Portal, Who: &m.Character, Pos: m.Pos})
+	}
+	level.lineOfSight()
+}
+
+// placeNear puts a monster on the first free tile next to pos, if there is one
+func (level *Level) placeNear(m *Monster, pos Pos) bool {
+	free := (&walkGraph{level: level}).Neighbors(path.Pos(pos))
+	if len(free) == 0 {
+		return false
+	}
+	m.Pos = Pos(free[0])
+	level.Monsters[m.Pos] = m
+	return true
+}
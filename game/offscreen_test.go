@@ -0,0 +1,96 @@
+package game
+
+import "testing"
+
+// Two levels with portals at (8,7) leading to each other's middle, and the player next to the one on the first
+func offscreenTestGame() (*Game, *Level, *Level) {
+	game := createTestGame()
+	first := game.CurrentLevel
+	second := createTestLevel()
+	second.Player = first.Player // Everyone shares the player
+	second.Log = first.Log
+	game.Levels["second"] = second
+	first.Portals[Pos{8, 7}] = &LevelPos{second, Pos{7, 7}}
+	second.Portals[Pos{8, 7}] = &LevelPos{first, Pos{7, 7}}
+	game.enterLevel(first)
+	return game, first, second
+}
+
+func TestOffscreenCatchUp(t *testing.T) {
+	game, first, second := offscreenTestGame()
+	rat := NewRat(Pos{0, 0})
+	rat.AddEffect(Effect{Kind: Haste, Ticks: 1000}) // Counts the ticks it gets
+	first.Monsters[rat.Pos] = rat
+	stranger := NewRat(Pos{14, 14})
+	second.Monsters[stranger.Pos] = stranger
+
+	game.Move(Pos{8, 7})
+	if game.CurrentLevel != second || !first.Offscreen || second.Offscreen {
+		t.Fatal("Expected the player to be on the second level")
+	}
+	if first.LastSeen == nil || *first.LastSeen != (Pos{8, 7}) {
+		t.Errorf("Expected the first level to remember the portal, got %v", first.LastSeen)
+	}
+	if stranger.Pos != (Pos{14, 14}) {
+		t.Errorf("Expected monsters that never saw the player to stay put, got %v", stranger.Pos)
+	}
+
+	// Nothing happens on the first level while the player's away
+	for i := 0; i < 5; i++ {
+		game.endPlayerTurn()
+	}
+	if game.Ticks != 5 || rat.Pos != (Pos{0, 0}) || rat.Effects[0].Ticks != 1000 {
+		t.Errorf("Expected the first level to wait for the player, got %d ticks and the rat at %v", game.Ticks, rat.Pos)
+	}
+
+	// Coming back catches it up, with the rat heading for where the player left
+	game.Move(Pos{8, 7})
+	if rat.Effects[0].Ticks != 995 || first.LeftAt != 5 {
+		t.Errorf("Expected 5 ticks of catching up, got %d", 1000-rat.Effects[0].Ticks)
+	}
+	if rat.Pos == (Pos{0, 0}) || rat.Pos == first.Player.Pos || first.Fighting() {
+		t.Errorf("Expected the rat to move without starting a fight or standing on the player, got %v", rat.Pos)
+	}
+}
+
+func TestOffscreenCatchUpBudget(t *testing.T) {
+	game, first, _ := offscreenTestGame()
+	rat := NewRat(Pos{0, 0})
+	rat.AddEffect(Effect{Kind: Haste, Ticks: 1000})
+	first.Monsters[rat.Pos] = rat
+
+	game.Move(Pos{8, 7})
+	game.Ticks += 10000 // A long time away
+	game.Move(Pos{8, 7})
+	if ticks := 1000 - rat.Effects[0].Ticks; ticks != catchUpTicks {
+		t.Errorf("Expected %d ticks of catching up, got %d", catchUpTicks, ticks)
+	}
+	if d := abs(rat.Pos.X-8) + abs(rat.Pos.Y-7); d > 1 {
+		t.Errorf("Expected the rat to have reached the portal, got %v", rat.Pos)
+	}
+}
+
+func TestMonstersFollowThroughPortals(t *testing.T) {
+	game, first, second := offscreenTestGame()
+	rat := NewRat(Pos{7, 6})
+	first.Monsters[rat.Pos] = rat
+	coward := NewRat(Pos{6, 7})
+	coward.Hitpoints = coward.FleeHitpoints
+	first.Monsters[coward.Pos] = coward
+	far := NewRat(Pos{0, 0})
+	first.Monsters[far.Pos] = far
+
+	game.Move(Pos{8, 7})
+	if second.Monsters[rat.Pos] != rat || first.Monsters[Pos{7, 6}] != nil {
+		t.Fatalf("Expected the rat to follow the player, got %v", second.Monsters)
+	}
+	if d := abs(rat.Pos.X-7) + abs(rat.Pos.Y-7); d != 1 {
+		t.Errorf("Expected the rat next to the player, got %v", rat.Pos)
+	}
+	if first.Monsters[coward.Pos] != coward || first.Monsters[far.Pos] != far || len(second.Monsters) != 1 {
+		t.Error("Expected fleeing and far away monsters to stay behind")
+	}
+	if texts := messageTexts(second); texts[len(texts)-1] != "The Rat follows you through the portal." {
+		t.Errorf("Expected a message about the rat following, got %v", texts)
+	}
+}
@@ -0,0 +1,77 @@
+package path
+
+// Pack our tree into contiguous memory
+type heapNode[T any] struct {
+	value    T
+	priority int
+}
+
+// Heap is a binary min-heap, so the lowest priority comes out first
+// Parent:		(i-1)/2
+// Left child:	i*2+1
+// Right child: i*2+2
+type Heap[T any] struct {
+	nodes []heapNode[T]
+}
+
+// NewHeap makes a heap with room for size values before it has to grow
+func NewHeap[T any](size int) *Heap[T] {
+	return &Heap[T]{make([]heapNode[T], 0, size)}
+}
+
+// Len is how many values are in the heap
+func (h *Heap[T]) Len() int {
+	return len(h.nodes)
+}
+
+// Push adds a value to the heap
+func (h *Heap[T]) Push(value T, priority int) {
+	// Put at end of array to add to the heap (by shape property, add as right child)
+	h.nodes = append(h.nodes, heapNode[T]{value, priority})
+	i := len(h.nodes) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if h.nodes[parent].priority <= h.nodes[i].priority {
+			break
+		}
+		h.swap(i, parent)
+		i = parent
+	}
+}
+
+// Pop removes the value with the lowest priority, or returns false if the heap is empty
+func (h *Heap[T]) Pop() (T, bool) {
+	if len(h.nodes) == 0 {
+		var zero T
+		return zero, false
+	}
+
+	result := h.nodes[0].value // Always return the root
+	// Replace root with rightmost leaf node
+	last := len(h.nodes) - 1
+	h.nodes[0] = h.nodes[last]
+	h.nodes = h.nodes[:last]
+
+	// New root may not be in the right place, loop down the tree
+	i := 0
+	for {
+		smallest := i
+		left, right := i*2+1, i*2+2
+		if left < len(h.nodes) && h.nodes[left].priority < h.nodes[smallest].priority {
+			smallest = left
+		}
+		if right < len(h.nodes) && h.nodes[right].priority < h.nodes[smallest].priority {
+			smallest = right
+		}
+		if smallest == i {
+			break // We have a valid heap again
+		}
+		h.swap(i, smallest)
+		i = smallest
+	}
+	return result, true
+}
+
+func (h *Heap[T]) swap(i, j int) {
+	h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i]
+}
@@ -0,0 +1,68 @@
+package path
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestHeapOperations(t *testing.T) {
+	h := NewHeap[string](0)
+
+	// Test empty heap
+	if h.Len() != 0 {
+		t.Error("New heap should be empty")
+	}
+	if _, ok := h.Pop(); ok {
+		t.Error("Popping an empty heap should return false")
+	}
+
+	h.Push("five", 5)
+	h.Push("three", 3)
+	h.Push("eight", 8)
+	if h.Len() != 3 {
+		t.Errorf("Expected 3 values, got %d", h.Len())
+	}
+
+	for _, expected := range []string{"three", "five", "eight"} {
+		value, ok := h.Pop()
+		if !ok || value != expected {
+			t.Errorf("Expected %s, got %s", expected, value)
+		}
+	}
+	if h.Len() != 0 {
+		t.Error("Heap should be empty after popping all values")
+	}
+}
+
+func TestHeapOrder(t *testing.T) {
+	h := NewHeap[int](8)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		p := r.Intn(100)
+		h.Push(p, p)
+	}
+	last := -1
+	for h.Len() > 0 {
+		p, _ := h.Pop()
+		if p < last {
+			t.Fatalf("Popped %d after %d", p, last)
+		}
+		last = p
+	}
+}
+
+func TestHeapPositions(t *testing.T) {
+	h := NewHeap[Pos](0)
+	h.Push(Pos{1, 1}, 5)
+	h.Push(Pos{2, 2}, 3) // Higher priority (lower number)
+
+	if p, ok := h.Pop(); !ok || p != (Pos{2, 2}) {
+		t.Errorf("Expected pos {2,2}, got %v", p)
+	}
+	if p, ok := h.Pop(); !ok || p != (Pos{1, 1}) {
+		t.Errorf("Expected pos {1,1}, got %v", p)
+	}
+	if p, ok := h.Pop(); ok || p != (Pos{}) {
+		t.Error("Popping an empty heap should return the zero value Pos")
+	}
+}
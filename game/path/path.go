@@ -0,0 +1,140 @@
+// Package path has pathfinding that works on any grid, so levels, AI and map tools can share it.
+package path
+
+import "math"
+
+// Unreachable is the distance to tiles that can't be reached
+const Unreachable = math.MaxInt32
+
+// Pos is a tile on a grid
+type Pos struct {
+	X, Y int
+}
+
+// Path is the result of a pathfinding search
+type Path struct {
+	Steps []Pos // Includes the start and goal
+	Cost  int
+}
+
+// Graph is anything we can search. Neighbors may reuse the slice it returns on the next call,
+// and should be symmetric (if b is a neighbour of a, a is a neighbour of b).
+type Graph interface {
+	Neighbors(pos Pos) []Pos
+	Cost(from, to Pos) int // Cost of a single step between neighbours
+}
+
+// Heuristic estimates the cost between two positions. It must never be larger than the real cost.
+type Heuristic func(a, b Pos) int
+
+// Manhattan distance (how many nodes in a straight line) for four-way movement
+func Manhattan(straight int) Heuristic {
+	return func(a, b Pos) int {
+		return straight * (abs(a.X-b.X) + abs(a.Y-b.Y))
+	}
+}
+
+// Octile distance (diagonal steps first, then straight) for eight-way movement
+func Octile(straight, diagonal int) Heuristic {
+	return func(a, b Pos) int {
+		xDist := abs(a.X - b.X)
+		yDist := abs(a.Y - b.Y)
+		return straight*(xDist+yDist) + (diagonal-2*straight)*min(xDist, yDist)
+	}
+}
+
+// AStar finds the cheapest path from start to goal, or an empty path if there is none
+func AStar(g Graph, start, goal Pos, h Heuristic) Path {
+	frontier := NewHeap[Pos](8) // Start at 8 instead of growing/shrinking frontier
+	frontier.Push(start, 0)
+
+	cameFrom := make(map[Pos]Pos) // Keep a map of where we came from
+	cameFrom[start] = start       // Start didn't come from anywhere
+
+	costSoFar := make(map[Pos]int) // Read to handle varying costs of travel
+	costSoFar[start] = 0
+
+	for frontier.Len() > 0 {
+		current, _ := frontier.Pop() // Get starting node from the beginning of it
+
+		if current == goal {
+			// We've found our path
+			steps := make([]Pos, 0)
+			for p := current; p != start; p = cameFrom[p] {
+				steps = append(steps, p)
+			}
+			steps = append(steps, start)
+
+			// Reverse slice
+			for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+				steps[i], steps[j] = steps[j], steps[i]
+			}
+			return Path{steps, costSoFar[goal]}
+		}
+
+		for _, next := range g.Neighbors(current) {
+			newCost := costSoFar[current] + g.Cost(current, next)
+			oldCost, exists := costSoFar[next]
+			if !exists || newCost < oldCost {
+				costSoFar[next] = newCost
+				frontier.Push(next, newCost+h(next, goal)) // Stick a new priority onto the queue
+				cameFrom[next] = current                   // Update where we came from
+			}
+		}
+	}
+
+	return Path{}
+}
+
+// BFS searches outwards from start, and returns the first position found is true for
+func BFS(g Graph, start Pos, found func(Pos) bool) (Pos, bool) {
+	frontier := make([]Pos, 0, 8)      // Start at 8 instead of growing/shrinking frontier
+	frontier = append(frontier, start) // Put in front of queue
+	visited := make(map[Pos]bool)
+	visited[start] = true // We have already visited start, we are on it
+
+	for len(frontier) > 0 {
+		current := frontier[0]
+		if found(current) {
+			return current, true
+		}
+
+		frontier = frontier[1:] // But first
+		for _, next := range g.Neighbors(current) {
+			// Check if it is already visited
+			if !visited[next] {
+				frontier = append(frontier, next)
+				visited[next] = true
+			}
+		}
+	}
+	return Pos{}, false
+}
+
+// Dijkstra fills in dist[y][x] with the cheapest cost of reaching a seed.
+// Seeds need their starting cost filled in, and everything else should be Unreachable.
+// It searches backwards, so Cost is asked for the step from each tile towards the seeds.
+func Dijkstra(g Graph, dist [][]int, seeds []Pos) {
+	frontier := NewHeap[Pos](len(seeds))
+	for _, seed := range seeds {
+		frontier.Push(seed, dist[seed.Y][seed.X])
+	}
+
+	for frontier.Len() > 0 {
+		current, _ := frontier.Pop()
+		for _, next := range g.Neighbors(current) {
+			newCost := dist[current.Y][current.X] + g.Cost(next, current)
+			if newCost < dist[next.Y][next.X] {
+				dist[next.Y][next.X] = newCost
+				frontier.Push(next, newCost)
+			}
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
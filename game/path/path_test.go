@@ -0,0 +1,114 @@
+package path
+
+import "testing"
+
+// Grid where '#' is a wall and digits cost extra to enter
+type testGrid struct {
+	rows []string
+	buf  []Pos
+}
+
+func (g *testGrid) Neighbors(pos Pos) []Pos {
+	g.buf = g.buf[:0]
+	for _, dir := range []Pos{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		next := Pos{pos.X + dir.X, pos.Y + dir.Y}
+		if next.Y >= 0 && next.Y < len(g.rows) && next.X >= 0 && next.X < len(g.rows[next.Y]) && g.rows[next.Y][next.X] != '#' {
+			g.buf = append(g.buf, next)
+		}
+	}
+	return g.buf
+}
+
+func (g *testGrid) Cost(from, to Pos) int {
+	c := g.rows[to.Y][to.X]
+	if c >= '0' && c <= '9' {
+		return 1 + int(c-'0')
+	}
+	return 1
+}
+
+func newTestGrid() *testGrid {
+	return &testGrid{rows: []string{
+		".....",
+		".###.",
+		"..9..",
+		".###.",
+		".....",
+	}}
+}
+
+func TestAStar(t *testing.T) {
+	g := newTestGrid()
+	p := AStar(g, Pos{0, 2}, Pos{4, 2}, Manhattan(1))
+	if len(p.Steps) == 0 {
+		t.Fatal("Expected a path")
+	}
+	if p.Steps[0] != (Pos{0, 2}) || p.Steps[len(p.Steps)-1] != (Pos{4, 2}) {
+		t.Error("Path should include start and goal")
+	}
+	// Going around (8 steps) is cheaper than through the 9 (2+10+2)
+	if p.Cost != 8 {
+		t.Errorf("Expected cost 8, got %d", p.Cost)
+	}
+	for _, step := range p.Steps {
+		if g.rows[step.Y][step.X] == '9' {
+			t.Error("Path should go around the expensive tile")
+		}
+	}
+
+	// No path
+	g.rows = []string{"..#..", "..#..", "..#..", "..#..", "..#.."}
+	if p := AStar(g, Pos{0, 2}, Pos{4, 2}, Manhattan(1)); len(p.Steps) != 0 {
+		t.Error("Should not find a path through walls")
+	}
+}
+
+func TestBFS(t *testing.T) {
+	g := newTestGrid()
+	pos, found := BFS(g, Pos{0, 0}, func(p Pos) bool { return g.rows[p.Y][p.X] == '9' })
+	if !found || pos != (Pos{2, 2}) {
+		t.Errorf("Expected to find {2,2}, got %v", pos)
+	}
+	if _, found := BFS(g, Pos{0, 0}, func(p Pos) bool { return false }); found {
+		t.Error("Should not find anything")
+	}
+}
+
+func TestDijkstra(t *testing.T) {
+	g := newTestGrid()
+	dist := make([][]int, len(g.rows))
+	for y := range dist {
+		dist[y] = make([]int, len(g.rows[y]))
+		for x := range dist[y] {
+			dist[y][x] = Unreachable
+		}
+	}
+	dist[0][0] = 0
+	Dijkstra(g, dist, []Pos{{0, 0}})
+
+	if dist[1][1] != Unreachable {
+		t.Error("Walls should stay unreachable")
+	}
+	// Every tile should agree with a*
+	for y, row := range g.rows {
+		for x := range row {
+			if row[x] == '#' {
+				continue
+			}
+			// Dijkstra measures towards the seed, so search from the tile to it
+			p := AStar(g, Pos{x, y}, Pos{0, 0}, Manhattan(1))
+			if dist[y][x] != p.Cost {
+				t.Errorf("Distance at {%d,%d} is %d, a* says %d", x, y, dist[y][x], p.Cost)
+			}
+		}
+	}
+}
+
+func TestHeuristics(t *testing.T) {
+	if h := Manhattan(10)(Pos{0, 0}, Pos{3, 4}); h != 70 {
+		t.Errorf("Expected Manhattan 70, got %d", h)
+	}
+	if h := Octile(10, 14)(Pos{0, 0}, Pos{3, 4}); h != 3*14+10 {
+		t.Errorf("Expected octile %d, got %d", 3*14+10, h)
+	}
+}
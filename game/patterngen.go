@@ -0,0 +1,176 @@
+package game
+
+// PatternType is a named shape of notes, borrowed from dance game charting
+type PatternType int
+
+const (
+	// StreamPattern is random notes that never repeat a column
+	StreamPattern PatternType = iota
+	// JumpPattern hits two columns at the same time
+	JumpPattern
+	// StairPattern runs across the columns in order, like LDUR
+	StairPattern
+	// TrillPattern alternates between two columns
+	TrillPattern
+	// JackPattern hits the same column over and over
+	JackPattern
+	// CandlePattern goes from up to down (or down to up) with a side note in between
+	CandlePattern
+	// CrossoverPattern goes from one side to the other through the middle, so your feet cross
+	CrossoverPattern
+	numPatternTypes
+)
+
+var patternNames = [numPatternTypes]string{"stream", "jumps", "stairs", "trills", "jacks", "candles", "crossovers"}
+
+func (typ PatternType) String() string {
+	return patternNames[typ]
+}
+
+// PatternWeights is how likely a character is to throw each pattern type.
+// An array instead of a map so picking from it is deterministic.
+type PatternWeights [numPatternTypes]int
+
+// Per monster pattern styles
+var (
+	ratWeights    = PatternWeights{StreamPattern: 2, StairPattern: 6, JackPattern: 1}
+	spiderWeights = PatternWeights{StreamPattern: 2, TrillPattern: 6, JumpPattern: 1, CrossoverPattern: 1}
+)
+
+// Columns on a 4-panel pad
+const (
+	colLeft = iota
+	colDown
+	colUp
+	colRight
+)
+
+// Candles and crossovers only make sense on a 4-panel layout, so they're listed out
+var (
+	candles    = [][]int{{colUp, colLeft, colDown}, {colDown, colLeft, colUp}, {colUp, colRight, colDown}, {colDown, colRight, colUp}}
+	crossovers = [][]int{{colLeft, colDown, colRight}, {colLeft, colUp, colRight}, {colRight, colDown, colLeft}, {colRight, colUp, colLeft}}
+)
+
+// Difficulty is clamped to this range
+const (
+	minDifficulty = 1
+	maxDifficulty = 10
+)
+
+// patternSpacing gets faster with difficulty, down to half of a plain stream
+func patternSpacing(difficulty int) int {
+	return streamSpacing * 10 / (9 + difficulty)
+}
+
+// patternChunk is how many rows a pattern runs for before picking the next one
+func patternChunk(difficulty int) int {
+	return 3 + difficulty/2
+}
+
+// pickPattern chooses a pattern type from the weights
+func (c *Character) pickPattern(weights PatternWeights) PatternType {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		return StreamPattern
+	}
+	r := c.PatternRNG.Intn(total)
+	for typ, w := range weights {
+		if r < w {
+			return PatternType(typ)
+		}
+		r -= w
+	}
+	return StreamPattern
+}
+
+// patternRows generates rows of columns for a pattern, where each row is hit at the same time
+func (c *Character) patternRows(typ PatternType, rows, keys int) [][]int {
+	rng := c.PatternRNG
+	// Candles and crossovers need a dance pad
+	if keys != 4 && (typ == CandlePattern || typ == CrossoverPattern) {
+		typ = StreamPattern
+	}
+	out := make([][]int, 0, rows)
+	switch typ {
+	case JumpPattern:
+		for i := 0; i < rows; i++ {
+			a := rng.Intn(keys)
+			b := (a + 1 + rng.Intn(keys-1)) % keys // Never the same column twice
+			out = append(out, []int{a, b})
+		}
+	case StairPattern:
+		col := rng.Intn(keys)
+		dir := 1
+		if rng.Intn(2) == 0 {
+			dir = -1
+		}
+		for i := 0; i < rows; i++ {
+			out = append(out, []int{col})
+			col = (col + dir + keys) % keys // Wrap around to keep going
+		}
+	case TrillPattern:
+		a := rng.Intn(keys)
+		b := (a + 1 + rng.Intn(keys-1)) % keys
+		for i := 0; i < rows; i++ {
+			out = append(out, []int{a})
+			a, b = b, a
+		}
+	case JackPattern:
+		col := rng.Intn(keys)
+		for i := 0; i < rows; i++ {
+			out = append(out, []int{col})
+		}
+	case CandlePattern, CrossoverPattern:
+		shapes := candles
+		if typ == CrossoverPattern {
+			shapes = crossovers
+		}
+		for len(out) < rows {
+			for _, col := range shapes[rng.Intn(len(shapes))] {
+				out = append(out, []int{col})
+			}
+		}
+		out = out[:rows]
+	default:
+		last := -1
+		for i := 0; i < rows; i++ {
+			col := rng.Intn(keys)
+			for col == last {
+				col = rng.Intn(keys)
+			}
+			out = append(out, []int{col})
+			last = col
+		}
+	}
+	return out
+}
+
+// MakePatterns strings together patterns picked by weight until there are length notes to hit.
+// Difficulty makes the notes closer together and the patterns longer.
+func (c *Character) MakePatterns(length int, weights PatternWeights, difficulty int) []Note {
+	return c.makePatterns(length, weights, difficulty, c.KeyMode())
+}
+
+func (c *Character) makePatterns(length int, weights PatternWeights, difficulty int, keys int) []Note {
+	difficulty = min(max(difficulty, minDifficulty), maxDifficulty)
+	spacing := patternSpacing(difficulty)
+	notes := make([]Note, 0, length)
+	time := 0
+	for len(notes) < length {
+		typ := c.pickPattern(weights)
+		for _, row := range c.patternRows(typ, patternChunk(difficulty), keys) {
+			for _, col := range row {
+				if len(notes) == length {
+					break // A jump might get cut in half at the end
+				}
+				notes = append(notes, Note{Column: col, Typ: TapNote, Time: time})
+			}
+			time += spacing
+		}
+		time += spacing // Breathe between patterns
+	}
+	return notes
+}
@@ -0,0 +1,242 @@
+package game
+
+import (
+	mrand "math/rand"
+	"testing"
+)
+
+func newPatternCharacter(seed int64) *Character {
+	return &Character{PatternRNG: mrand.New(mrand.NewSource(seed))}
+}
+
+func TestPatternRows(t *testing.T) {
+	isShape := func(shapes [][]int, cols []int) bool {
+		for _, shape := range shapes {
+			match := true
+			for i := range cols {
+				match = match && shape[i] == cols[i]
+			}
+			if match {
+				return true
+			}
+		}
+		return false
+	}
+
+	for seed := int64(0); seed < 200; seed++ {
+		c := newPatternCharacter(seed)
+		for typ := StreamPattern; typ < numPatternTypes; typ++ {
+			rows := c.patternRows(typ, 6, NumKeys)
+			if len(rows) != 6 {
+				t.Fatalf("%v: expected 6 rows, got %d", typ, len(rows))
+			}
+			cols := make([]int, 0, len(rows))
+			for _, row := range rows {
+				for _, col := range row {
+					if col < 0 || col >= NumKeys {
+						t.Fatalf("%v: column %d out of range", typ, col)
+					}
+				}
+				if typ == JumpPattern {
+					if len(row) != 2 || row[0] == row[1] {
+						t.Errorf("%v: expected two different columns, got %v", typ, row)
+					}
+					continue
+				}
+				if len(row) != 1 {
+					t.Fatalf("%v: expected single notes, got %v", typ, row)
+				}
+				cols = append(cols, row[0])
+			}
+
+			switch typ {
+			case StreamPattern:
+				for i := 1; i < len(cols); i++ {
+					if cols[i] == cols[i-1] {
+						t.Errorf("%v: repeated column in %v", typ, cols)
+					}
+				}
+			case StairPattern:
+				dir := (cols[1] - cols[0] + NumKeys) % NumKeys
+				if dir != 1 && dir != NumKeys-1 {
+					t.Errorf("%v: not a stair %v", typ, cols)
+				}
+				for i := 1; i < len(cols); i++ {
+					if (cols[i]-cols[i-1]+NumKeys)%NumKeys != dir {
+						t.Errorf("%v: stair changed direction %v", typ, cols)
+					}
+				}
+			case TrillPattern:
+				for i := range cols {
+					if cols[i] != cols[i%2] || cols[0] == cols[1] {
+						t.Errorf("%v: not a trill %v", typ, cols)
+					}
+				}
+			case JackPattern:
+				for i := range cols {
+					if cols[i] != cols[0] {
+						t.Errorf("%v: not a jack %v", typ, cols)
+					}
+				}
+			case CandlePattern, CrossoverPattern:
+				shapes := candles
+				if typ == CrossoverPattern {
+					shapes = crossovers
+				}
+				for i := 0; i < len(cols); i += 3 {
+					if !isShape(shapes, cols[i:i+3]) {
+						t.Errorf("%v: unexpected shape %v", typ, cols[i:i+3])
+					}
+				}
+			}
+		}
+	}
+
+	// Streams, trills and stairs should use every column about as often as each other
+	for _, typ := range []PatternType{StreamPattern, TrillPattern, StairPattern, JumpPattern} {
+		c := newPatternCharacter(7)
+		counts := make([]int, NumKeys)
+		total := 0
+		for i := 0; i < 2000; i++ {
+			for _, row := range c.patternRows(typ, 4, NumKeys) {
+				for _, col := range row {
+					counts[col]++
+					total++
+				}
+			}
+		}
+		for col, count := range counts {
+			if share := float64(count) / float64(total); share < 0.2 || share > 0.3 {
+				t.Errorf("%v: column %d got %.2f of notes, expected about 0.25", typ, col, share)
+			}
+		}
+	}
+}
+
+func TestPickPattern(t *testing.T) {
+	c := newPatternCharacter(1)
+	counts := PatternWeights{}
+	const picks = 10000
+	for i := 0; i < picks; i++ {
+		counts[c.pickPattern(spiderWeights)]++
+	}
+	total := 0
+	for _, w := range spiderWeights {
+		total += w
+	}
+	for typ, w := range spiderWeights {
+		expected := float64(w) / float64(total)
+		actual := float64(counts[typ]) / picks
+		if actual < expected-0.03 || actual > expected+0.03 {
+			t.Errorf("%v: picked %.2f of the time, expected %.2f", PatternType(typ), actual, expected)
+		}
+	}
+	if c.pickPattern(PatternWeights{}) != StreamPattern {
+		t.Error("Expected empty weights to fall back to streams")
+	}
+}
+
+func TestMakePatterns(t *testing.T) {
+	// Same seed, same patterns
+	a := newPatternCharacter(42).MakePatterns(20, spiderWeights, 3)
+	b := newPatternCharacter(42).MakePatterns(20, spiderWeights, 3)
+	if len(a) != 20 || len(b) != 20 {
+		t.Fatalf("Expected 20 notes, got %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Patterns differ at note %d: %v vs %v", i, a[i], b[i])
+		}
+	}
+
+	// Notes never go back in time, and harder patterns are faster
+	lastTime := func(notes []Note) int {
+		for i := 1; i < len(notes); i++ {
+			if notes[i].Time < notes[i-1].Time {
+				t.Fatalf("Note %d goes back in time", i)
+			}
+		}
+		return notes[len(notes)-1].Time
+	}
+	easy := lastTime(newPatternCharacter(3).MakePatterns(50, ratWeights, 1))
+	hard := lastTime(newPatternCharacter(3).MakePatterns(50, ratWeights, maxDifficulty))
+	if hard >= easy {
+		t.Errorf("Expected difficulty %d to be faster than 1, took %dms vs %dms", maxDifficulty, hard, easy)
+	}
+}
+
+func TestPressJumpEitherOrder(t *testing.T) {
+	level := createTestLevel()
+	c := &level.Player.Character
+	c.Stamina = 3
+	c.Burst = &Burst{Notes: []Note{{Column: 0, Time: 0}, {Column: 3, Time: 0}, {Column: 1, Time: 250}}, MaxCombo: 3}
+	level.Press(c, 3, 0)
+	level.Press(c, 0, 0)
+	if len(c.Burst.Notes) != 1 || c.Burst.Notes[0].Column != 1 {
+		t.Errorf("Expected both halves of the jump to be hit, %v left", c.Burst.Notes)
+	}
+}
+
+func TestKeyModes(t *testing.T) {
+	for _, keys := range KeyModes {
+		c := newPatternCharacter(int64(keys))
+		c.Keys = keys
+		if c.KeyMode() != keys {
+			t.Errorf("Expected %dK, got %dK", keys, c.KeyMode())
+		}
+		used := make([]bool, keys)
+		notes := append(c.MakeStream(200), c.MakePatterns(200, PatternWeights{CandlePattern: 1, JumpPattern: 1, StairPattern: 1}, 5)...)
+		for _, n := range notes {
+			if n.Column < 0 || n.Column >= keys {
+				t.Fatalf("%dK: column %d out of range", keys, n.Column)
+			}
+			used[n.Column] = true
+		}
+		for col, ok := range used {
+			if !ok {
+				t.Errorf("%dK: column %d never used", keys, col)
+			}
+		}
+	}
+
+	// Unsupported modes fall back to the default
+	c := newPatternCharacter(1)
+	c.Keys = 5
+	if c.KeyMode() != NumKeys {
+		t.Errorf("Expected 5K to fall back to %dK, got %dK", NumKeys, c.KeyMode())
+	}
+}
+
+func TestDungeonKeyModes(t *testing.T) {
+	level := createTestLevel()
+	spider := NewSpider(Pos{X: 7, Y: 6})
+	spider.PatternRNG = mrand.New(mrand.NewSource(1))
+	level.Monsters[spider.Pos] = spider
+
+	// Spiders fight in 6K both ways
+	level.Attack(&spider.Character, &level.Player.Character)
+	level.Attack(&level.Player.Character, &spider.Character)
+	for _, c := range []*Character{&spider.Character, &level.Player.Character} {
+		if c.Burst.Keys != 6 {
+			t.Errorf("Expected the %s's burst to be 6K, got %dK", c.Name, c.Burst.Keys)
+		}
+		six := false
+		for _, n := range c.Burst.Notes {
+			if n.Column >= 6 {
+				t.Fatalf("Column %d doesn't fit 6K", n.Column)
+			}
+			six = six || n.Column >= 4
+		}
+		if !six {
+			t.Errorf("Expected the %s's burst to use the extra columns, got %v", c.Name, c.Burst.Notes)
+		}
+	}
+
+	// Rats stay on 4K
+	rat := NewRat(Pos{X: 7, Y: 8})
+	level.Player.Burst = nil
+	level.Attack(&level.Player.Character, &rat.Character)
+	if level.Player.Burst.Keys != 4 {
+		t.Errorf("Expected a 4K fight with the rat, got %dK", level.Player.Burst.Keys)
+	}
+}
@@ -1,13 +1,90 @@
 package game
 
-// NumKeys specifies the keymode
+import "github.com/maxproske/lyns-rhythm-dungeon/game/chart"
+
+// NumKeys specifies the default keymode
 const NumKeys = 4
 
-// Note translates the noteskin index atlas
+// KeyModes are the column counts a burst can be played on
+var KeyModes = []int{3, 4, 6, 7, 8}
+
+// ValidKeyMode checks if a burst can be played on this many columns
+func ValidKeyMode(keys int) bool {
+	for _, k := range KeyModes {
+		if k == keys {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyMode is how many columns the character attacks with.
+// Charts bring their own, so each difficulty can use a different mode.
+func (c *Character) KeyMode() int {
+	if keys := c.ownKeyMode(); keys != 0 {
+		return keys
+	}
+	return NumKeys
+}
+
+// ownKeyMode is the mode from the character's chart or Keys, or 0 if they don't have one
+func (c *Character) ownKeyMode() int {
+	if c.Chart != nil && ValidKeyMode(c.Chart.Columns) {
+		return c.Chart.Columns
+	}
+	if ValidKeyMode(c.Keys) {
+		return c.Keys
+	}
+	return 0
+}
+
+// battleKeys is the mode a fight is played in. The attacker's own if they have one,
+// otherwise the defender's, so the player fights every monster in that monster's mode.
+func battleKeys(c1, c2 *Character) int {
+	if keys := c1.ownKeyMode(); keys != 0 {
+		return keys
+	}
+	return c2.KeyMode()
+}
+
+// NoteType is what the player has to do with a note
+type NoteType int
+
+const (
+	// TapNote is pressed once
+	TapNote NoteType = iota
+	// HoldNote is pressed and held until its end time
+	HoldNote
+	// RollNote is tapped repeatedly until its end time
+	RollNote
+	// MineNote hurts you if you press it
+	MineNote
+)
+
+// Note is a single arrow in a burst
 type Note struct {
-	Rune rune
+	Column  int
+	Typ     NoteType
+	Time    int // Milliseconds from the start of the burst
+	EndTime int // Holds and rolls only
 }
 
+// Duration is the length of a hold or roll in milliseconds
+func (n Note) Duration() int {
+	return n.EndTime - n.Time
+}
+
+// RollTaps is how many times a roll has to be tapped
+func (n Note) RollTaps() int {
+	return max(2, n.Duration()/rollInterval)
+}
+
+const (
+	streamSpacing = 250 // Milliseconds between notes in a random stream
+	rollInterval  = 125 // Milliseconds between taps on a roll
+	mineDamage    = 1
+)
+
 const (
 	// Receptor represented by a character
 	Receptor rune = 'R'
@@ -17,18 +94,89 @@ const (
 	Blue = 'b'
 	// Yellow represented by a character
 	Yellow = 'y'
+	// HoldBody represented by a character
+	HoldBody = 'H'
+	// HoldEnd represented by a character
+	HoldEnd = 'E'
+	// MineSprite represented by a character
+	MineSprite = 'M'
+	// RollBody represented by a character
+	RollBody = 'L'
 )
 
 // MakeStream generates a stream of non-repeating notes
-func (c *Character) MakeStream(len int) []int {
+func (c *Character) MakeStream(len int) []Note {
+	return c.makeStream(len, c.KeyMode())
+}
+
+func (c *Character) makeStream(len int, keys int) []Note {
 	last := -1
-	notes := make([]int, len)
+	notes := make([]Note, len)
 	for i := range notes {
-		notes[i] = c.PatternRNG.Intn(NumKeys)
-		for notes[i] == last {
-			notes[i] = c.PatternRNG.Intn(NumKeys)
+		col := c.PatternRNG.Intn(keys)
+		for col == last {
+			col = c.PatternRNG.Intn(keys)
+		}
+		notes[i] = Note{Column: col, Typ: TapNote, Time: i * streamSpacing}
+		last = col
+	}
+	return notes
+}
+
+// MakeChartStream takes the next notes from the character's authored chart, looping back to the start.
+// Mines come along for free, length only counts notes you have to hit.
+func (c *Character) MakeChartStream(length int) []Note {
+	keys := c.KeyMode()
+	playable := make([]chart.Note, 0, len(c.Chart.Notes))
+	hits := 0
+	for _, n := range c.Chart.Notes {
+		// The chart has to fit the keymode
+		if n.Column >= keys {
+			continue
+		}
+		switch n.Typ {
+		case chart.Tap, chart.Hold, chart.Roll:
+			hits++
+		case chart.Mine:
+		default:
+			continue // Lifts and fakes aren't supported
+		}
+		playable = append(playable, n)
+	}
+	if hits == 0 {
+		return c.makeStream(length, keys)
+	}
+
+	// Looping adds the length of the chart (plus a gap) to each note time.
+	// Holds and rolls count until they end, so the next loop doesn't start in one's tail.
+	end := 0
+	for _, n := range playable {
+		end = max(end, n.Time, n.EndTime)
+	}
+	loopTime := end - playable[0].Time + streamSpacing
+	start := c.ChartPos
+	notes := make([]Note, 0, length)
+	for hits = 0; hits < length; c.ChartPos++ {
+		n := playable[c.ChartPos%len(playable)]
+		loop := c.ChartPos/len(playable) - start/len(playable)
+		offset := loop*loopTime - playable[start%len(playable)].Time
+		note := Note{Column: n.Column, Time: n.Time + offset}
+		switch n.Typ {
+		case chart.Tap:
+			note.Typ = TapNote
+			hits++
+		case chart.Hold:
+			note.Typ = HoldNote
+			note.EndTime = n.EndTime + offset
+			hits++
+		case chart.Roll:
+			note.Typ = RollNote
+			note.EndTime = n.EndTime + offset
+			hits++
+		case chart.Mine:
+			note.Typ = MineNote
 		}
-		last = notes[i]
+		notes = append(notes, note)
 	}
 	return notes
 }
@@ -0,0 +1,165 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// PracticeOptions are picked on the practice menu
+type PracticeOptions struct {
+	Monster     int // Index into PracticeMonsters
+	Difficulty  int // 0 is a plain stream, otherwise the monster's patterns
+	Keys        int
+	ScrollSpeed float64
+}
+
+// PracticeMonsters are the monsters whose patterns can be practiced
+var PracticeMonsters = []struct {
+	Name string
+	New  func(Pos) *Monster
+}{
+	{"Rat", NewRat},
+	{"Spider", NewSpider},
+}
+
+// MaxPracticeDifficulty is the hardest patterns get
+const MaxPracticeDifficulty = maxDifficulty
+
+const practiceLength = 16 // Notes per loop
+
+// Practice plays bursts over and over on a level of its own, so nothing in the dungeon gets hurt
+type Practice struct {
+	Options PracticeOptions
+	Level   *Level
+	Player  *Character
+	Monster *Monster
+	Stats   *PracticeStats
+	Last    *BattleResult // How the last loop went
+}
+
+// NewPractice sets up a practice level that follows the clock, with speed mods from the options
+func NewPractice(options PracticeOptions, clock Clock, settings *Settings, stats *PracticeStats) *Practice {
+	practiceSettings := *settings
+	practiceSettings.ScrollSpeed = options.ScrollSpeed
+	practiceSettings.ConstantBPM = 0
+
+	level := &Level{
+		Monsters: make(map[Pos]*Monster),
+		Items:    make(map[Pos][]*Item),
+		Battle:   &Battle{},
+		Settings: &practiceSettings,
+		Practice: true,
+	}
+	level.Clock = &offsetClock{clock: clock, settings: level.Settings}
+
+	monster := PracticeMonsters[options.Monster].New(Pos{})
+	monster.Hitpoints = practiceLength // Attack makes bursts as long as the defender's hitpoints
+	level.Player = &Player{Character: Character{
+		Entity:     Entity{Name: "You", Rune: '@'},
+		Hitpoints:  1,
+		MaxStamina: math.MaxInt32, // Never run out
+		Stamina:    math.MaxInt32,
+		PatternRNG: monster.PatternRNG,
+		Keys:       options.Keys,
+		Difficulty: options.Difficulty,
+	}}
+	if options.Difficulty > 0 {
+		level.Player.Patterns = monster.Patterns
+	}
+	p := &Practice{Options: options, Level: level, Player: &level.Player.Character, Monster: monster, Stats: stats}
+	level.Attack(p.Player, &monster.Character)
+	return p
+}
+
+// Name is what the best accuracy is saved under
+func (o PracticeOptions) Name() string {
+	difficulty := "stream"
+	if o.Difficulty > 0 {
+		difficulty = "difficulty " + strconv.Itoa(o.Difficulty)
+	}
+	return PracticeMonsters[o.Monster].Name + " " + strconv.Itoa(o.Keys) + "K " + difficulty
+}
+
+// Press judges a key going down at a song position
+func (p *Practice) Press(col, time int) Judgement {
+	return p.Level.Press(p.Player, col, time)
+}
+
+// Release judges a key going up at a song position
+func (p *Practice) Release(col, time int) Judgement {
+	return p.Level.Release(p.Player, col, time)
+}
+
+// Update misses notes that have gone by, and loops around to a new burst once this one is done.
+// Returns true if the best accuracy for these options went up.
+func (p *Practice) Update(now int) bool {
+	p.Level.CheckMisses(p.Player, now)
+	if !p.Level.BurstOver() {
+		return false
+	}
+	p.Level.newTurn() // Each loop is a turn
+	p.Last = p.Level.EndTurn()
+	p.Level.Attack(p.Player, &p.Monster.Character)
+	if p.Last == nil {
+		return false
+	}
+	return p.Stats.Record(p.Options.Name(), p.Last.Accuracy)
+}
+
+// PracticeStats are the best accuracy for each set of practice options
+type PracticeStats struct {
+	Best map[string]float64 `json:"best"`
+}
+
+// DefaultPracticeStatsPath is next to the settings
+func DefaultPracticeStatsPath() (string, error) {
+	path, err := DefaultSettingsPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "practice.json"), nil
+}
+
+// LoadPracticeStats reads stats from a file. A missing file means nothing has been practiced yet.
+func LoadPracticeStats(filename string) (*PracticeStats, error) {
+	stats := &PracticeStats{Best: make(map[string]float64)}
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return &PracticeStats{Best: make(map[string]float64)}, err
+	}
+	if stats.Best == nil {
+		stats.Best = make(map[string]float64)
+	}
+	return stats, nil
+}
+
+// Save writes stats to a file, making the directory if it isn't there yet
+func (s *PracticeStats) Save(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Record keeps an accuracy if it's the best yet. Returns true if it was.
+func (s *PracticeStats) Record(name string, accuracy float64) bool {
+	if best, ok := s.Best[name]; ok && best >= accuracy {
+		return false
+	}
+	s.Best[name] = accuracy
+	return true
+}
@@ -0,0 +1,86 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPracticeLoops(t *testing.T) {
+	clock := &FakeClock{}
+	stats := &PracticeStats{Best: make(map[string]float64)}
+	options := PracticeOptions{Monster: 1, Difficulty: 2, Keys: 6, ScrollSpeed: 3}
+	p := NewPractice(options, clock, &Settings{ScrollSpeed: 1, ConstantBPM: 300}, stats)
+
+	if p.Level.Settings.ScrollSpeed != 3 || p.Level.Settings.ConstantBPM != 0 {
+		t.Errorf("Expected the practice speed to replace the settings, got %+v", p.Level.Settings)
+	}
+	b := p.Player.Burst
+	if b == nil || b.Keys != 6 || len(b.Notes) != practiceLength {
+		t.Fatalf("Expected a %d note 6K burst, got %+v", practiceLength, b)
+	}
+
+	// Hit everything spot on
+	notes := append([]Note(nil), b.Notes...)
+	for _, n := range notes {
+		p.Press(n.Column, b.At(n))
+	}
+	clock.Set(b.At(notes[len(notes)-1]))
+	if !p.Update(clock.Now()) {
+		t.Error("Expected a new best")
+	}
+	if p.Last == nil || p.Last.Accuracy != 100 || stats.Best[options.Name()] != 100 {
+		t.Errorf("Expected 100%% to be recorded, got %+v and %v", p.Last, stats.Best)
+	}
+	if p.Player.Burst == b || p.Player.Burst.Done() {
+		t.Error("Expected practice to loop around to a new burst")
+	}
+
+	// Missing everything isn't a new best
+	end := p.Player.Burst.At(p.Player.Burst.Notes[len(p.Player.Burst.Notes)-1])
+	if p.Update(end + 1000) {
+		t.Error("Expected missing everything not to be a new best")
+	}
+	if p.Last.Accuracy != 0 || stats.Best[options.Name()] != 100 {
+		t.Errorf("Expected best to stay at 100%%, got %v", stats.Best)
+	}
+}
+
+func TestPracticeMinesDontHurt(t *testing.T) {
+	p := NewPractice(PracticeOptions{Keys: 4, ScrollSpeed: 1}, &FakeClock{}, &Settings{}, &PracticeStats{Best: make(map[string]float64)})
+	hp := p.Player.Hitpoints
+	b := p.Player.Burst
+	b.Notes = []Note{{Column: 0, Typ: MineNote}}
+	p.Press(0, b.Start)
+	if p.Player.Hitpoints != hp {
+		t.Errorf("Expected mines not to hurt in practice, hitpoints went from %d to %d", hp, p.Player.Hitpoints)
+	}
+}
+
+func TestPracticeStatsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nested", "practice.json")
+	stats, err := LoadPracticeStats(filename)
+	if err != nil || len(stats.Best) != 0 {
+		t.Fatalf("Expected empty stats for a missing file, got %v %v", stats, err)
+	}
+	stats.Record("Rat 4K stream", 80)
+	if stats.Record("Rat 4K stream", 70) {
+		t.Error("Expected a worse accuracy not to be recorded")
+	}
+	if err := stats.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPracticeStats(filename)
+	if err != nil || loaded.Best["Rat 4K stream"] != 80 {
+		t.Errorf("Expected best to be saved, got %v %v", loaded, err)
+	}
+
+	// A broken file still gives stats to record into
+	if err := os.WriteFile(filename, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken, err := LoadPracticeStats(filename)
+	if err == nil || broken == nil || broken.Best == nil || len(broken.Best) != 0 {
+		t.Errorf("Expected an error with empty stats, got %v %v", broken, err)
+	}
+}
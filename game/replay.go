@@ -0,0 +1,57 @@
+package game
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// ReplayEvent is an event written down by name, so it still makes sense once the characters are gone
+type ReplayEvent struct {
+	Tick   int       `json:"tick"`
+	Typ    GameEvent `json:"type"`
+	Who    string    `json:"who,omitempty"`
+	Target string    `json:"target,omitempty"`
+	Pos    Pos       `json:"pos"`
+	Item   string    `json:"item,omitempty"`
+	Amount int       `json:"amount,omitempty"`
+}
+
+// Replay is every event of the run in order. It's kept in the save, and written out on its own once the run is over.
+type Replay struct {
+	Seed   int64         `json:"seed"`
+	Events []ReplayEvent `json:"events"`
+}
+
+// Record writes an event down with the tick it happened on
+func (r *Replay) Record(tick int, e Event) {
+	re := ReplayEvent{Tick: tick, Typ: e.Typ, Pos: e.Pos, Amount: e.Amount}
+	if e.Who != nil {
+		re.Who = e.Who.Name
+	}
+	if e.Target != nil {
+		re.Target = e.Target.Name
+	}
+	if e.Item != nil {
+		re.Item = e.Item.Name
+	}
+	r.Events = append(r.Events, re)
+}
+
+// replayPath is next to the save, and only the last run's replay is kept
+func replayPath(savePath string) string {
+	return filepath.Join(filepath.Dir(savePath), "replay.json")
+}
+
+// saveReplay writes the finished run's replay
+func (game *Game) saveReplay(filename string) error {
+	game.Replay.Seed = game.Seed
+	data, err := json.Marshal(game.Replay)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
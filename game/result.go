@@ -0,0 +1,174 @@
+package game
+
+import (
+	"strconv"
+)
+
+// TurnStats count how the attacker did this turn. Bursts can last a few turns, so these are reset on every attack.
+type TurnStats struct {
+	Judgements  [NumJudgements]int
+	MaxStreak   int
+	DamageDealt int
+	DamageTaken int // Mines hurt the attacker
+}
+
+// BattleResult is how one turn of a fight went, from the attacker's side
+type BattleResult struct {
+	Attacker    string
+	Defender    string
+	Judgements  [NumJudgements]int
+	Hits        int
+	Misses      int
+	MaxCombo    int
+	Accuracy    float64 // Percent
+	DamageDealt int
+	DamageTaken int
+	Grade       string
+}
+
+// How much each judgement is worth towards accuracy, out of 100
+var judgementScores = [NumJudgements]int{Perfect: 100, Great: 80, Good: 50, Boo: 20, Miss: 0}
+
+// Grades and the accuracy needed to get them, best first
+var grades = []struct {
+	name     string
+	accuracy float64
+}{
+	{"AAA", 100},
+	{"AA", 93},
+	{"A", 80},
+	{"B", 65},
+	{"C", 45},
+	{"D", 0},
+}
+
+// Accuracy scores judgements out of 100. Returns 0 if nothing was judged.
+func Accuracy(judgements [NumJudgements]int) float64 {
+	total := 0
+	score := 0
+	for j := Perfect; j < NumJudgements; j++ {
+		total += judgements[j]
+		score += judgements[j] * judgementScores[j]
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(score) / float64(total)
+}
+
+// Grade turns an accuracy into a letter grade
+func Grade(accuracy float64) string {
+	for _, g := range grades {
+		if accuracy >= g.accuracy {
+			return g.name
+		}
+	}
+	return grades[len(grades)-1].name
+}
+
+func newBattleResult(c1, c2 *Character) *BattleResult {
+	turn := c1.Burst.Turn
+	result := &BattleResult{
+		Attacker:    c1.Name,
+		Defender:    c2.Name,
+		Judgements:  turn.Judgements,
+		Misses:      turn.Judgements[Miss],
+		MaxCombo:    turn.MaxStreak,
+		Accuracy:    Accuracy(turn.Judgements),
+		DamageDealt: turn.DamageDealt,
+		DamageTaken: turn.DamageTaken,
+	}
+	for j := Perfect; j < Miss; j++ {
+		result.Hits += turn.Judgements[j]
+	}
+	result.Grade = Grade(result.Accuracy)
+	return result
+}
+
+// String is the line that goes in the event log
+func (r *BattleResult) String() string {
+	return r.Grade + " (" + strconv.FormatFloat(r.Accuracy, 'f', 1, 64) + "%): " +
+		strconv.Itoa(r.Hits) + " hit, " + strconv.Itoa(r.Misses) + " missed, " +
+		strconv.Itoa(r.MaxCombo) + " max combo."
+}
+
+// Fighting is true while an attacker is playing their turn, and nobody can walk away
+func (level *Level) Fighting() bool {
+	return level.Battle != nil && level.Battle.Active
+}
+
+// BurstOver is true once the attacker has run out of stamina or notes for this turn
+func (level *Level) BurstOver() bool {
+	c1 := level.Battle.C1
+	return c1 != nil && c1.Burst != nil && (c1.Stamina <= 0 || c1.Burst.Done())
+}
+
+// EndTurn gives the attacker their stamina back, and logs and records how the turn went.
+// Returns nil if no notes were played.
+func (level *Level) EndTurn() *BattleResult {
+	c1 := level.Battle.C1
+	c2 := level.Battle.C2
+	c1.Stamina = c1.MaxStamina
+	level.Battle.Active = false
+	result := newBattleResult(c1, c2)
+	c1.Burst.Turn = TurnStats{}
+	if result.Hits+result.Misses == 0 {
+		return nil
+	}
+	level.Emit(Event{Typ: TurnOver, Who: c1, Target: c2, Result: result})
+	return result
+}
+
+// RunStats add up every fight in a run, from the player's side
+type RunStats struct {
+	Battles     []*BattleResult
+	NotesHit    int
+	NotesMissed int
+	MaxCombo    int
+	DamageDealt int
+	DamageTaken int
+	Judgements  [NumJudgements]int
+}
+
+// Record adds up the fights from the event stream
+func (s *RunStats) Record(e Event) {
+	if e.Typ == TurnOver {
+		s.Add(e.Result)
+	}
+}
+
+// Add records a turn of a fight
+func (s *RunStats) Add(r *BattleResult) {
+	s.Battles = append(s.Battles, r)
+	if r.Attacker != "You" {
+		// Monsters hurt us, and mines hurt them
+		s.DamageDealt += r.DamageTaken
+		s.DamageTaken += r.DamageDealt
+		return
+	}
+	s.NotesHit += r.Hits
+	s.NotesMissed += r.Misses
+	s.MaxCombo = max(s.MaxCombo, r.MaxCombo)
+	s.DamageDealt += r.DamageDealt
+	s.DamageTaken += r.DamageTaken
+	for j := range r.Judgements {
+		s.Judgements[j] += r.Judgements[j]
+	}
+}
+
+// Accuracy over every note the player has played
+func (s *RunStats) Accuracy() float64 {
+	return Accuracy(s.Judgements)
+}
+
+// Summary is a few lines for the end of a run
+func (s *RunStats) Summary() []string {
+	accuracy := s.Accuracy()
+	return []string{
+		"Grade: " + Grade(accuracy) + " (" + strconv.FormatFloat(accuracy, 'f', 1, 64) + "%)",
+		"Notes hit: " + strconv.Itoa(s.NotesHit) + ", missed: " + strconv.Itoa(s.NotesMissed),
+		"Max combo: " + strconv.Itoa(s.MaxCombo),
+		"Damage dealt: " + strconv.Itoa(s.DamageDealt) + ", taken: " + strconv.Itoa(s.DamageTaken),
+		"Turns fought: " + strconv.Itoa(len(s.Battles)),
+	}
+}
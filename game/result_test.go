@@ -0,0 +1,101 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccuracyAndGrade(t *testing.T) {
+	tests := []struct {
+		judgements [NumJudgements]int
+		accuracy   float64
+		grade      string
+	}{
+		{[NumJudgements]int{Perfect: 4}, 100, "AAA"},
+		{[NumJudgements]int{Perfect: 9, Great: 1}, 98, "AA"},
+		{[NumJudgements]int{Perfect: 1, Great: 1}, 90, "A"},
+		{[NumJudgements]int{Perfect: 1, Miss: 1}, 50, "C"},
+		{[NumJudgements]int{Boo: 1, Miss: 1}, 10, "D"},
+		{[NumJudgements]int{}, 0, "D"},
+	}
+	for _, tc := range tests {
+		accuracy := Accuracy(tc.judgements)
+		if accuracy != tc.accuracy {
+			t.Errorf("%v: expected %v%%, got %v%%", tc.judgements, tc.accuracy, accuracy)
+		}
+		if grade := Grade(accuracy); grade != tc.grade {
+			t.Errorf("%v: expected grade %s, got %s", tc.judgements, tc.grade, grade)
+		}
+	}
+}
+
+func TestEndTurn(t *testing.T) {
+	level := createTestLevel()
+	level.Stats = &RunStats{}
+	clock := &FakeClock{}
+	level.Clock = clock
+	p := &level.Player.Character
+	p.MaxStamina = 3
+	p.Stamina = 3
+	rat := NewRat(Pos{6, 7})
+	rat.Hitpoints = 10
+	level.Monsters[rat.Pos] = rat
+	level.Attack(p, &rat.Character)
+	b := p.Burst
+	b.Notes = []Note{{Column: 0, Time: 0}, {Column: 1, Time: 250}, {Column: 2, Time: 500}, {Column: 3, Time: 750}}
+
+	if level.BurstOver() {
+		t.Fatal("Burst shouldn't be over before it starts")
+	}
+	level.Press(p, 0, b.Start)
+	level.ResolveDamage()
+	level.Press(p, 1, b.Start+300) // Good
+	level.ResolveDamage()
+	level.CheckMisses(p, b.Start+700) // Only the third note has gone by
+	if !level.BurstOver() {
+		t.Fatal("Expected burst to be over once stamina runs out")
+	}
+	result := level.EndTurn()
+	if result == nil {
+		t.Fatal("Expected a result")
+	}
+	if result.Hits != 2 || result.Misses != 1 || result.MaxCombo != 2 || result.DamageDealt != 2 || result.Grade != "C" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if p.Stamina != p.MaxStamina || b.Turn != (TurnStats{}) {
+		t.Errorf("Expected stamina and turn stats to be reset, got %d stamina and %+v", p.Stamina, b.Turn)
+	}
+	if last := level.Log.Messages[len(level.Log.Messages)-1]; !strings.HasPrefix(last.Text, "Your attack: C") || last.Kind != CombatMessage {
+		t.Errorf("Expected result in the event log, got %+v", last)
+	}
+	if len(level.Stats.Battles) != 1 || level.Stats.NotesHit != 2 || level.Stats.DamageDealt != 2 {
+		t.Errorf("Expected result to be recorded, got %+v", level.Stats)
+	}
+
+	// Nothing played, nothing recorded
+	level.Attack(p, &rat.Character)
+	if result := level.EndTurn(); result != nil || len(level.Stats.Battles) != 1 {
+		t.Errorf("Expected an empty turn to be ignored, got %+v", result)
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	stats := &RunStats{}
+	stats.Add(&BattleResult{Attacker: "You", Defender: "Rat", Judgements: [NumJudgements]int{Perfect: 3, Miss: 1}, Hits: 3, Misses: 1, MaxCombo: 3, DamageDealt: 3})
+	stats.Add(&BattleResult{Attacker: "Rat", Defender: "You", Judgements: [NumJudgements]int{Perfect: 2}, Hits: 2, MaxCombo: 2, DamageDealt: 2, DamageTaken: 1})
+	stats.Add(&BattleResult{Attacker: "You", Defender: "Rat", Judgements: [NumJudgements]int{Great: 1}, Hits: 1, MaxCombo: 1, DamageDealt: 1, DamageTaken: 1})
+
+	// Monster turns count the other way around, and their notes aren't ours
+	if stats.NotesHit != 4 || stats.NotesMissed != 1 || stats.MaxCombo != 3 {
+		t.Errorf("Unexpected note stats %+v", stats)
+	}
+	if stats.DamageDealt != 5 || stats.DamageTaken != 3 {
+		t.Errorf("Expected 5 damage dealt and 3 taken, got %d and %d", stats.DamageDealt, stats.DamageTaken)
+	}
+	if accuracy := stats.Accuracy(); accuracy != 76 {
+		t.Errorf("Expected 76%% accuracy, got %v", accuracy)
+	}
+	if summary := stats.Summary(); len(summary) == 0 || summary[0] != "Grade: B (76.0%)" {
+		t.Errorf("Unexpected summary %v", summary)
+	}
+}
@@ -0,0 +1,380 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NewSeededGame makes a game whose patterns all come from one seed, so a run can be played again
+func NewSeededGame(numWindows int, seed int64) *Game {
+	game := NewGame(numWindows)
+	game.seed(seed)
+	return game
+}
+
+// Give every character their own random patterns from the seed, in an order that doesn't depend on map iteration
+func (game *Game) seed(seed int64) {
+	game.Seed = seed
+	rng := rand.New(rand.NewSource(seed))
+	game.CurrentLevel.Player.seedPatterns(rng.Int63(), 0)
+	for _, name := range game.levelNames() {
+		for _, m := range game.Levels[name].sortedMonsters() {
+			m.seedPatterns(rng.Int63(), 0)
+		}
+	}
+}
+
+// patternSource counts the numbers a character's patterns have used, so a continued run carries on from the same one
+type patternSource struct {
+	rand.Source
+	seed  int64
+	draws int
+}
+
+func (s *patternSource) Int63() int64 {
+	s.draws++
+	return s.Source.Int63()
+}
+
+// seedPatterns gives a character their own patterns, skipping the numbers they've already drawn
+func (c *Character) seedPatterns(seed int64, draws int) {
+	src := &patternSource{Source: rand.NewSource(seed), seed: seed}
+	for src.draws < draws {
+		src.Int63()
+	}
+	c.patternSrc = src
+	c.PatternRNG = rand.New(src)
+}
+
+// RandomSeed picks a seed for a new run
+func RandomSeed() int64 {
+	return time.Now().UnixNano() % 1000000 // Short enough to type in
+}
+
+func (game *Game) levelNames() []string {
+	names := make([]string, 0, len(game.Levels))
+	for name := range game.Levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Monsters from top to bottom, left to right
+func (level *Level) sortedMonsters() []*Monster {
+	monsters := make([]*Monster, 0, len(level.Monsters))
+	for _, m := range level.Monsters {
+		monsters = append(monsters, m)
+	}
+	sort.Slice(monsters, func(i, j int) bool {
+		a, b := monsters[i].Pos, monsters[j].Pos
+		return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+	})
+	return monsters
+}
+
+// SaveFile is everything that changes during a run. The rest comes from the map files.
+type SaveFile struct {
+	Seed   int64                 `json:"seed"`
+	Level  string                `json:"level"`
+	Player SavedCharacter        `json:"player"`
+	Levels map[string]SavedLevel `json:"levels"`
+	Stats  RunStats              `json:"stats"`
+	Log    MessageLog            `json:"log"`
+	Ticks  int                   `json:"ticks,omitempty"`
+	Class  string                `json:"class,omitempty"`
+	Replay Replay                `json:"replay"`
+}
+
+// SavedCharacter is a character's position, health and belongings
+type SavedCharacter struct {
+	Rune      rune        `json:"rune"`
+	Pos       Pos         `json:"pos"`
+	Hitpoints int         `json:"hitpoints"`
+	Items     []SavedItem `json:"items,omitempty"`
+	Weapon    *SavedItem  `json:"weapon,omitempty"`
+	Helmet    *SavedItem  `json:"helmet,omitempty"`
+	Energy    float64     `json:"energy,omitempty"`
+	Effects   []Effect    `json:"effects,omitempty"`
+	XP        int         `json:"xp,omitempty"`
+	Level     int         `json:"level,omitempty"`
+	Patterns  *SavedRNG   `json:"patterns,omitempty"`
+	ChartPos  int         `json:"chart_pos,omitempty"`
+}
+
+// SavedRNG is a pattern seed and how many numbers have been drawn from it
+type SavedRNG struct {
+	Seed  int64 `json:"seed"`
+	Draws int   `json:"draws"`
+}
+
+// SavedItem is an item by its rune, since that's how maps make them
+type SavedItem struct {
+	Rune rune `json:"rune"`
+	Pos  Pos  `json:"pos"`
+}
+
+// SavedLevel is who is still alive, what's still lying around, and the doors, traps and tiles that have been found
+type SavedLevel struct {
+	Monsters []SavedCharacter `json:"monsters"`
+	Items    []SavedItem      `json:"items"`
+	Overlays []string         `json:"overlays"`
+	Seen     []string         `json:"seen"`
+	LastSeen *Pos             `json:"last_seen,omitempty"`
+	LeftAt   int              `json:"left_at,omitempty"`
+}
+
+// Items and monsters that can be saved, by the rune they have on maps
+var (
+	itemsByRune = map[rune]func(Pos) *Item{
+		'$': NewCredits,
+		'+': NewPotion,
+		'b': NewBones,
+		's': NewSword,
+		'h': NewHelmet,
+	}
+	monstersByRune = map[rune]func(Pos) *Monster{
+		'R': NewRat,
+		'S': NewSpider,
+	}
+)
+
+// DefaultSavePath is next to the settings
+func DefaultSavePath() (string, error) {
+	path, err := DefaultSettingsPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "save.json"), nil
+}
+
+// SaveExists is true if there's a run to continue
+func SaveExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func saveItem(item *Item) *SavedItem {
+	if item == nil {
+		return nil
+	}
+	return &SavedItem{item.Rune, item.Pos}
+}
+
+func saveCharacter(c *Character) SavedCharacter {
+	saved := SavedCharacter{Rune: c.Rune, Pos: c.Pos, Hitpoints: c.Hitpoints, Weapon: saveItem(c.Weapon), Helmet: saveItem(c.Helmet), Energy: c.ActionPoints, Effects: c.Effects, XP: c.XP, Level: c.CharLevel, ChartPos: c.ChartPos}
+	if c.patternSrc != nil {
+		saved.Patterns = &SavedRNG{c.patternSrc.seed, c.patternSrc.draws}
+	}
+	for _, item := range c.Items {
+		saved.Items = append(saved.Items, *saveItem(item))
+	}
+	return saved
+}
+
+// Save writes the run to a file, making the directory if it isn't there yet
+func (game *Game) Save(filename string) error {
+	save := SaveFile{
+		Seed:   game.Seed,
+		Ticks:  game.Ticks,
+		Class:  game.CurrentLevel.Player.Class,
+		Player: saveCharacter(&game.CurrentLevel.Player.Character),
+		Levels: make(map[string]SavedLevel),
+	}
+	if game.Stats != nil {
+		save.Stats = *game.Stats
+	}
+	if game.Log != nil {
+		save.Log = *game.Log
+	}
+	if game.Replay != nil {
+		save.Replay = *game.Replay
+	}
+	for name, level := range game.Levels {
+		if level == game.CurrentLevel {
+			save.Level = name
+		}
+		saved := SavedLevel{LastSeen: level.LastSeen, LeftAt: level.LeftAt}
+		for _, m := range level.sortedMonsters() {
+			saved.Monsters = append(saved.Monsters, saveCharacter(&m.Character))
+		}
+		for pos, items := range level.Items {
+			for _, item := range items {
+				saved.Items = append(saved.Items, SavedItem{item.Rune, pos}) // Dropped items don't know where they are
+			}
+		}
+		for _, row := range level.Map {
+			var overlays, seen strings.Builder
+			for _, tile := range row {
+				if tile.OverlayRune == Blank {
+					overlays.WriteRune(' ')
+				} else {
+					overlays.WriteRune(tile.OverlayRune)
+				}
+				if tile.Seen {
+					seen.WriteByte('1')
+				} else {
+					seen.WriteByte('0')
+				}
+			}
+			saved.Overlays = append(saved.Overlays, overlays.String())
+			saved.Seen = append(saved.Seen, seen.String())
+		}
+		save.Levels[name] = saved
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+func loadItem(saved *SavedItem) (*Item, error) {
+	newItem, ok := itemsByRune[saved.Rune]
+	if !ok {
+		return nil, errors.New("unknown item " + string(saved.Rune) + " in save")
+	}
+	return newItem(saved.Pos), nil
+}
+
+func loadCharacter(c *Character, saved SavedCharacter) error {
+	c.Pos = saved.Pos
+	for c.CharLevel < saved.Level {
+		c.levelUp() // Stats grow the same way they did the first time
+	}
+	c.XP = saved.XP
+	c.Hitpoints = saved.Hitpoints
+	c.ActionPoints = saved.Energy
+	c.Effects = saved.Effects
+	c.ChartPos = saved.ChartPos
+	if saved.Patterns != nil {
+		c.seedPatterns(saved.Patterns.Seed, saved.Patterns.Draws)
+	}
+	c.Items = nil
+	for i := range saved.Items {
+		item, err := loadItem(&saved.Items[i])
+		if err != nil {
+			return err
+		}
+		c.Items = append(c.Items, item)
+	}
+	var err error
+	c.Weapon, c.Helmet = nil, nil
+	if saved.Weapon != nil {
+		if c.Weapon, err = loadItem(saved.Weapon); err != nil {
+			return err
+		}
+	}
+	if saved.Helmet != nil {
+		if c.Helmet, err = loadItem(saved.Helmet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadGame rebuilds a run from the map files and a save
+func LoadGame(numWindows int, filename string) (*Game, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	save := SaveFile{}
+	if err := json.Unmarshal(data, &save); err != nil {
+		return nil, err
+	}
+
+	game := NewSeededGame(numWindows, save.Seed)
+	level, ok := game.Levels[save.Level]
+	if !ok {
+		return nil, errors.New("unknown level " + save.Level + " in save")
+	}
+	game.enterLevel(level)
+	game.Ticks = save.Ticks
+	if save.Class != "" {
+		classes, err := LoadClasses()
+		if err != nil {
+			return nil, err
+		}
+		class := FindClass(classes, save.Class)
+		if class == nil {
+			return nil, errors.New("unknown class " + save.Class + " in save")
+		}
+		if err := level.Player.setClass(class); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadCharacter(&level.Player.Character, save.Player); err != nil {
+		return nil, err
+	}
+	*game.Stats = save.Stats
+	*game.Log = save.Log
+	*game.Replay = save.Replay
+
+	for name, saved := range save.Levels {
+		level, ok := game.Levels[name]
+		if !ok {
+			continue // The map was taken out of the game
+		}
+		level.LastSeen, level.LeftAt = saved.LastSeen, saved.LeftAt
+		level.Monsters = make(map[Pos]*Monster)
+		for _, s := range saved.Monsters {
+			newMonster, ok := monstersByRune[s.Rune]
+			if !ok {
+				return nil, errors.New("unknown monster " + string(s.Rune) + " in save")
+			}
+			m := newMonster(s.Pos)
+			if err := loadCharacter(&m.Character, s); err != nil {
+				return nil, err
+			}
+			level.Monsters[m.Pos] = m
+		}
+		level.Items = make(map[Pos][]*Item)
+		for i := range saved.Items {
+			item, err := loadItem(&saved.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			level.Items[item.Pos] = append(level.Items[item.Pos], item)
+		}
+		for y, row := range saved.Overlays {
+			for x, r := range []rune(row) {
+				if y < len(level.Map) && x < len(level.Map[y]) && y < len(saved.Seen) && x < len(saved.Seen[y]) {
+					if r == ' ' {
+						r = Blank
+					}
+					level.Map[y][x].OverlayRune = r
+					level.Map[y][x].Seen = saved.Seen[y][x] == '1'
+				}
+			}
+		}
+	}
+	if save.Player.Patterns == nil {
+		game.seed(save.Seed) // Older saves don't know where the patterns were up to, so they start over
+	}
+	game.loadCharts()
+	game.CurrentLevel.lineOfSight()
+	return game, nil
+}
+
+// Finish the run when the game quits. Dead players can't continue, but they get their replay.
+func (game *Game) saveOrForget() error {
+	if game.SavePath == "" {
+		return nil
+	}
+	if game.CurrentLevel.Player.Hitpoints <= 0 {
+		os.Remove(game.SavePath)
+		return game.saveReplay(replayPath(game.SavePath))
+	}
+	return game.Save(game.SavePath)
+}
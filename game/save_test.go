@@ -0,0 +1,209 @@
+package game
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Move into a temp dir with a small world, so games can be made from map files
+func setupTestWorld(t *testing.T) {
+	tmpDir := t.TempDir()
+	mapsDir := filepath.Join(tmpDir, "game", "maps")
+	if err := os.MkdirAll(mapsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"world.txt":       "first\nfirst,5,1,second,1,1",
+		"first.map":       "#######\n#@.|.u#\n#R.+..#\n#######",
+		"second.map":      "#####\n#d.S#\n#####",
+		"../classes.json": `[{"name": "Bard", "hitpoints": 14, "stamina": 3, "sight_range": 7, "weapon": "s", "passive": {"name": "Encore", "heal_every": 8}}]`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(mapsDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(oldWd) })
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+}
+
+func TestSeededGamesMatch(t *testing.T) {
+	setupTestWorld(t)
+	a := NewSeededGame(1, 42)
+	b := NewSeededGame(1, 42)
+	streamA := a.CurrentLevel.Player.MakeStream(16)
+	streamB := b.CurrentLevel.Player.MakeStream(16)
+	for i := range streamA {
+		if streamA[i] != streamB[i] {
+			t.Fatalf("Expected the same seed to give the same patterns, got %v and %v", streamA, streamB)
+		}
+	}
+	ratA := a.CurrentLevel.Monsters[Pos{1, 2}]
+	ratB := b.CurrentLevel.Monsters[Pos{1, 2}]
+	if ratA.PatternRNG.Int63() != ratB.PatternRNG.Int63() {
+		t.Error("Expected monsters to get the same patterns too")
+	}
+}
+
+func TestContinuedRunsKeepTheirPatterns(t *testing.T) {
+	setupTestWorld(t)
+	played := NewSeededGame(1, 42)
+	uninterrupted := NewSeededGame(1, 42)
+	for _, g := range []*Game{played, uninterrupted} {
+		g.CurrentLevel.Player.MakeStream(10)
+		g.Levels["second"].Monsters[Pos{3, 1}].MakeStream(5)
+	}
+	played.Levels["second"].Monsters[Pos{3, 1}].ChartPos = 3
+
+	if err := played.Save(played.SavePath); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGame(1, played.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Carrying on gives the same patterns as never having stopped
+	a := loaded.CurrentLevel.Player.MakeStream(16)
+	b := uninterrupted.CurrentLevel.Player.MakeStream(16)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected the player's patterns to carry on, got %v and %v", a, b)
+		}
+	}
+	spider := loaded.Levels["second"].Monsters[Pos{3, 1}]
+	if spider.PatternRNG.Int63() != uninterrupted.Levels["second"].Monsters[Pos{3, 1}].PatternRNG.Int63() {
+		t.Error("Expected the spider's patterns to carry on too")
+	}
+	if spider.ChartPos != 3 {
+		t.Errorf("Expected the spider's place in its chart to be saved, got %d", spider.ChartPos)
+	}
+}
+
+func TestSaveAndContinue(t *testing.T) {
+	setupTestWorld(t)
+	game := NewSeededGame(1, 7)
+	level := game.CurrentLevel
+	if SaveExists(game.SavePath) {
+		t.Fatal("Expected no save yet")
+	}
+
+	if err := game.SetClass(&Class{Name: "Bard", Hitpoints: 14, Stamina: 3, SightRange: 7, Weapon: "s", Passive: Passive{HealEvery: 8}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Kill the rat, pick up the potion and open the door
+	rat := level.Monsters[Pos{1, 2}]
+	level.Kill(&rat.Character)
+	level.Player.Pos = Pos{3, 2}
+	level.MoveItem(level.Items[Pos{3, 2}][0], &level.Player.Character)
+	level.Map[1][3].OverlayRune = OpenDoor
+	level.Player.levelUp()
+	level.Player.XP = 10
+	level.Player.Hitpoints = 11
+	level.Stats.NotesHit = 5
+	game.Ticks = 12
+	game.Levels["second"].LastSeen = &Pos{2, 1}
+	game.Levels["second"].LeftAt = 3
+
+	if err := game.saveOrForget(); err != nil {
+		t.Fatal(err)
+	}
+	if !SaveExists(game.SavePath) {
+		t.Fatal("Expected a save")
+	}
+	loaded, err := LoadGame(1, game.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := loaded.CurrentLevel
+	p := l.Player
+	if loaded.Seed != 7 || p.Pos != (Pos{3, 2}) || p.Hitpoints != 11 || len(p.Items) != 1 || p.Items[0].Name != "Health Potion" || p.Weapon == nil {
+		t.Errorf("Player not restored, got %+v", p.Character)
+	}
+	if p.Class != "Bard" || p.Passive.HealEvery != 8 || p.MaxStamina != 4 {
+		t.Errorf("Expected the class to be restored, got %q", p.Class)
+	}
+	if p.CharLevel != 2 || p.XP != 10 || p.MaxHitpoints != 18 {
+		t.Errorf("Expected experience and level to be restored, got level %d with %d and %d max hitpoints", p.CharLevel, p.XP, p.MaxHitpoints)
+	}
+	if len(l.Monsters) != 0 {
+		t.Errorf("Expected the rat to stay dead, got %v", l.Monsters)
+	}
+	if len(l.Items[Pos{3, 2}]) != 0 || len(l.Items[Pos{1, 2}]) != 2 {
+		t.Errorf("Expected the potion gone and the rat's loot on the floor, got %v", l.Items)
+	}
+	if l.Map[1][3].OverlayRune != OpenDoor || l.Map[1][5].OverlayRune != UpStair {
+		t.Error("Expected doors and stairs to be restored")
+	}
+	if loaded.Stats.NotesHit != 5 || l.Stats != loaded.Stats {
+		t.Errorf("Expected run stats to be restored, got %+v", loaded.Stats)
+	}
+	if texts := messageTexts(l); len(texts) != 1 || texts[0] != "You picked up 1x Health Potion" || l.Log != loaded.Levels["second"].Log {
+		t.Errorf("Expected the message log to be restored and shared, got %v", texts)
+	}
+	second := loaded.Levels["second"]
+	if len(second.Monsters) != 1 {
+		t.Error("Expected the spider on the other level to still be there")
+	}
+	if loaded.Ticks != 12 || !second.Offscreen || second.LastSeen == nil || *second.LastSeen != (Pos{2, 1}) || second.LeftAt != 3 {
+		t.Errorf("Expected the other level to remember the player leaving, got %v at tick %d", second.LastSeen, second.LeftAt)
+	}
+
+	if r := loaded.Replay.Events; len(r) != 1 || r[0].Typ != PickUp || r[0].Who != p.Name || r[0].Item != "Health Potion" {
+		t.Errorf("Expected the replay so far to be restored, got %+v", r)
+	}
+
+	// Dying ends the run, and leaves the replay
+	loaded.Ticks = 20
+	loaded.CurrentLevel.Emit(Event{Typ: Kill, Who: &NewRat(p.Pos).Character, Target: &p.Character, Pos: p.Pos})
+	loaded.CurrentLevel.Kill(&p.Character)
+	if err := loaded.saveOrForget(); err != nil {
+		t.Fatal(err)
+	}
+	if SaveExists(game.SavePath) {
+		t.Error("Expected the save to be deleted when the player dies")
+	}
+	data, err := os.ReadFile(replayPath(game.SavePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := Replay{}
+	if err := json.Unmarshal(data, &replay); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(replay.Events); replay.Seed != 7 || n != 2 || replay.Events[n-1].Typ != Kill || replay.Events[n-1].Tick != 20 {
+		t.Errorf("Expected the whole run in the replay, got %+v", replay)
+	}
+}
+
+func TestQuitWhenSavingFails(t *testing.T) {
+	setupTestWorld(t)
+	game := NewSeededGame(1, 7)
+	// A file where the save directory should be
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	game.SavePath = filepath.Join(blocker, "save.json")
+
+	go game.Run()
+	levelChan := game.LevelChans[0]
+	<-levelChan // Discard initial state sent on Run start
+	game.InputChan <- &Input{Typ: QuitGame}
+	for range levelChan {
+		// The game still closes the channels so the UI isn't left waiting
+	}
+	if game.SaveErr == nil {
+		t.Error("Expected the failed save to be reported")
+	}
+}
@@ -0,0 +1,76 @@
+package game
+
+// Time goes by in ticks. Every tick each character gains their speed in energy, and every action costs the same.
+// A speed of 1 is one action a tick, which is how fast the player normally goes.
+const actionCost = 1.0
+
+// EffectKind is something changing how a character acts for a while
+type EffectKind int
+
+const (
+	// Haste doubles speed
+	Haste EffectKind = iota
+	// Slow halves speed
+	Slow
+)
+
+// How much each effect multiplies speed by
+var effectSpeeds = [...]float64{Haste: 2, Slow: 0.5}
+
+// Effect is a haste, slow or the like, until it wears off
+type Effect struct {
+	Kind  EffectKind `json:"kind"`
+	Ticks int        `json:"ticks"` // How long it has left
+}
+
+// AddEffect hastes, slows or the like. Getting the same effect again keeps whichever lasts longer.
+func (c *Character) AddEffect(effect Effect) {
+	for i, e := range c.Effects {
+		if e.Kind == effect.Kind {
+			c.Effects[i].Ticks = max(e.Ticks, effect.Ticks)
+			return
+		}
+	}
+	c.Effects = append(c.Effects, effect)
+}
+
+// EffectiveSpeed is how much energy a character gains a tick, with their effects
+func (c *Character) EffectiveSpeed() float64 {
+	speed := c.Speed
+	for _, e := range c.Effects {
+		speed *= effectSpeeds[e.Kind]
+	}
+	return speed
+}
+
+// tick gives a character a tick's energy, and wears their effects down
+func (c *Character) tick() {
+	c.ActionPoints += c.EffectiveSpeed()
+	effects := c.Effects[:0]
+	for _, e := range c.Effects {
+		if e.Ticks--; e.Ticks > 0 {
+			effects = append(effects, e)
+		}
+	}
+	c.Effects = effects
+}
+
+// tick runs one tick of the level. The player gains energy first, then the monsters from top to bottom, left to right,
+// so a run plays out the same every time.
+func (level *Level) tick() {
+	level.Player.tick()
+	level.monstersTick()
+}
+
+// endPlayerTurn spends the player's action, then runs time forward until they can act again.
+// What the monsters do in the meantime is part of the same turn.
+func (game *Game) endPlayerTurn() {
+	level := game.CurrentLevel
+	p := &level.Player.Character
+	p.ActionPoints = max(p.ActionPoints, actionCost) - actionCost // New players haven't waited for their first turn
+	for p.ActionPoints < actionCost && p.Hitpoints > 0 && p.EffectiveSpeed() > 0 {
+		level.tick()
+		game.Ticks++
+	}
+	level.log().Turn++
+}
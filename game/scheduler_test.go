@@ -0,0 +1,86 @@
+package game
+
+import "testing"
+
+// Monsters far enough away that they never reach the player
+func schedulerTestLevel() (*Game, *Monster, *Monster) {
+	game := createTestGame()
+	level := game.CurrentLevel
+	level.Player.Pos = Pos{14, 14}
+	rat := NewRat(Pos{0, 0})
+	spider := NewSpider(Pos{4, 0})
+	level.Monsters[rat.Pos] = rat
+	level.Monsters[spider.Pos] = spider
+	return game, rat, spider
+}
+
+// How many times each character moved this turn
+func countMoves(level *Level) map[*Character]int {
+	moves := make(map[*Character]int)
+	for _, e := range level.Events {
+		if e.Typ == Move {
+			moves[e.Who]++
+		}
+	}
+	return moves
+}
+
+func TestSchedulerSpeeds(t *testing.T) {
+	game, rat, spider := schedulerTestLevel()
+	level := game.CurrentLevel
+	level.newTurn()
+	for i := 0; i < 4; i++ {
+		game.endPlayerTurn()
+	}
+	// A rat is 1.5 times as fast as the player, and a spider twice as fast
+	moves := countMoves(level)
+	if moves[&rat.Character] != 6 || moves[&spider.Character] != 8 {
+		t.Errorf("Expected 6 rat moves and 8 spider moves in 4 turns, got %d and %d", moves[&rat.Character], moves[&spider.Character])
+	}
+	if level.Log.Turn != 4 {
+		t.Errorf("Expected 4 turns, got %d", level.Log.Turn)
+	}
+
+	// Monsters go top to bottom, left to right, whatever order the map gives them in
+	level.newTurn()
+	game.endPlayerTurn()
+	if len(level.Events) == 0 || level.Events[0].Who != &rat.Character || level.Events[len(level.Events)-1].Who != &spider.Character {
+		t.Errorf("Expected the rat to go first, got %+v", level.Events)
+	}
+}
+
+func TestSchedulerEffects(t *testing.T) {
+	game, rat, spider := schedulerTestLevel()
+	level := game.CurrentLevel
+	p := &level.Player.Character
+
+	// A slowed player gives monsters twice as long
+	p.AddEffect(Effect{Kind: Slow, Ticks: 4})
+	level.newTurn()
+	game.endPlayerTurn()
+	if moves := countMoves(level); moves[&spider.Character] != 4 {
+		t.Errorf("Expected the spider to move 4 times while the player is slowed, got %d", moves[&spider.Character])
+	}
+
+	// A hasted rat keeps up with the spider
+	rat.AddEffect(Effect{Kind: Haste, Ticks: 2})
+	rat.AddEffect(Effect{Kind: Haste, Ticks: 1}) // Doesn't cut it short
+	if len(rat.Effects) != 1 || rat.EffectiveSpeed() != 3 {
+		t.Errorf("Expected one haste doubling the rat to speed 3, got %+v", rat.Effects)
+	}
+	level.newTurn()
+	game.endPlayerTurn() // Two more ticks, the slow and haste wear off
+	if len(p.Effects) != 0 || len(rat.Effects) != 0 {
+		t.Errorf("Expected the effects to wear off, got %+v and %+v", p.Effects, rat.Effects)
+	}
+	if moves := countMoves(level); moves[&rat.Character] != 6 {
+		t.Errorf("Expected the hasted rat to move 6 times, got %d", moves[&rat.Character])
+	}
+
+	// The player doesn't wait once they're back to normal
+	level.newTurn()
+	game.endPlayerTurn()
+	if moves := countMoves(level); moves[&spider.Character] != 2 {
+		t.Errorf("Expected the spider to move twice in a normal turn, got %d", moves[&spider.Character])
+	}
+}
@@ -0,0 +1,192 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Settings are the options a player can change
+type Settings struct {
+	GlobalOffset int     `json:"global_offset"` // How many milliseconds late the music reaches your ears, so you can hit later
+	InputOffset  int     `json:"input_offset"`  // How many milliseconds late you press keys to something you see, so notes are drawn early
+	ScrollSpeed  float64 `json:"scroll_speed"`  // 1x to 8x, notes get faster and slower with the music
+	ConstantBPM  int     `json:"constant_bpm"`  // Scroll as if the music was always this tempo, ignoring ScrollSpeed. 0 is off
+
+	Width      int  `json:"width"`
+	Height     int  `json:"height"`
+	Fullscreen bool `json:"fullscreen"`
+
+	// Volumes go from 0 to MaxVolume
+	MusicVolume    int `json:"music_volume"`
+	FootstepVolume int `json:"footstep_volume"`
+	DoorVolume     int `json:"door_volume"`
+	HitsoundVolume int `json:"hitsound_volume"`
+
+	// Key names for each column, for each key mode. Modes that aren't here use the default keys.
+	KeyBindings map[int][][]string `json:"key_bindings,omitempty"`
+	// Key names for each exploring action, like "up" or "take_all". Actions that aren't here use the default keys.
+	Actions map[string][]string `json:"actions,omitempty"`
+
+	// Gamepads and dance pads the player has changed the buttons on, by their GUID
+	Pads map[string]PadBindings `json:"pads,omitempty"`
+}
+
+// PadBindings are the buttons a gamepad or dance pad uses, named like the keys are.
+// Anything that isn't here uses the default buttons for that kind of pad.
+type PadBindings struct {
+	Actions map[string][]string `json:"actions,omitempty"`
+	Columns map[int][][]string  `json:"columns,omitempty"`
+}
+
+// MaxVolume is as loud as a channel goes
+const MaxVolume = 128
+
+// Resolution is a window size in pixels
+type Resolution struct {
+	Width, Height int
+}
+
+// Resolutions players can pick from, the first is the default
+var Resolutions = []Resolution{{780, 480}, {1024, 640}, {1280, 800}, {1600, 1000}, {1920, 1200}}
+
+// DefaultSettings are what players start with
+func DefaultSettings() *Settings {
+	return &Settings{
+		Width:          Resolutions[0].Width,
+		Height:         Resolutions[0].Height,
+		MusicVolume:    MaxVolume,
+		FootstepVolume: 5,
+		DoorVolume:     10,
+		HitsoundVolume: 40,
+	}
+}
+
+// Speed mods players can pick from
+const (
+	MinScrollSpeed  = 1.0
+	MaxScrollSpeed  = 8.0
+	ScrollSpeedStep = 0.5
+)
+
+// ScrollBPM is how many beats a minute scroll past. XMods multiply the music's tempo, CMods replace it.
+func (s *Settings) ScrollBPM(musicBPM int) float64 {
+	if s.ConstantBPM > 0 {
+		return float64(s.ConstantBPM)
+	}
+	speed := min(max(s.ScrollSpeed, MinScrollSpeed), MaxScrollSpeed) // Old settings files don't have a speed
+	return float64(musicBPM) * speed
+}
+
+// DefaultSettingsPath is where settings are kept between runs
+func DefaultSettingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lyns-rhythm-dungeon", "settings.json"), nil
+}
+
+// LoadSettings reads settings from a file. A missing file just means defaults, and so do missing fields.
+func LoadSettings(filename string) (*Settings, error) {
+	settings := DefaultSettings()
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, settings); err != nil {
+		return DefaultSettings(), err
+	}
+	return settings, nil
+}
+
+// Save writes settings to a file, making the directory if it isn't there yet
+func (s *Settings) Save(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// LoadDefaultSettings reads settings from the default path, or uses defaults if there's nowhere to keep them.
+// The path is empty when settings can't be saved. A file that can't be read still gives defaults and its path,
+// so saving fixes it, along with the error to tell the player about.
+func LoadDefaultSettings() (*Settings, string, error) {
+	path, err := DefaultSettingsPath()
+	if err != nil {
+		return DefaultSettings(), "", nil
+	}
+	settings, err := LoadSettings(path)
+	return settings, path, err
+}
+
+// Load settings from the default path and share them with every level
+func (game *Game) loadSettings() {
+	var err error
+	game.Settings, game.SettingsPath, err = LoadDefaultSettings()
+	if err != nil {
+		game.Log.Add(SystemMessage, "Couldn't load settings, using defaults: "+err.Error())
+	}
+	for _, level := range game.Levels {
+		level.Settings = game.Settings
+	}
+}
+
+// Copy over the current settings instead of replacing them, since clocks keep a pointer to them
+func (game *Game) updateSettings(settings *Settings) {
+	*game.Settings = *settings
+	if game.SettingsPath == "" {
+		return
+	}
+	if err := game.Settings.Save(game.SettingsPath); err != nil {
+		game.CurrentLevel.AddMessage(SystemMessage, "Couldn't save settings: "+err.Error())
+	}
+}
+
+// Calibration needs enough taps close to the beat to be trusted
+const (
+	minCalibrationTaps = 8
+	maxCalibrationErr  = 200 // Taps further than this from a beat are ignored
+)
+
+// Calibrate works out how late someone taps along to a metronome.
+// Each tap is matched to the nearest beat, and the offsets are averaged.
+// Returns false if there weren't enough good taps.
+func Calibrate(beats, taps []int) (int, bool) {
+	if len(beats) == 0 {
+		return 0, false
+	}
+	total := 0
+	count := 0
+	for _, tap := range taps {
+		nearest := tap - beats[0]
+		for _, beat := range beats[1:] {
+			if offset := tap - beat; abs(offset) < abs(nearest) {
+				nearest = offset
+			}
+		}
+		if abs(nearest) <= maxCalibrationErr {
+			total += nearest
+			count++
+		}
+	}
+	if count < minCalibrationTaps {
+		return 0, false
+	}
+	return total / count, true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,141 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettingsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nested", "settings.json")
+
+	// Missing files are just defaults
+	settings, err := LoadSettings(filename)
+	if err != nil || !reflect.DeepEqual(settings, DefaultSettings()) {
+		t.Fatalf("Expected default settings, got %+v (%v)", settings, err)
+	}
+
+	settings.GlobalOffset = 42
+	settings.InputOffset = -7
+	settings.Fullscreen = true
+	settings.MusicVolume = 0
+	settings.KeyBindings = map[int][][]string{4: {{"A"}, {"S"}, {"Up", "K"}, {"Right"}}}
+	settings.Actions = map[string][]string{"up": {"Up", "W"}, "menu": {}}
+	if err := settings.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSettings(filename)
+	if err != nil || !reflect.DeepEqual(loaded, settings) {
+		t.Errorf("Expected %+v after saving, got %+v (%v)", settings, loaded, err)
+	}
+
+	if err := os.WriteFile(filename, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSettings(filename); err == nil {
+		t.Error("Expected an error for a broken settings file")
+	}
+}
+
+func TestOldSettingsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(filename, []byte(`{"global_offset": 12, "scroll_speed": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings added since then get their defaults
+	settings, err := LoadSettings(filename)
+	want := DefaultSettings()
+	want.GlobalOffset = 12
+	want.ScrollSpeed = 2
+	if err != nil || !reflect.DeepEqual(settings, want) {
+		t.Errorf("Expected %+v, got %+v (%v)", want, settings, err)
+	}
+}
+
+func TestCorruptDefaultSettings(t *testing.T) {
+	setupTestWorld(t)
+	path, err := DefaultSettingsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The game still starts, with defaults it can save over the broken file
+	game := NewGame(1)
+	if !reflect.DeepEqual(game.Settings, DefaultSettings()) || game.SettingsPath != path {
+		t.Errorf("Expected default settings kept at %s, got %+v at %s", path, game.Settings, game.SettingsPath)
+	}
+	if texts := messageTexts(game.CurrentLevel); len(texts) != 1 || !strings.HasPrefix(texts[0], "Couldn't load settings") {
+		t.Errorf("Expected the broken settings to be logged, got %v", texts)
+	}
+}
+
+func TestUpdateSettings(t *testing.T) {
+	game := createTestGame()
+	game.Settings = &Settings{}
+	game.SettingsPath = filepath.Join(t.TempDir(), "settings.json")
+	clock := &FakeClock{}
+	game.SetClock(clock)
+	clock.Set(1000)
+
+	game.handleInput(&Input{Typ: UpdateSettings, Settings: &Settings{GlobalOffset: 25}})
+
+	// The judge picks up the new offset straight away
+	if pos := game.CurrentLevel.SongPosition(); pos != 975 {
+		t.Errorf("Expected song position 975, got %d", pos)
+	}
+	saved, err := LoadSettings(game.SettingsPath)
+	if err != nil || saved.GlobalOffset != 25 {
+		t.Errorf("Expected saved offset 25, got %+v (%v)", saved, err)
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	beats := []int{1000, 1500, 2000, 2500, 3000, 3500, 4000, 4500, 5000, 5500}
+
+	// Consistently 30ms late, with a bit of wobble and one stray tap
+	taps := []int{1030, 1525, 2035, 2530, 3030, 3525, 4035, 4530, 5030, 5525, 5250}
+	offset, ok := Calibrate(beats, taps)
+	if !ok || offset < 28 || offset > 31 {
+		t.Errorf("Expected about 30ms, got %d (%v)", offset, ok)
+	}
+
+	// Early taps give a negative offset
+	offset, ok = Calibrate(beats, []int{960, 1460, 1960, 2460, 2960, 3460, 3960, 4460})
+	if !ok || offset != -40 {
+		t.Errorf("Expected -40ms, got %d (%v)", offset, ok)
+	}
+
+	// Not enough taps
+	if _, ok := Calibrate(beats, []int{1000, 1500}); ok {
+		t.Error("Expected calibration to fail with only 2 taps")
+	}
+}
+
+func TestScrollBPM(t *testing.T) {
+	tests := []struct {
+		settings Settings
+		musicBPM int
+		expected float64
+	}{
+		{Settings{}, 120, 120},                                  // Old settings files default to 1x
+		{Settings{ScrollSpeed: 2}, 120, 240},                    // XMods follow the music
+		{Settings{ScrollSpeed: 2}, 150, 300},                    //
+		{Settings{ScrollSpeed: 20}, 100, 800},                   // Capped at 8x
+		{Settings{ScrollSpeed: 3, ConstantBPM: 400}, 150, 400},  // CMods ignore the music
+		{Settings{ScrollSpeed: 3, ConstantBPM: 400}, 1000, 400}, //
+	}
+	for _, tc := range tests {
+		if bpm := tc.settings.ScrollBPM(tc.musicBPM); bpm != tc.expected {
+			t.Errorf("%+v at %dbpm: expected %v, got %v", tc.settings, tc.musicBPM, tc.expected, bpm)
+		}
+	}
+}
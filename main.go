@@ -3,17 +3,12 @@ package main
 import (
 	"runtime"
 
-	"github.com/maxproske/lyns-rhythm-dungeon/game"
 	"github.com/maxproske/lyns-rhythm-dungeon/ui2d"
 )
 
 func main() {
-	// Make new game
-	game := game.NewGame(1)
-	go game.Run()
-
-	// Make our UI
+	// Make our UI, which starts games from its title menu
 	runtime.LockOSThread()
-	ui := ui2d.NewUI(game.InputChan, game.LevelChans[0])
+	ui := ui2d.NewUI()
 	ui.Run()
 }
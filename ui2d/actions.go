@@ -0,0 +1,139 @@
+package ui2d
+
+import (
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// action is something the player does while exploring. Battles use column keys instead, see keyBindings.
+type action int
+
+const (
+	actionUp action = iota
+	actionDown
+	actionLeft
+	actionRight
+	actionUpLeft
+	actionUpRight
+	actionDownLeft
+	actionDownRight
+	actionTakeAll
+	actionInventory
+	actionCalibrate
+	actionSlower
+	actionFaster
+	actionMenu
+	actionZoomIn
+	actionZoomOut
+	actionMessageLog
+	numActions
+)
+
+// Names are what settings files call each action, so they can't change
+var actionNames = [numActions]string{"up", "down", "left", "right", "up_left", "up_right", "down_left", "down_right", "take_all", "inventory", "calibrate", "slower", "faster", "menu", "zoom_in", "zoom_out", "message_log"}
+
+// What the options menu calls each action
+var actionTitles = [numActions]string{"Up", "Down", "Left", "Right", "Up left", "Up right", "Down left", "Down right", "Take all", "Inventory", "Calibrate", "Slower notes", "Faster notes", "Menu", "Zoom in", "Zoom out", "Message log"}
+
+// actionMap is the keys bound to each action. Like columns, an action can have more than one key.
+type actionMap [][]uint8
+
+func defaultActionMap() actionMap {
+	return actionMap{
+		actionUp:         {sdl.SCANCODE_UP, sdl.SCANCODE_W},
+		actionDown:       {sdl.SCANCODE_DOWN, sdl.SCANCODE_S},
+		actionLeft:       {sdl.SCANCODE_LEFT, sdl.SCANCODE_A},
+		actionRight:      {sdl.SCANCODE_RIGHT, sdl.SCANCODE_D},
+		actionUpLeft:     {sdl.SCANCODE_KP_7}, // Diagonals only work in eight-way mode
+		actionUpRight:    {sdl.SCANCODE_KP_9},
+		actionDownLeft:   {sdl.SCANCODE_KP_1},
+		actionDownRight:  {sdl.SCANCODE_KP_3},
+		actionTakeAll:    {sdl.SCANCODE_T},
+		actionInventory:  {sdl.SCANCODE_I},
+		actionCalibrate:  {sdl.SCANCODE_C},
+		actionSlower:     {sdl.SCANCODE_MINUS},
+		actionFaster:     {sdl.SCANCODE_EQUALS},
+		actionMenu:       {sdl.SCANCODE_ESCAPE},
+		actionZoomIn:     {sdl.SCANCODE_RIGHTBRACKET},
+		actionZoomOut:    {sdl.SCANCODE_LEFTBRACKET},
+		actionMessageLog: {sdl.SCANCODE_L},
+	}
+}
+
+// actionMapFrom starts from the default keys and swaps in the actions the player has changed
+func actionMapFrom(names map[string][]string) actionMap {
+	actions := defaultActionMap()
+	for a, name := range actionNames {
+		if keyNames, ok := names[name]; ok {
+			actions[a] = scancodesFrom(keyNames)
+		}
+	}
+	return actions
+}
+
+// setActionKeys writes every action's keys into a copy of the settings
+func setActionKeys(settings *game.Settings, actions actionMap) *game.Settings {
+	changed := *settings
+	changed.Actions = make(map[string][]string, numActions)
+	for a, scancodes := range actions {
+		changed.Actions[actionNames[a]] = scancodeNames(scancodes)
+	}
+	return &changed
+}
+
+// resetActions puts every action back on its default keys
+func resetActions(settings *game.Settings) *game.Settings {
+	changed := *settings
+	changed.Actions = nil
+	return &changed
+}
+
+// actionDown is true on the frame any of an action's keys or pad buttons is pressed
+func (ui *ui) actionDown(a action) bool {
+	for _, scancode := range ui.actions[a] {
+		if ui.keyDownOnce(scancode) {
+			return true
+		}
+	}
+	return ui.pads.actionDown(a)
+}
+
+// addKey puts a key or pad button on one slot, taking it off the others so each one only does one thing
+func addKey[K comparable](slots [][]K, slot int, key K) [][]K {
+	changed := make([][]K, len(slots))
+	for i, scancodes := range slots {
+		for _, other := range scancodes {
+			if other != key {
+				changed[i] = append(changed[i], other)
+			}
+		}
+	}
+	changed[slot] = append(changed[slot], key)
+	return changed
+}
+
+// clearKeys takes everything off one slot
+func clearKeys[K comparable](slots [][]K, slot int) [][]K {
+	changed := make([][]K, len(slots))
+	copy(changed, slots)
+	changed[slot] = nil
+	return changed
+}
+
+func scancodesFrom(names []string) []uint8 {
+	scancodes := make([]uint8, 0, len(names))
+	for _, name := range names {
+		if scancode := sdl.GetScancodeFromName(name); scancode != sdl.SCANCODE_UNKNOWN {
+			scancodes = append(scancodes, uint8(scancode))
+		}
+	}
+	return scancodes
+}
+
+func scancodeNames(scancodes []uint8) []string {
+	names := make([]string, len(scancodes))
+	for i, scancode := range scancodes {
+		names[i] = sdl.GetScancodeName(sdl.Scancode(scancode))
+	}
+	return names
+}
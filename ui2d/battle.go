@@ -1,27 +1,35 @@
 package ui2d
 
 import (
+	"strconv"
+
 	"github.com/maxproske/lyns-rhythm-dungeon/game"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
 // DrawBurst renders a short pattern of arrows on the battle UI
 // TODO(Max): Draw the attacker's burst first. (player -> character)
-func (ui *ui) DrawBurst(c, defender *game.Character) {
+func (ui *ui) DrawBurst(c, defender *game.Character, now int, settings *game.Settings) {
 
 	// Dim the lights
 	ui.renderer.Copy(ui.dimOverlay, nil, nil) // Stretch to fit
 
-	colors := [4]int{1, 4, 2, 4}
+	keys := burstKeys(c.Burst)
+	layout := getNoteskinLayout(keys)
+	scale := ui.layoutScale() // Everything below is sized for the smallest window
+	colWidth := layout.columnWidth * scale
+	noteHeight := noteskinHeight * scale
+	spriteSize := tileSize * scale
+	scrollBPM := settings.ScrollBPM(c.Burst.Tempo())
 
 	// For now, always draw the players's burst first
-	offsetX := int32(ui.winWidth/2 - (game.NumKeys*20)/2) // Cast int to int32 since we will always use it as int32
-	offsetY := int32(ui.winHeight/2 - (game.NumKeys*24)/2)
+	offsetX := int32(ui.winWidth/2) - int32(keys)*colWidth/2 // Cast int to int32 since we will always use it as int32
+	offsetY := int32(ui.winHeight/2) - game.NumKeys*24*scale/2
 
 	// Draw black playfield with white border
-	padding := int32(24)
-	borderWidth := int32(1)
-	playfieldRect := sdl.Rect{offsetX - padding, offsetY - padding/2, game.NumKeys*24 + padding*2, int32(c.Burst.MaxCombo+1)*20 + padding*3}
+	padding := 24 * scale
+	borderWidth := scale
+	playfieldRect := sdl.Rect{offsetX - padding, offsetY - padding/2, int32(keys)*colWidth + padding*2, int32(c.Burst.MaxCombo+1)*noteHeight + padding*3}
 	battleBackgroundRect := sdl.Rect{playfieldRect.X - borderWidth, playfieldRect.Y - borderWidth, playfieldRect.W + borderWidth*2, playfieldRect.H + borderWidth*2}
 	if c.Name == "You" {
 		ui.renderer.Copy(ui.battleBorderPlayer, nil, &battleBackgroundRect)
@@ -32,25 +40,25 @@ func (ui *ui) DrawBurst(c, defender *game.Character) {
 	ui.renderer.Copy(ui.playfieldBackground, nil, &playfieldRect)
 
 	// Draw versus context
-	yOffset := int32(60)
-	xCenter := int32(24)
+	yOffset := 60 * scale
+	xCenter := 24 * scale
 	// Draw attacker
 	c1SrcRect := ui.textureIndex[c.Rune][0]
-	ui.renderer.Copy(ui.textureAtlas, &c1SrcRect, &sdl.Rect{playfieldRect.X + xCenter, playfieldRect.Y - yOffset, 32, 32})
+	ui.renderer.Copy(ui.textureAtlas, &c1SrcRect, &sdl.Rect{playfieldRect.X + xCenter, playfieldRect.Y - yOffset, spriteSize, spriteSize})
 	// Draw attcker weapon
 	if c.Weapon != nil {
 		weaponSrcRect := ui.textureIndex[c.Weapon.Rune][0]
-		ui.renderer.Copy(ui.textureAtlas, &weaponSrcRect, &sdl.Rect{playfieldRect.X + playfieldRect.W/2 - 32/2, playfieldRect.Y - yOffset, 32, 32})
+		ui.renderer.Copy(ui.textureAtlas, &weaponSrcRect, &sdl.Rect{playfieldRect.X + playfieldRect.W/2 - spriteSize/2, playfieldRect.Y - yOffset, spriteSize, spriteSize})
 	}
 	// Draw defender
 	c2SrcRect := ui.textureIndex[defender.Rune][0]
-	ui.renderer.Copy(ui.textureAtlas, &c2SrcRect, &sdl.Rect{playfieldRect.X + playfieldRect.W - 32 - xCenter, playfieldRect.Y - yOffset, 32, 32})
+	ui.renderer.Copy(ui.textureAtlas, &c2SrcRect, &sdl.Rect{playfieldRect.X + playfieldRect.W - spriteSize - xCenter, playfieldRect.Y - yOffset, spriteSize, spriteSize})
 	// Draw hitpoints
-	hpXOffset := int32(-16)
-	hpYOffset := int32(40)
+	hpXOffset := -16 * scale
+	hpYOffset := 40 * scale
 	hpSrcRect := ui.textureIndex['<'][0]
-	attackerHPDstRect := sdl.Rect{playfieldRect.X + xCenter + hpXOffset, playfieldRect.Y - yOffset + hpYOffset, 32, 32}
-	defenderHPDstRect := sdl.Rect{playfieldRect.X + playfieldRect.W - 32 - xCenter + hpXOffset, playfieldRect.Y - yOffset + hpYOffset, 32, 32}
+	attackerHPDstRect := sdl.Rect{playfieldRect.X + xCenter + hpXOffset, playfieldRect.Y - yOffset + hpYOffset, spriteSize, spriteSize}
+	defenderHPDstRect := sdl.Rect{playfieldRect.X + playfieldRect.W - spriteSize - xCenter + hpXOffset, playfieldRect.Y - yOffset + hpYOffset, spriteSize, spriteSize}
 	ui.renderer.Copy(ui.textureAtlas, &hpSrcRect, &attackerHPDstRect)
 	ui.renderer.Copy(ui.textureAtlas, &hpSrcRect, &defenderHPDstRect)
 
@@ -60,47 +68,170 @@ func (ui *ui) DrawBurst(c, defender *game.Character) {
 
 	// Draw receptors
 	srcRect := ui.noteskinIndex[game.Receptor][0]
-	for i := 0; i < game.NumKeys; i++ {
-		dstRect := sdl.Rect{int32(i*24) + offsetX, int32(0) + offsetY, 24, 20}
+	for i := 0; i < keys; i++ {
+		dstRect := sdl.Rect{int32(i)*colWidth + offsetX, int32(0) + offsetY, colWidth, noteHeight}
 		if c.Name == "You" {
 			ui.noteskinAtlas.SetColorMod(255, 255, 255)
+			// Flash and pop out a little when pressed
+			if ui.receptorFlashing(i) {
+				ui.noteskinAtlas.SetColorMod(255, 255, 128)
+				dstRect = sdl.Rect{dstRect.X - 2*scale, dstRect.Y - 2*scale, dstRect.W + 4*scale, dstRect.H + 4*scale}
+			}
 		} else {
 			ui.noteskinAtlas.SetColorMod(255, 0, 0)
 		}
 		ui.renderer.Copy(ui.noteskinAtlas, &srcRect, &dstRect)
 	}
+	ui.noteskinAtlas.SetColorMod(255, 255, 255)
+
+	// Notes scroll up to the receptors in time with the music, and get cut off at the edge of the playfield
+	ui.renderer.SetClipRect(&playfieldRect)
+	defer ui.renderer.SetClipRect(nil)
+
+	// Holds that are being held shrink into the receptors
+	for _, held := range c.Burst.Held {
+		ui.noteskinAtlas.SetColorMod(255, 255, 255)
+		ui.drawHoldBody(held.Note, int32(held.Column)*colWidth+offsetX, offsetY, noteY(c.Burst, now, held.EndTime, scrollBPM)*scale+offsetY, colWidth, noteHeight)
+	}
 
-	for noteIndex, columnIndex := range c.Burst.Notes {
+	hitIndex := 0 // Mines don't use up stamina
+	for noteIndex, note := range c.Burst.Notes {
 		// Get note colour
 		noteskinIndex := 0 // Uncoloured for monsters
 		if c.Name == "You" {
-			noteskinIndex = colors[(noteIndex+c.Burst.Combo)%game.NumKeys] // Coloured for player
-			if noteIndex < c.Stamina {
+			noteskinIndex = layout.colors[(noteIndex+c.Burst.Combo)%len(layout.colors)] // Coloured for player
+			if layout.byColumn {
+				noteskinIndex = layout.colors[note.Column]
+			}
+			if hitIndex < c.Stamina {
 				ui.noteskinAtlas.SetColorMod(255, 255, 255)
 			} else {
 				ui.noteskinAtlas.SetColorMod(64, 64, 64)
 			}
 		} else {
-			if noteIndex < c.Stamina {
+			if hitIndex < c.Stamina {
 				ui.noteskinAtlas.SetColorMod(255, 0, 0)
 			} else {
 				ui.noteskinAtlas.SetColorMod(64, 0, 0)
 			}
 		}
+		x := int32(note.Column)*colWidth + offsetX
+		y := noteY(c.Burst, now, note.Time, scrollBPM)*scale + offsetY
+		if note.Typ == game.HoldNote || note.Typ == game.RollNote {
+			ui.drawHoldBody(note, x, y, noteY(c.Burst, now, note.EndTime, scrollBPM)*scale+offsetY, colWidth, noteHeight)
+		}
+
 		noteskinRune := getRuneFromNoteskinIndex(noteskinIndex)
+		if note.Typ == game.MineNote {
+			noteskinRune = game.MineSprite
+		} else {
+			hitIndex++
+		}
 		srcRect := ui.noteskinIndex[noteskinRune][0]
-		dstRect := sdl.Rect{int32(columnIndex*24) + offsetX, int32((noteIndex+1)*20) + offsetY, 24, 20}
+		dstRect := sdl.Rect{x, y, colWidth, noteHeight}
 		ui.renderer.Copy(ui.noteskinAtlas, &srcRect, &dstRect)
 	}
+	ui.noteskinAtlas.SetColorMod(255, 255, 255)
+
+	// Judgement and combo sit under the receptors, on top of the notes going past
+	ui.drawJudgement(c.Burst, now, &playfieldRect, offsetY)
+	ui.drawScrollSpeed(settings, &playfieldRect)
+}
+
+// Draw the body under the head, one tile at a time down to the end
+func (ui *ui) drawHoldBody(note game.Note, x, y, endY, colWidth, noteHeight int32) {
+	bodyRune := game.HoldBody
+	if note.Typ == game.RollNote {
+		bodyRune = game.RollBody
+	}
+	bodySrcRect := ui.noteskinIndex[bodyRune][0]
+	for bodyY := y + noteHeight/2; bodyY < endY; bodyY += noteHeight {
+		bodyDstRect := sdl.Rect{x, bodyY, colWidth, min(noteHeight, endY-bodyY)}
+		ui.renderer.Copy(ui.noteskinAtlas, &bodySrcRect, &bodyDstRect)
+	}
+	endSrcRect := ui.noteskinIndex[game.HoldEnd][0]
+	ui.renderer.Copy(ui.noteskinAtlas, &endSrcRect, &sdl.Rect{x, endY, colWidth, noteHeight})
+}
+
+// A beat is 40px at 1x, so a 120bpm stream is one note every 20px. Bigger windows multiply it by the layout scale.
+const pixelsPerBeat = 40
+
+// How tall a note is on the noteskin
+const noteskinHeight = 20
+
+// noteY is how far below the receptors a note is, scrolling scrollBPM beats a minute
+func noteY(b *game.Burst, now, time int, scrollBPM float64) int32 {
+	return int32(float64(b.Start+time-now) * scrollBPM / 60000 * pixelsPerBeat)
+}
+
+// How long judgements and receptor flashes stay on screen
+const (
+	judgementDisplayTime = 500
+	receptorFlashTime    = 100
+)
+
+var judgementColors = [game.NumJudgements]sdl.Color{
+	game.Perfect: {255, 255, 128, 0},
+	game.Great:   {128, 255, 128, 0},
+	game.Good:    {128, 192, 255, 0},
+	game.Boo:     {192, 128, 255, 0},
+	game.Miss:    {255, 64, 64, 0},
+}
+
+// drawJudgement shows how the last note was hit, and the combo if there is one
+func (ui *ui) drawJudgement(b *game.Burst, now int, playfieldRect *sdl.Rect, offsetY int32) {
+	if b.LastJudgement == game.NoJudgement || now-b.LastJudgedAt > judgementDisplayTime {
+		return
+	}
+	centerX := playfieldRect.X + playfieldRect.W/2
+	y := offsetY + 40*ui.layoutScale()
+	tex := ui.stringToTexture(b.LastJudgement.String(), judgementColors[b.LastJudgement], FontLarge)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{centerX - w/2, y, w, h})
+	if b.Streak > 1 {
+		tex = ui.stringToTexture(strconv.Itoa(b.Streak)+" combo", sdl.Color{255, 255, 255, 0}, FontMedium)
+		_, _, cw, ch, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{centerX - cw/2, y + h, cw, ch})
+	}
+}
+
+// drawScrollSpeed shows the speed mod in the corner of the playfield
+func (ui *ui) drawScrollSpeed(settings *game.Settings, playfieldRect *sdl.Rect) {
+	tex := ui.stringToTexture(speedModName(settings), sdl.Color{128, 128, 128, 0}, FontSmall)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{playfieldRect.X + playfieldRect.W - w - 2, playfieldRect.Y + playfieldRect.H - h - 2, w, h})
+}
+
+// speedModName is how rhythm games usually write speed mods, like 1.5x or C400
+func speedModName(settings *game.Settings) string {
+	if settings.ConstantBPM > 0 {
+		return "C" + strconv.Itoa(settings.ConstantBPM)
+	}
+	speed := min(max(settings.ScrollSpeed, game.MinScrollSpeed), game.MaxScrollSpeed)
+	return strconv.FormatFloat(speed, 'f', -1, 64) + "x"
+}
+
+// Step the speed mod up or down, leaving the rest of the settings alone
+func changeScrollSpeed(settings *game.Settings, steps int) *game.Settings {
+	changed := *settings
+	speed := min(max(changed.ScrollSpeed, game.MinScrollSpeed), game.MaxScrollSpeed)
+	changed.ScrollSpeed = min(max(speed+float64(steps)*game.ScrollSpeedStep, game.MinScrollSpeed), game.MaxScrollSpeed)
+	changed.ConstantBPM = 0 // Picking an XMod turns the CMod off
+	return &changed
+}
+
+func (ui *ui) receptorFlashing(col int) bool {
+	return col < len(ui.receptorPressed) && sdl.GetTicks64()-ui.receptorPressed[col] < receptorFlashTime
 }
 
 func (ui *ui) drawHitpoints(value int, heartRect *sdl.Rect) {
 	digits := ui.getSliceFromInt(value)
+	scale := heartRect.W / tileSize // Digits grow with the heart
 	for i, digit := range digits {
 		digitSrcRect := ui.textureIndex['0'][digit]
-		digitXInterval := int32((len(digits) - 1 - i) * 10) // Print digits from left to right
-		digitXOffset := int32(23)
-		digitYOffset := int32(-12)
+		digitXInterval := int32(len(digits)-1-i) * 10 * scale // Print digits from left to right
+		digitXOffset := 23 * scale
+		digitYOffset := -12 * scale
 		digitDstRect := sdl.Rect{heartRect.X + digitXOffset + digitXInterval, heartRect.Y + digitYOffset, heartRect.W, heartRect.H}
 		ui.renderer.Copy(ui.textureAtlas, &digitSrcRect, &digitDstRect)
 	}
@@ -144,8 +275,60 @@ func getRuneFromNoteskinIndex(i int) rune {
 	}
 }
 
+// How long the result of a turn stays up
+const battleResultDisplayTime = 2500
+
+func (ui *ui) showBattleResult(result *game.BattleResult) {
+	if result == nil {
+		return // Nothing was played
+	}
+	ui.battleResult = result
+	ui.battleResultAt = sdl.GetTicks64()
+}
+
+// DrawBattleResult shows a small box with how the last turn went, until it times out
+func (ui *ui) DrawBattleResult() {
+	if sdl.GetTicks64()-ui.battleResultAt > battleResultDisplayTime {
+		ui.battleResult = nil
+		return
+	}
+	r := ui.battleResult
+	title := "You vs the " + r.Defender
+	if r.Attacker != "You" {
+		title = "The " + r.Attacker + " vs you"
+	}
+	lines := []string{
+		title,
+		"Grade " + r.Grade + "  " + strconv.FormatFloat(r.Accuracy, 'f', 1, 64) + "%",
+		strconv.Itoa(r.Hits) + " hit  " + strconv.Itoa(r.Misses) + " missed  " + strconv.Itoa(r.MaxCombo) + " max combo",
+		"Damage dealt " + strconv.Itoa(r.DamageDealt) + "  taken " + strconv.Itoa(r.DamageTaken),
+	}
+	textures := make([]*sdl.Texture, len(lines))
+	width, height := int32(0), int32(0)
+	for i, line := range lines {
+		textures[i] = ui.stringToTexture(line, sdl.Color{255, 255, 255, 0}, FontSmall)
+		_, _, w, h, _ := textures[i].Query()
+		width = max(width, w)
+		height += h
+	}
+	padding := int32(8)
+	boxRect := sdl.Rect{int32(ui.winWidth)/2 - width/2 - padding, padding, width + padding*2, height + padding*2}
+	ui.renderer.Copy(ui.eventBackground, nil, &boxRect)
+	y := boxRect.Y + padding
+	for _, tex := range textures {
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+		y += h
+	}
+}
+
 // DrawBattle renders the battle screen
 func (ui *ui) DrawBattle(level *game.Level) {
 	// Draw the attacker's burst first
-	ui.DrawBurst(level.Battle.C1, level.Battle.C2)
+	settings := level.Settings
+	if settings == nil {
+		settings = &game.Settings{}
+	}
+	now := level.SongPosition() + settings.InputOffset // Notes arrive a little early to make up for slow fingers and screens
+	ui.DrawBurst(level.Battle.C1, level.Battle.C2, now, settings)
 }
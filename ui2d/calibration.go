@@ -0,0 +1,156 @@
+package ui2d
+
+import (
+	"strconv"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/mix"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Calibration plays a metronome twice, once as clicks and once as flashes.
+// Tapping along to the clicks gives the audio offset, and tapping along to the flashes gives the input offset.
+type calibrationPhase int
+
+const (
+	calibrateAudio calibrationPhase = iota
+	calibrateVisual
+	calibrateDone
+)
+
+const (
+	calibrationBeats    = 16
+	calibrationInterval = 500 // 120bpm
+	calibrationLeadIn   = 1000
+	calibrationFlash    = 100 // How long the flash stays on screen
+)
+
+type calibration struct {
+	phase       calibrationPhase
+	start       uint64 // Ticks when the phase started
+	nextBeat    int
+	taps        []int
+	audioOffset int
+	inputOffset int
+	audioOK     bool
+	inputOK     bool
+	returnTo    uiState // The screen calibration was started from
+}
+
+// Beat times are relative to the start of the phase
+func calibrationBeatTimes() []int {
+	beats := make([]int, calibrationBeats)
+	for i := range beats {
+		beats[i] = calibrationLeadIn + i*calibrationInterval
+	}
+	return beats
+}
+
+func (ui *ui) startCalibration() {
+	returnTo := ui.state
+	if ui.calibration != nil {
+		returnTo = ui.calibration.returnTo // Trying again
+	}
+	ui.calibration = &calibration{start: sdl.GetTicks64(), returnTo: returnTo}
+	ui.state = UICalibration
+	mix.VolumeMusic(ui.settings.MusicVolume / 8) // Quiet enough to hear the clicks
+}
+
+func (ui *ui) stopCalibration() {
+	ui.state = ui.calibration.returnTo
+	ui.calibration = nil
+	mix.VolumeMusic(ui.settings.MusicVolume)
+}
+
+// updateCalibration plays the metronome and records taps. Returns an input once the player accepts the results.
+func (ui *ui) updateCalibration() *game.Input {
+	cal := ui.calibration
+	if ui.keyDownOnce(sdl.SCANCODE_ESCAPE) {
+		ui.stopCalibration()
+		return nil
+	}
+	if cal.phase == calibrateDone {
+		if ui.keyDownOnce(sdl.SCANCODE_RETURN) {
+			settings := *ui.settings
+			if cal.audioOK {
+				settings.GlobalOffset = cal.audioOffset
+			}
+			if cal.inputOK {
+				settings.InputOffset = cal.inputOffset
+			}
+			ui.stopCalibration()
+			return &game.Input{Typ: game.UpdateSettings, Settings: &settings}
+		}
+		if ui.keyDownOnce(sdl.SCANCODE_SPACE) {
+			ui.startCalibration() // Try again
+		}
+		return nil
+	}
+
+	now := int(sdl.GetTicks64() - cal.start)
+	if ui.keyDownOnce(sdl.SCANCODE_SPACE) {
+		cal.taps = append(cal.taps, now)
+	}
+	beats := calibrationBeatTimes()
+	if cal.nextBeat < len(beats) && now >= beats[cal.nextBeat] {
+		if cal.phase == calibrateAudio {
+			playHitsound(ui.sounds.hitsound)
+		}
+		cal.nextBeat++
+	}
+	// Give the last beat time to be tapped before moving on
+	if now >= beats[len(beats)-1]+calibrationInterval {
+		offset, ok := game.Calibrate(beats, cal.taps)
+		if cal.phase == calibrateAudio {
+			cal.audioOffset, cal.audioOK = offset, ok
+		} else {
+			cal.inputOffset, cal.inputOK = offset, ok
+		}
+		cal.phase++
+		cal.start = sdl.GetTicks64()
+		cal.nextBeat = 0
+		cal.taps = cal.taps[:0]
+	}
+	return nil
+}
+
+// DrawCalibration shows instructions, the flashing metronome and the results
+func (ui *ui) DrawCalibration() {
+	cal := ui.calibration
+	ui.renderer.Copy(ui.dimOverlay, nil, nil)
+
+	lines := []string{}
+	switch cal.phase {
+	case calibrateAudio:
+		lines = append(lines, "Tap SPACE along with the clicks", "Beat "+strconv.Itoa(cal.nextBeat)+"/"+strconv.Itoa(calibrationBeats))
+	case calibrateVisual:
+		lines = append(lines, "Tap SPACE along with the flashes", "Beat "+strconv.Itoa(cal.nextBeat)+"/"+strconv.Itoa(calibrationBeats))
+		// Flash on each beat
+		beats := calibrationBeatTimes()
+		now := int(sdl.GetTicks64() - cal.start)
+		if cal.nextBeat > 0 && now-beats[cal.nextBeat-1] < calibrationFlash {
+			size := 64 * ui.layoutScale()
+			ui.renderer.Copy(ui.battleBorderPlayer, nil, &sdl.Rect{int32(ui.winWidth)/2 - size/2, int32(ui.winHeight) / 2, size, size})
+		}
+	case calibrateDone:
+		lines = append(lines, "Audio offset: "+calibrationResult(cal.audioOffset, cal.audioOK))
+		lines = append(lines, "Input offset: "+calibrationResult(cal.inputOffset, cal.inputOK))
+		lines = append(lines, "ENTER to save, SPACE to try again")
+	}
+	lines = append(lines, "ESC to cancel")
+
+	y := int32(ui.winHeight) / 4
+	for _, line := range lines {
+		tex := ui.stringToTexture(line, sdl.Color{255, 255, 255, 0}, FontMedium)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+		y += h
+	}
+}
+
+func calibrationResult(offset int, ok bool) string {
+	if !ok {
+		return "not enough taps"
+	}
+	return strconv.Itoa(offset) + "ms"
+}
@@ -0,0 +1,197 @@
+package ui2d
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// bindingMenu picks an action or column to put keys on. The first page is exploring, then one page per key mode.
+type bindingMenu struct {
+	page     int
+	selected int  // An action or column, or the reset entry after the last one
+	waiting  bool // For the next key to be pressed
+}
+
+const explorePage = 0
+
+func (ui *ui) openKeyBindings() {
+	ui.binding = &bindingMenu{}
+	ui.state = UIKeyBindings
+}
+
+// The key mode a page is for
+func pageKeys(page int) int {
+	return game.KeyModes[page-1]
+}
+
+// What can have keys put on it on this page, and what they're called
+func (ui *ui) bindingSlots(page int) ([][]uint8, []string) {
+	if page == explorePage {
+		return ui.actions, actionTitles[:]
+	}
+	keys := pageKeys(page)
+	titles := make([]string, keys)
+	for col := range titles {
+		titles[col] = "Column " + strconv.Itoa(col+1)
+	}
+	return ui.keyBindings[keys], titles
+}
+
+func (ui *ui) saveBindings(page int, slots [][]uint8) {
+	if page == explorePage {
+		ui.saveSettings(setActionKeys(ui.settings, slots))
+	} else {
+		ui.saveSettings(setColumnKeys(ui.settings, pageKeys(page), slots))
+	}
+}
+
+// A pad's buttons for what's on this page
+func padSlots(p *pad, page int) [][]string {
+	if page == explorePage {
+		return p.bindings.actions
+	}
+	keys := pageKeys(page)
+	if columns := p.bindings.columns[keys]; len(columns) == keys {
+		return columns
+	}
+	return make([][]string, keys) // Nothing on this mode yet
+}
+
+// savePadBindings keeps a pad's buttons under its GUID, so each pad remembers its own
+func (ui *ui) savePadBindings(p *pad, page int, slots [][]string) {
+	bindings := padBindings{actions: p.bindings.actions, columns: make(map[int][][]string, len(p.bindings.columns)+1)}
+	for keys, columns := range p.bindings.columns {
+		bindings.columns[keys] = columns
+	}
+	if page == explorePage {
+		bindings.actions = slots
+	} else {
+		bindings.columns[pageKeys(page)] = slots
+	}
+	ui.saveSettings(setPadBindings(ui.settings, p.guid, bindings.save()))
+}
+
+// setPadBindings puts one pad's buttons into a copy of the settings. Nil bindings forget the pad.
+func setPadBindings(settings *game.Settings, guid string, saved *game.PadBindings) *game.Settings {
+	changed := *settings
+	changed.Pads = make(map[string]game.PadBindings, len(settings.Pads)+1)
+	for g, b := range settings.Pads {
+		if g != guid {
+			changed.Pads[g] = b
+		}
+	}
+	if saved != nil {
+		changed.Pads[guid] = *saved
+	}
+	return &changed
+}
+
+func (ui *ui) updateKeyBindings() {
+	menu := ui.binding
+	slots, _ := ui.bindingSlots(menu.page)
+	numItems := len(slots) + 1 // Then reset
+	numPages := len(game.KeyModes) + 1
+
+	if menu.waiting {
+		if ui.keyDownOnce(sdl.SCANCODE_ESCAPE) {
+			menu.waiting = false
+			return
+		}
+		for key := range ui.keyboardState {
+			if ui.keyDownOnce(uint8(key)) {
+				ui.saveBindings(menu.page, addKey(slots, menu.selected, uint8(key)))
+				menu.waiting = false
+				return
+			}
+		}
+		if p, control := ui.pads.firstPress(); p != nil {
+			ui.savePadBindings(p, menu.page, addKey(padSlots(p, menu.page), menu.selected, control))
+			menu.waiting = false
+		}
+		return
+	}
+
+	switch {
+	case ui.keyDownOnce(sdl.SCANCODE_ESCAPE):
+		ui.binding = nil
+		ui.state = UIOptions
+	case ui.keyDownOnce(sdl.SCANCODE_UP):
+		menu.selected = (menu.selected + numItems - 1) % numItems
+	case ui.keyDownOnce(sdl.SCANCODE_DOWN):
+		menu.selected = (menu.selected + 1) % numItems
+	case ui.keyDownOnce(sdl.SCANCODE_LEFT):
+		menu.page = (menu.page + numPages - 1) % numPages
+		menu.selected = 0
+	case ui.keyDownOnce(sdl.SCANCODE_RIGHT):
+		menu.page = (menu.page + 1) % numPages
+		menu.selected = 0
+	case ui.keyDownOnce(sdl.SCANCODE_BACKSPACE) && menu.selected < len(slots):
+		ui.saveBindings(menu.page, clearKeys(slots, menu.selected))
+		if p := ui.pads.last; p != nil {
+			ui.savePadBindings(p, menu.page, clearKeys(padSlots(p, menu.page), menu.selected))
+		}
+	case ui.keyDownOnce(sdl.SCANCODE_RETURN):
+		if menu.selected < len(slots) {
+			menu.waiting = true
+		} else if menu.page == explorePage {
+			ui.saveSettings(resetActions(ui.settings))
+			ui.resetPad()
+		} else {
+			ui.saveSettings(resetKeys(ui.settings, pageKeys(menu.page)))
+			ui.resetPad()
+		}
+	}
+}
+
+// resetPad puts the last pad used back to its default buttons
+func (ui *ui) resetPad() {
+	if p := ui.pads.last; p != nil {
+		ui.saveSettings(setPadBindings(ui.settings, p.guid, nil))
+	}
+}
+
+// DrawKeyBindings shows the keys on each action or column, and the buttons on the last pad used
+func (ui *ui) DrawKeyBindings() {
+	menu := ui.binding
+	heading := "Explore"
+	if menu.page != explorePage {
+		heading = strconv.Itoa(pageKeys(menu.page)) + "K battles"
+	}
+	y := int32(ui.winHeight) / 16
+	tex := ui.stringToTexture("< "+heading+" >", menuColor, FontMedium)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+	y += h + h/2
+
+	slots, titles := ui.bindingSlots(menu.page)
+	var padControls [][]string
+	if ui.pads.last != nil {
+		padControls = padSlots(ui.pads.last, menu.page)
+	}
+	items := make([]string, 0, len(slots)+1)
+	for i, scancodes := range slots {
+		if menu.waiting && i == menu.selected {
+			items = append(items, titles[i]+": press a key or button")
+			continue
+		}
+		item := titles[i] + ": " + keyNames(scancodes)
+		if padControls != nil && len(padControls[i]) > 0 {
+			item += " | " + strings.Join(padControls[i], " / ")
+		}
+		items = append(items, item)
+	}
+	items = append(items, "Reset to defaults")
+	ui.drawMenu(items, menu.selected, y, FontSmall)
+
+	footer := []string{"ENTER to add a key, BACKSPACE to clear, LEFT and RIGHT for other pages, ESC to go back"}
+	if menu.waiting {
+		footer = []string{"ESC to cancel"}
+	}
+	if ui.pads.last != nil {
+		footer = append(footer, "Pad: "+ui.pads.last.name)
+	}
+	ui.drawLines(footer, int32(ui.winHeight)*7/8)
+}
@@ -0,0 +1,133 @@
+package ui2d
+
+import (
+	"strings"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// keyBindings are the scancodes for each column, for each key mode.
+// A column can have more than one key bound to it.
+type keyBindings map[int][][]uint8
+
+func defaultKeyBindings() keyBindings {
+	return keyBindings{
+		3: {{sdl.SCANCODE_LEFT, sdl.SCANCODE_F}, {sdl.SCANCODE_DOWN, sdl.SCANCODE_SPACE}, {sdl.SCANCODE_RIGHT, sdl.SCANCODE_J}},
+		4: {{sdl.SCANCODE_LEFT, sdl.SCANCODE_D}, {sdl.SCANCODE_DOWN, sdl.SCANCODE_F}, {sdl.SCANCODE_UP, sdl.SCANCODE_J}, {sdl.SCANCODE_RIGHT, sdl.SCANCODE_K}},
+		6: {{sdl.SCANCODE_S}, {sdl.SCANCODE_D}, {sdl.SCANCODE_F}, {sdl.SCANCODE_J}, {sdl.SCANCODE_K}, {sdl.SCANCODE_L}},
+		7: {{sdl.SCANCODE_S}, {sdl.SCANCODE_D}, {sdl.SCANCODE_F}, {sdl.SCANCODE_SPACE}, {sdl.SCANCODE_J}, {sdl.SCANCODE_K}, {sdl.SCANCODE_L}},
+		8: {{sdl.SCANCODE_A}, {sdl.SCANCODE_S}, {sdl.SCANCODE_D}, {sdl.SCANCODE_F}, {sdl.SCANCODE_J}, {sdl.SCANCODE_K}, {sdl.SCANCODE_L}, {sdl.SCANCODE_SEMICOLON}},
+	}
+}
+
+// noteskinLayout is how a key mode is drawn
+type noteskinLayout struct {
+	columnWidth int32
+	colors      []int // Noteskin index for each column
+	byColumn    bool  // Colour notes by their column, instead of by their order in the burst
+}
+
+// Wider modes get narrower columns so the playfield still fits
+var noteskinLayouts = map[int]noteskinLayout{
+	3: {24, []int{1, 4, 1}, true},
+	4: {24, []int{1, 4, 2, 4}, false},
+	6: {20, []int{1, 2, 1, 1, 2, 1}, true},
+	7: {18, []int{1, 2, 1, 4, 1, 2, 1}, true},
+	8: {18, []int{4, 1, 2, 1, 1, 2, 1, 4}, true},
+}
+
+// Fall back to the default keymode if we don't know how to draw it
+func getNoteskinLayout(keys int) noteskinLayout {
+	if layout, ok := noteskinLayouts[keys]; ok {
+		return layout
+	}
+	return noteskinLayouts[game.NumKeys]
+}
+
+// Number of columns a burst is played on
+func burstKeys(b *game.Burst) int {
+	if b == nil || !game.ValidKeyMode(b.Keys) {
+		return game.NumKeys
+	}
+	return b.Keys
+}
+
+// queueNoteInputs checks every column's keys. Jumps and releases can happen on the same frame,
+// and we can only send one input per frame, so they're queued up and sent one at a time.
+func (ui *ui) queueNoteInputs(keys, now int) {
+	bindings := ui.keyBindings[keys]
+	for col, scancodes := range bindings {
+		for _, scancode := range scancodes {
+			if ui.keyDownOnce(scancode) {
+				ui.pendingNotes = append(ui.pendingNotes, game.Input{Typ: game.NotePressed, Column: col, Time: now})
+				ui.receptorPressed[col] = sdl.GetTicks64()
+			} else if ui.keyPressed(scancode) {
+				ui.pendingNotes = append(ui.pendingNotes, game.Input{Typ: game.NoteReleased, Column: col, Time: now})
+			}
+		}
+	}
+	for _, p := range ui.pads.byID {
+		for col, controls := range p.bindings.columns[keys] {
+			// Pads can be quick enough to press and let go between frames
+			if p.wasPressed(controls) {
+				ui.pendingNotes = append(ui.pendingNotes, game.Input{Typ: game.NotePressed, Column: col, Time: now})
+				ui.receptorPressed[col] = sdl.GetTicks64()
+			}
+			if p.wasReleased(controls) {
+				ui.pendingNotes = append(ui.pendingNotes, game.Input{Typ: game.NoteReleased, Column: col, Time: now})
+			}
+		}
+	}
+}
+
+// keyBindingsFrom starts from the default keys and swaps in the modes the player has changed
+func keyBindingsFrom(names map[int][][]string) keyBindings {
+	bindings := defaultKeyBindings()
+	for keys, columns := range names {
+		if _, ok := bindings[keys]; !ok || len(columns) != keys {
+			continue // Not a key mode we have, or from a broken settings file
+		}
+		mode := make([][]uint8, keys)
+		for col, colNames := range columns {
+			mode[col] = scancodesFrom(colNames)
+		}
+		bindings[keys] = mode
+	}
+	return bindings
+}
+
+// setColumnKeys writes a key mode's columns into a copy of the settings
+func setColumnKeys(settings *game.Settings, keys int, columns [][]uint8) *game.Settings {
+	changed := *settings
+	changed.KeyBindings = make(map[int][][]string, len(settings.KeyBindings)+1)
+	for k, v := range settings.KeyBindings {
+		changed.KeyBindings[k] = v
+	}
+	mode := make([][]string, keys)
+	for col, scancodes := range columns {
+		mode[col] = scancodeNames(scancodes)
+	}
+	changed.KeyBindings[keys] = mode
+	return &changed
+}
+
+// resetKeys puts a mode back to its default keys
+func resetKeys(settings *game.Settings, keys int) *game.Settings {
+	changed := *settings
+	changed.KeyBindings = make(map[int][][]string, len(settings.KeyBindings))
+	for k, v := range settings.KeyBindings {
+		if k != keys {
+			changed.KeyBindings[k] = v
+		}
+	}
+	return &changed
+}
+
+// keyNames lists a column or action's keys, like "Left / D"
+func keyNames(scancodes []uint8) string {
+	if len(scancodes) == 0 {
+		return "(none)"
+	}
+	return strings.Join(scancodeNames(scancodes), " / ")
+}
@@ -0,0 +1,70 @@
+package ui2d
+
+import "github.com/veandco/go-sdl2/ttf"
+
+// The battle screen and fonts were laid out for the smallest window, and grow in whole steps from there
+const (
+	baseWidth  = 780
+	baseHeight = 480
+	tileSize   = 32 // Size of a tile on the atlas
+	maxZoom    = 4
+)
+
+// layoutScale is how many times bigger than the smallest window things get drawn. Whole steps keep the pixel art sharp.
+func layoutScale(winWidth, winHeight int) int32 {
+	return int32(max(1, min(winWidth/baseWidth, winHeight/baseHeight)))
+}
+
+func (ui *ui) layoutScale() int32 {
+	return layoutScale(ui.winWidth, ui.winHeight)
+}
+
+// tileZoom is how many pixels each map tile pixel takes up. Zero zoom follows the window size.
+func (ui *ui) tileZoom() int32 {
+	if ui.zoom > 0 {
+		return int32(ui.zoom)
+	}
+	return ui.layoutScale()
+}
+
+// changeZoom steps the map zoom in or out, starting from whatever it is now
+func changeZoom(zoom int, current int32, dir int) int {
+	if zoom == 0 {
+		zoom = int(current)
+	}
+	return min(max(zoom+dir, 1), maxZoom)
+}
+
+// Font sizes for a window size, small text grows with the width so the event log fits, the rest with the height
+func fontSizes(winWidth, winHeight int) (small, medium, large int) {
+	return int(float64(winWidth) * 0.02), winHeight * 32 / baseHeight, winHeight * 64 / baseHeight
+}
+
+// resize lays everything out again for a new window size
+func (ui *ui) resize(winWidth, winHeight int) {
+	if winWidth == ui.winWidth && winHeight == ui.winHeight && ui.fontSmall != nil {
+		return
+	}
+	ui.winWidth, ui.winHeight = winWidth, winHeight
+	ui.openFonts()
+	ui.centerX, ui.centerY = -1, -1 // Re-center the camera on the player
+}
+
+// openFonts opens the fonts at the sizes the window needs
+func (ui *ui) openFonts() {
+	small, medium, large := fontSizes(ui.winWidth, ui.winHeight)
+	for _, f := range []struct {
+		font **ttf.Font
+		size int
+	}{{&ui.fontSmall, small}, {&ui.fontMedium, medium}, {&ui.fontLarge, large}} {
+		font, err := ttf.OpenFont("ui2d/assets/gothic.ttf", f.size)
+		if err != nil {
+			panic(err)
+		}
+		if *f.font != nil {
+			(*f.font).Close()
+		}
+		*f.font = font
+	}
+	ui.textCache.clear() // Text drawn with the old fonts is the wrong size
+}
@@ -0,0 +1,148 @@
+package ui2d
+
+import (
+	"strconv"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Each kind of message gets its own color, in the console and the log
+var messageColors = [game.NumMessageKinds]sdl.Color{
+	game.CombatMessage:   {255, 0, 0, 0},
+	game.LootMessage:     {255, 220, 80, 0},
+	game.MovementMessage: {120, 180, 255, 0},
+	game.SystemMessage:   {180, 180, 180, 0},
+}
+
+// How many messages the console in the corner shows
+const consoleMessages = 10
+
+// messageLogView is the full screen log, scrolled up from the newest message
+type messageLogView struct {
+	scroll int                        // Lines up from the bottom
+	shown  [game.NumMessageKinds]bool // Kinds that aren't filtered out
+}
+
+func (ui *ui) openMessageLog() {
+	view := &messageLogView{}
+	for kind := range view.shown {
+		view.shown[kind] = true
+	}
+	ui.messageLog = view
+	ui.state = UIMessageLog
+}
+
+// How many lines fit under the heading and filters
+func (ui *ui) messageLogPage() int {
+	_, lineHeight, _ := ui.fontSmall.SizeUTF8("A")
+	return max(1, ui.winHeight*3/4/lineHeight)
+}
+
+// clampScroll keeps the log from scrolling past either end
+func clampScroll(scroll, total, page int) int {
+	return min(max(scroll, 0), max(total-page, 0))
+}
+
+// visibleMessages is the page of messages scrolled to, oldest first
+func visibleMessages(messages []game.Message, scroll, page int) []game.Message {
+	end := len(messages) - clampScroll(scroll, len(messages), page)
+	return messages[max(end-page, 0):end]
+}
+
+func (ui *ui) updateMessageLog(log *game.MessageLog) {
+	view := ui.messageLog
+	page := ui.messageLogPage()
+	switch {
+	case ui.keyDownOnce(sdl.SCANCODE_ESCAPE) || ui.actionDown(actionMessageLog):
+		ui.messageLog = nil
+		ui.state = UIMain
+		return
+	case ui.keyDownOnce(sdl.SCANCODE_UP):
+		view.scroll++
+	case ui.keyDownOnce(sdl.SCANCODE_DOWN):
+		view.scroll--
+	case ui.keyDownOnce(sdl.SCANCODE_PAGEUP):
+		view.scroll += page
+	case ui.keyDownOnce(sdl.SCANCODE_PAGEDOWN):
+		view.scroll -= page
+	case ui.keyDownOnce(sdl.SCANCODE_HOME):
+		view.scroll = len(log.Messages) // Clamped to the oldest below
+	case ui.keyDownOnce(sdl.SCANCODE_END):
+		view.scroll = 0
+	default:
+		// Number keys turn each kind on and off
+		for kind := range view.shown {
+			if ui.keyDownOnce(uint8(sdl.SCANCODE_1 + kind)) {
+				view.shown[kind] = !view.shown[kind]
+			}
+		}
+	}
+	view.scroll = clampScroll(view.scroll, len(log.Filter(view.shown)), page)
+}
+
+// DrawMessageLog shows the whole run's messages over the map
+func (ui *ui) DrawMessageLog(level *game.Level) {
+	view := ui.messageLog
+	ui.renderer.Copy(ui.dimOverlay, nil, nil)
+
+	y := int32(ui.winHeight) / 32
+	tex := ui.stringToTexture("Message log", menuColor, FontMedium)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+	y += h
+
+	// Filters, dimmed when they're off
+	x := int32(ui.winWidth) / 32
+	for kind, name := range game.MessageKindNames {
+		color := messageColors[kind]
+		if !view.shown[kind] {
+			color = sdl.Color{80, 80, 80, 0}
+		}
+		tex := ui.stringToTexture(strconv.Itoa(kind+1)+" "+name, color, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{x, y, w, h})
+		x += w + w/2
+	}
+	_, lineHeight, _ := ui.fontSmall.SizeUTF8("A")
+	y += int32(lineHeight) * 2
+
+	if level.Log != nil {
+		for _, m := range visibleMessages(level.Log.Filter(view.shown), view.scroll, ui.messageLogPage()) {
+			tex := ui.stringToTexture("Turn "+strconv.Itoa(m.Turn)+": "+m.Text, messageColors[m.Kind], FontSmall)
+			_, _, w, h, _ := tex.Query()
+			ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth) / 32, y, w, h})
+			y += int32(lineHeight)
+		}
+	}
+
+	ui.drawLines([]string{"UP, DOWN, PAGE UP and PAGE DOWN to scroll, 1-4 to filter, ESC to go back"}, int32(ui.winHeight)-int32(lineHeight)*3/2)
+}
+
+// drawStatus shows the player's level, hitpoints and experience in the top corner
+func (ui *ui) drawStatus(level *game.Level) {
+	p := level.Player
+	status := "Level " + strconv.Itoa(p.CharLevel) + "  HP " + strconv.Itoa(p.Hitpoints) + "/" + strconv.Itoa(p.MaxHitpoints) +
+		"  XP " + strconv.Itoa(p.XP) + "/" + strconv.Itoa(game.XPToNext(p.CharLevel))
+	tex := ui.stringToTexture(status, menuColor, FontSmall)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, 0, w + 10, h})
+	ui.renderer.Copy(tex, nil, &sdl.Rect{5, 0, w, h})
+}
+
+// drawConsole shows the last few messages in the corner of the map
+func (ui *ui) drawConsole(level *game.Level) {
+	textStart := int32(float64(ui.winHeight) * 0.6)
+	textWidth := int32(float64(ui.winWidth) * 0.25)
+	// nil for the source stretches one pixel to our dst
+	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, textStart, textWidth, int32(ui.winHeight) - textStart})
+	if level.Log == nil {
+		return
+	}
+	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A") // Ask how big the relative font is
+	for i, m := range level.Log.Recent(consoleMessages) {
+		tex := ui.stringToTexture(m.Text, messageColors[m.Kind], FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(i*fontSizeY) + textStart, w, h})
+	}
+}
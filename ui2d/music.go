@@ -0,0 +1,71 @@
+package ui2d
+
+import (
+	"sync"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/mix"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// musicTrack is a song and the beat grid battles line up with
+type musicTrack struct {
+	path  string
+	tempo game.Tempo // Offset is from the start of the track
+}
+
+// The dungeon theme hasn't been beat mapped, so it's taken to be 120 BPM with a beat right at the start.
+// Bursts land on that grid every time through, but it's only as close to the track's own beats as that guess.
+var dungeonTheme = musicTrack{path: "ui2d/assets/dungeon-theme.ogg", tempo: game.Tempo{BPM: 120}}
+
+// musicClock is the song position of the music that's playing, and the game's clock for battles.
+// The track is played once at a time and started again when it ends, so each time through is
+// timed from when it really started, instead of drifting further from the first one every loop.
+type musicClock struct {
+	mu     sync.Mutex // The game reads the clock from its own goroutine
+	track  musicTrack
+	music  *mix.Music
+	start  uint64 // Ticks when this time through started
+	played int    // Milliseconds of the times through before this one
+}
+
+// playMusic loads a track and starts it
+func playMusic(track musicTrack) (*musicClock, error) {
+	music, err := mix.LoadMUS(track.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := music.Play(1); err != nil {
+		return nil, err
+	}
+	return &musicClock{track: track, music: music, start: sdl.GetTicks64()}, nil
+}
+
+// loop starts the track again once it has finished, called every frame
+func (c *musicClock) loop() {
+	if mix.PlayingMusic() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := sdl.GetTicks64()
+	c.played += int(now - c.start)
+	c.start = now
+	c.music.Play(1)
+}
+
+// Now is the song position in milliseconds. It keeps counting up through every loop.
+func (c *musicClock) Now() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.played + int(sdl.GetTicks64()-c.start)
+}
+
+// Tempo is the track's beat grid, from wherever this time through started
+func (c *musicClock) Tempo() game.Tempo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tempo := c.track.tempo
+	tempo.Offset += c.played
+	return tempo
+}
@@ -0,0 +1,166 @@
+package ui2d
+
+import (
+	"strconv"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/mix"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Options menu entries
+const (
+	optionResolution = iota
+	optionFullscreen
+	optionMusicVolume
+	optionFootstepVolume
+	optionDoorVolume
+	optionHitsoundVolume
+	optionScrollSpeed
+	optionAudioOffset
+	optionInputOffset
+	optionCalibrate
+	optionKeyBindings
+	optionBack
+	numOptionItems
+)
+
+// How far left and right move the offsets and volumes
+const (
+	offsetStep = 5
+	volumeStep = 5
+)
+
+// changeOption steps a setting left or right, leaving the rest of the settings alone
+func changeOption(settings *game.Settings, option, dir int) *game.Settings {
+	changed := *settings
+	switch option {
+	case optionResolution:
+		i := (resolutionIndex(settings) + dir + len(game.Resolutions)) % len(game.Resolutions)
+		changed.Width, changed.Height = game.Resolutions[i].Width, game.Resolutions[i].Height
+	case optionFullscreen:
+		changed.Fullscreen = !changed.Fullscreen
+	case optionMusicVolume:
+		changed.MusicVolume = changeVolume(changed.MusicVolume, dir)
+	case optionFootstepVolume:
+		changed.FootstepVolume = changeVolume(changed.FootstepVolume, dir)
+	case optionDoorVolume:
+		changed.DoorVolume = changeVolume(changed.DoorVolume, dir)
+	case optionHitsoundVolume:
+		changed.HitsoundVolume = changeVolume(changed.HitsoundVolume, dir)
+	case optionScrollSpeed:
+		return changeScrollSpeed(settings, dir)
+	case optionAudioOffset:
+		changed.GlobalOffset += dir * offsetStep
+	case optionInputOffset:
+		changed.InputOffset += dir * offsetStep
+	}
+	return &changed
+}
+
+func changeVolume(volume, dir int) int {
+	return min(max(volume+dir*volumeStep, 0), game.MaxVolume)
+}
+
+// Where the window size is in the list. Sizes that aren't in the list count as the first one.
+func resolutionIndex(settings *game.Settings) int {
+	for i, r := range game.Resolutions {
+		if r.Width == settings.Width && r.Height == settings.Height {
+			return i
+		}
+	}
+	return 0
+}
+
+func onOff(on bool) string {
+	if on {
+		return "On"
+	}
+	return "Off"
+}
+
+func optionNames(settings *game.Settings) []string {
+	return []string{
+		"Resolution: " + strconv.Itoa(settings.Width) + "x" + strconv.Itoa(settings.Height),
+		"Fullscreen: " + onOff(settings.Fullscreen),
+		"Music volume: " + strconv.Itoa(settings.MusicVolume),
+		"Footstep volume: " + strconv.Itoa(settings.FootstepVolume),
+		"Door volume: " + strconv.Itoa(settings.DoorVolume),
+		"Hitsound volume: " + strconv.Itoa(settings.HitsoundVolume),
+		"Speed: " + speedModName(settings),
+		"Audio offset: " + strconv.Itoa(settings.GlobalOffset) + "ms",
+		"Input offset: " + strconv.Itoa(settings.InputOffset) + "ms",
+		"Calibrate",
+		"Key bindings",
+		"Back",
+	}
+}
+
+func (ui *ui) updateOptions() {
+	switch {
+	case ui.keyDownOnce(sdl.SCANCODE_ESCAPE):
+		ui.state = UITitle
+	case ui.keyDownOnce(sdl.SCANCODE_UP):
+		ui.optionsSelection = (ui.optionsSelection + numOptionItems - 1) % numOptionItems
+	case ui.keyDownOnce(sdl.SCANCODE_DOWN):
+		ui.optionsSelection = (ui.optionsSelection + 1) % numOptionItems
+	case ui.keyDownOnce(sdl.SCANCODE_LEFT):
+		ui.saveSettings(changeOption(ui.settings, ui.optionsSelection, -1))
+	case ui.keyDownOnce(sdl.SCANCODE_RIGHT):
+		ui.saveSettings(changeOption(ui.settings, ui.optionsSelection, 1))
+	case ui.keyDownOnce(sdl.SCANCODE_RETURN):
+		switch ui.optionsSelection {
+		case optionFullscreen:
+			ui.saveSettings(changeOption(ui.settings, ui.optionsSelection, 1))
+		case optionCalibrate:
+			ui.startCalibration()
+		case optionKeyBindings:
+			ui.openKeyBindings()
+		case optionBack:
+			ui.state = UITitle
+		}
+	}
+}
+
+// saveSettings changes the settings while there's no game to do it for us
+func (ui *ui) saveSettings(settings *game.Settings) {
+	resized := settings.Width != ui.settings.Width || settings.Height != ui.settings.Height || settings.Fullscreen != ui.settings.Fullscreen
+	*ui.settings = *settings
+	if ui.settingsPath != "" {
+		if err := ui.settings.Save(ui.settingsPath); err != nil {
+			ui.reportError("Couldn't save settings", err) // They still apply until the game closes
+		}
+	}
+	ui.keyBindings = keyBindingsFrom(ui.settings.KeyBindings)
+	ui.actions = actionMapFrom(ui.settings.Actions)
+	ui.pads.rebind(ui.settings)
+	ui.applyVolumes()
+	if resized {
+		ui.applyDisplay()
+	}
+}
+
+// applyVolumes sets the music and hitsound volumes. Footsteps and doors get theirs when they play.
+func (ui *ui) applyVolumes() {
+	mix.VolumeMusic(ui.settings.MusicVolume)
+	ui.sounds.hitsound.Volume(ui.settings.HitsoundVolume)
+}
+
+// applyDisplay sizes the window from the settings, and lays everything out again
+func (ui *ui) applyDisplay() {
+	if ui.settings.Fullscreen {
+		ui.window.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP) // Keep the desktop's resolution, it's kinder to other windows
+	} else {
+		ui.window.SetFullscreen(0)
+		ui.window.SetSize(int32(ui.settings.Width), int32(ui.settings.Height))
+		ui.window.SetPosition(sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED)
+	}
+	w, h := ui.window.GetSize()
+	ui.resize(int(w), int(h))
+}
+
+// DrawOptions shows the settings and what they're set to
+func (ui *ui) DrawOptions() {
+	y := ui.drawHeading("Options", int32(ui.winHeight)/16)
+	ui.drawMenu(optionNames(ui.settings), ui.optionsSelection, y, FontSmall)
+}
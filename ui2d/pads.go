@@ -0,0 +1,289 @@
+package ui2d
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// How far a stick or trigger has to go before it counts as pressed
+const axisThreshold = 16384
+
+// pad is a gamepad or dance pad. Its controls are named like "a", "dpup" and "leftx+" for gamepads SDL knows,
+// and "button0", "hat0up" and "axis1-" for everything else, which is most dance pads.
+type pad struct {
+	id         sdl.JoystickID
+	guid       string
+	name       string
+	gamepad    bool // SDL knows its layout, so it gets gamepad names and bindings
+	controller *sdl.GameController
+	joystick   *sdl.Joystick
+	bindings   padBindings
+	held       map[string]bool
+	pressed    []string // Went down since the last frame
+	released   []string
+}
+
+// padBindings are a pad's controls for each action, and for each column of each key mode
+type padBindings struct {
+	actions [][]string
+	columns map[int][][]string
+}
+
+func defaultPadBindings(gamepad bool) padBindings {
+	if gamepad {
+		return padBindings{
+			actions: [][]string{
+				actionUp:         {"dpup", "lefty-"},
+				actionDown:       {"dpdown", "lefty+"},
+				actionLeft:       {"dpleft", "leftx-"},
+				actionRight:      {"dpright", "leftx+"},
+				actionUpLeft:     nil,
+				actionUpRight:    nil,
+				actionDownLeft:   nil,
+				actionDownRight:  nil,
+				actionTakeAll:    {"x"},
+				actionInventory:  {"y"},
+				actionCalibrate:  nil,
+				actionSlower:     {"leftshoulder"},
+				actionFaster:     {"rightshoulder"},
+				actionMenu:       {"start"},
+				actionZoomIn:     nil,
+				actionZoomOut:    nil,
+				actionMessageLog: {"back"},
+			},
+			// Face buttons where the arrows would be, and the dpad joins in when there's more columns
+			columns: map[int][][]string{
+				3: {{"x"}, {"a"}, {"b"}},
+				4: {{"x"}, {"a"}, {"y"}, {"b"}},
+				6: {{"dpleft"}, {"dpdown"}, {"dpright"}, {"x"}, {"a"}, {"b"}},
+				7: {{"dpleft"}, {"dpdown"}, {"dpright"}, {"rightshoulder"}, {"x"}, {"a"}, {"b"}},
+				8: {{"dpleft"}, {"dpdown"}, {"dpup"}, {"dpright"}, {"x"}, {"a"}, {"y"}, {"b"}},
+			},
+		}
+	}
+	// Most dance pads put the arrows on the first four buttons, the rest can be fixed in the options
+	return padBindings{
+		actions: [][]string{
+			actionUp:         {"button2", "hat0up"},
+			actionDown:       {"button1", "hat0down"},
+			actionLeft:       {"button0", "hat0left"},
+			actionRight:      {"button3", "hat0right"},
+			actionUpLeft:     {"button6"},
+			actionUpRight:    {"button7"},
+			actionDownLeft:   nil,
+			actionDownRight:  nil,
+			actionTakeAll:    nil,
+			actionInventory:  {"button8"},
+			actionCalibrate:  nil,
+			actionSlower:     nil,
+			actionFaster:     nil,
+			actionMenu:       {"button9"},
+			actionZoomIn:     nil,
+			actionZoomOut:    nil,
+			actionMessageLog: nil,
+		},
+		columns: map[int][][]string{
+			3: {{"button0"}, {"button1"}, {"button3"}},
+			4: {{"button0"}, {"button1"}, {"button2"}, {"button3"}},
+			6: {{"button0"}, {"button6"}, {"button1"}, {"button2"}, {"button7"}, {"button3"}},
+		},
+	}
+}
+
+// padBindingsFrom starts from the defaults for the kind of pad, and swaps in what the player has changed
+func padBindingsFrom(saved game.PadBindings, gamepad bool) padBindings {
+	bindings := defaultPadBindings(gamepad)
+	for a, name := range actionNames {
+		if controls, ok := saved.Actions[name]; ok {
+			bindings.actions[a] = controls
+		}
+	}
+	for keys, columns := range saved.Columns {
+		if game.ValidKeyMode(keys) && len(columns) == keys {
+			bindings.columns[keys] = columns
+		}
+	}
+	return bindings
+}
+
+// save writes the bindings out for the settings file
+func (b padBindings) save() *game.PadBindings {
+	saved := &game.PadBindings{Actions: make(map[string][]string, numActions), Columns: make(map[int][][]string, len(b.columns))}
+	for a, controls := range b.actions {
+		saved.Actions[actionNames[a]] = controls
+	}
+	for k, v := range b.columns {
+		saved.Columns[k] = v // The settings keep their own map, so rebinding doesn't change them behind our back
+	}
+	return saved
+}
+
+// set holds or lets go of a control, remembering the change until the end of the frame
+func (p *pad) set(control string, down bool) {
+	if p.held[control] == down {
+		return
+	}
+	p.held[control] = down
+	if down {
+		p.pressed = append(p.pressed, control)
+	} else {
+		p.released = append(p.released, control)
+	}
+}
+
+// setAxis turns a stick into two controls, one for each way it can be pushed
+func (p *pad) setAxis(name string, value int16) {
+	p.set(name+"+", value > axisThreshold)
+	p.set(name+"-", value < -axisThreshold)
+}
+
+func (p *pad) setHat(hat uint8, value uint8) {
+	name := "hat" + strconv.Itoa(int(hat))
+	p.set(name+"up", value&sdl.HAT_UP != 0)
+	p.set(name+"right", value&sdl.HAT_RIGHT != 0)
+	p.set(name+"down", value&sdl.HAT_DOWN != 0)
+	p.set(name+"left", value&sdl.HAT_LEFT != 0)
+}
+
+// wasPressed is true if any of the controls went down this frame
+func (p *pad) wasPressed(controls []string) bool {
+	for _, c := range controls {
+		if slices.Contains(p.pressed, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pad) wasReleased(controls []string) bool {
+	for _, c := range controls {
+		if slices.Contains(p.released, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// pads are every pad plugged in, by the id SDL gives them
+type pads struct {
+	byID map[sdl.JoystickID]*pad
+	last *pad // The pad that was last pressed, which the options menu binds buttons on
+}
+
+func newPads() *pads {
+	return &pads{byID: make(map[sdl.JoystickID]*pad)}
+}
+
+func (ps *pads) add(p *pad, settings *game.Settings) {
+	p.held = make(map[string]bool)
+	p.bindings = padBindingsFrom(settings.Pads[p.guid], p.gamepad)
+	ps.byID[p.id] = p
+}
+
+func (ps *pads) remove(id sdl.JoystickID) {
+	p, ok := ps.byID[id]
+	if !ok {
+		return // Gamepads get removed as a gamepad and as a joystick
+	}
+	if p.controller != nil {
+		p.controller.Close()
+	} else if p.joystick != nil {
+		p.joystick.Close()
+	}
+	delete(ps.byID, id)
+	if ps.last == p {
+		ps.last = nil
+	}
+}
+
+// rebind picks up changed bindings from the settings
+func (ps *pads) rebind(settings *game.Settings) {
+	for _, p := range ps.byID {
+		p.bindings = padBindingsFrom(settings.Pads[p.guid], p.gamepad)
+	}
+}
+
+// handleEvent plugs pads in and out, and keeps track of what's held down on them
+func (ps *pads) handleEvent(event sdl.Event, settings *game.Settings) {
+	switch e := event.(type) {
+	case *sdl.ControllerDeviceEvent:
+		if e.Type == sdl.CONTROLLERDEVICEADDED {
+			controller := sdl.GameControllerOpen(int(e.Which))
+			if controller == nil {
+				return
+			}
+			joystick := controller.Joystick()
+			ps.add(&pad{id: joystick.InstanceID(), guid: sdl.JoystickGetGUIDString(joystick.GUID()), name: controller.Name(), gamepad: true, controller: controller, joystick: joystick}, settings)
+		} else if e.Type == sdl.CONTROLLERDEVICEREMOVED {
+			ps.remove(e.Which)
+		}
+	case *sdl.JoyDeviceAddedEvent:
+		if sdl.IsGameController(int(e.Which)) {
+			return // It gets its own gamepad event
+		}
+		joystick := sdl.JoystickOpen(int(e.Which))
+		if joystick == nil {
+			return
+		}
+		ps.add(&pad{id: joystick.InstanceID(), guid: sdl.JoystickGetGUIDString(joystick.GUID()), name: joystick.Name(), joystick: joystick}, settings)
+	case *sdl.JoyDeviceRemovedEvent:
+		ps.remove(e.Which)
+	case *sdl.ControllerButtonEvent:
+		if p := ps.byID[e.Which]; p != nil {
+			p.set(sdl.GameControllerGetStringForButton(sdl.GameControllerButton(e.Button)), e.State == sdl.PRESSED)
+			ps.last = p
+		}
+	case *sdl.ControllerAxisEvent:
+		if p := ps.byID[e.Which]; p != nil {
+			p.setAxis(sdl.GameControllerGetStringForAxis(sdl.GameControllerAxis(e.Axis)), e.Value)
+			ps.last = p
+		}
+	// Gamepads send joystick events too, but they've already been handled by name
+	case *sdl.JoyButtonEvent:
+		if p := ps.byID[e.Which]; p != nil && !p.gamepad {
+			p.set("button"+strconv.Itoa(int(e.Button)), e.State == sdl.PRESSED)
+			ps.last = p
+		}
+	case *sdl.JoyHatEvent:
+		if p := ps.byID[e.Which]; p != nil && !p.gamepad {
+			p.setHat(e.Hat, e.Value)
+			ps.last = p
+		}
+	case *sdl.JoyAxisEvent:
+		if p := ps.byID[e.Which]; p != nil && !p.gamepad {
+			p.setAxis("axis"+strconv.Itoa(int(e.Axis)), e.Value)
+			ps.last = p
+		}
+	}
+}
+
+// endFrame forgets what was pressed and released, like the previous keyboard state does for keys
+func (ps *pads) endFrame() {
+	for _, p := range ps.byID {
+		p.pressed = p.pressed[:0]
+		p.released = p.released[:0]
+	}
+}
+
+// actionDown is true if any pad pressed an action this frame
+func (ps *pads) actionDown(a action) bool {
+	for _, p := range ps.byID {
+		if p.wasPressed(p.bindings.actions[a]) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstPress is a control any pad pressed this frame, for binding it in the options
+func (ps *pads) firstPress() (*pad, string) {
+	for _, p := range ps.byID {
+		if len(p.pressed) > 0 {
+			return p, p.pressed[0]
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,197 @@
+package ui2d
+
+import (
+	"strconv"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Practice menu rows
+const (
+	practiceMonster = iota
+	practiceDifficulty
+	practiceKeys
+	practiceSpeed
+	practiceStart
+	numPracticeItems
+)
+
+type practiceMenu struct {
+	options   game.PracticeOptions
+	selected  int
+	settings  *game.Settings
+	stats     *game.PracticeStats
+	statsPath string
+	session   *game.Practice // Nil while picking options
+	newBest   bool
+}
+
+func (ui *ui) openPractice(settings *game.Settings) {
+	menu := &practiceMenu{settings: settings}
+	menu.options = game.PracticeOptions{Difficulty: 1, Keys: game.NumKeys, ScrollSpeed: max(settings.ScrollSpeed, game.MinScrollSpeed)}
+	menu.stats = &game.PracticeStats{Best: make(map[string]float64)}
+	ui.practice = menu
+	ui.state = UIPractice
+	path, err := game.DefaultPracticeStatsPath()
+	if err == nil {
+		menu.statsPath = path
+		menu.stats, err = game.LoadPracticeStats(path) // Empty stats if the file is broken, saving a best fixes it
+		if err != nil {
+			ui.reportError("Couldn't load practice bests", err)
+		}
+	}
+}
+
+func (ui *ui) updatePractice() {
+	menu := ui.practice
+	if menu.session != nil {
+		ui.updatePracticeSession()
+		return
+	}
+	switch {
+	case ui.keyDownOnce(sdl.SCANCODE_ESCAPE):
+		ui.practice = nil
+		ui.state = UITitle
+	case ui.keyDownOnce(sdl.SCANCODE_UP):
+		menu.selected = (menu.selected + numPracticeItems - 1) % numPracticeItems
+	case ui.keyDownOnce(sdl.SCANCODE_DOWN):
+		menu.selected = (menu.selected + 1) % numPracticeItems
+	case ui.keyDownOnce(sdl.SCANCODE_LEFT):
+		menu.options = changePracticeOption(menu.options, menu.selected, -1)
+	case ui.keyDownOnce(sdl.SCANCODE_RIGHT):
+		menu.options = changePracticeOption(menu.options, menu.selected, 1)
+	case ui.keyDownOnce(sdl.SCANCODE_RETURN) && menu.selected == practiceStart:
+		menu.session = game.NewPractice(menu.options, ui.music, menu.settings, menu.stats)
+		menu.newBest = false
+		ui.pendingNotes = ui.pendingNotes[:0]
+	}
+}
+
+// Play the notes straight away instead of sending them to the game, practice doesn't need the dungeon
+func (ui *ui) updatePracticeSession() {
+	menu := ui.practice
+	session := menu.session
+	if ui.keyDownOnce(sdl.SCANCODE_ESCAPE) {
+		menu.session = nil
+		return
+	}
+	now := session.Level.SongPosition()
+	ui.queueNoteInputs(burstKeys(session.Player.Burst), now)
+	for _, input := range ui.pendingNotes {
+		if input.Typ == game.NotePressed {
+			session.Press(input.Column, input.Time)
+		} else {
+			session.Release(input.Column, input.Time)
+		}
+	}
+	ui.pendingNotes = ui.pendingNotes[:0]
+	last := session.Last
+	best := session.Update(now)
+	if session.Last != last {
+		menu.newBest = best // Only until the next loop is done
+	}
+	if best && menu.statsPath != "" {
+		if err := menu.stats.Save(menu.statsPath); err != nil {
+			ui.reportError("Couldn't save practice bests", err) // Not worth stopping practice over
+		}
+	}
+}
+
+// Step a practice option, wrapping around at either end
+func changePracticeOption(options game.PracticeOptions, item, steps int) game.PracticeOptions {
+	switch item {
+	case practiceMonster:
+		options.Monster = wrap(options.Monster+steps, len(game.PracticeMonsters))
+	case practiceDifficulty:
+		options.Difficulty = wrap(options.Difficulty+steps, game.MaxPracticeDifficulty+1)
+	case practiceKeys:
+		i := 0
+		for j, keys := range game.KeyModes {
+			if keys == options.Keys {
+				i = j
+			}
+		}
+		options.Keys = game.KeyModes[wrap(i+steps, len(game.KeyModes))]
+	case practiceSpeed:
+		speed := options.ScrollSpeed + float64(steps)*game.ScrollSpeedStep
+		if speed > game.MaxScrollSpeed {
+			speed = game.MinScrollSpeed
+		} else if speed < game.MinScrollSpeed {
+			speed = game.MaxScrollSpeed
+		}
+		options.ScrollSpeed = speed
+	}
+	return options
+}
+
+func wrap(i, n int) int {
+	return (i%n + n) % n
+}
+
+// DrawPractice shows the options menu, or the burst being practiced
+func (ui *ui) DrawPractice() {
+	menu := ui.practice
+	if menu.session != nil {
+		ui.drawPracticeSession()
+		return
+	}
+	options := menu.options
+	difficulty := "Stream"
+	if options.Difficulty > 0 {
+		difficulty = strconv.Itoa(options.Difficulty)
+	}
+	items := []string{
+		"Monster: " + game.PracticeMonsters[options.Monster].Name,
+		"Difficulty: " + difficulty,
+		"Keys: " + strconv.Itoa(options.Keys) + "K",
+		"Speed: " + speedModName(&game.Settings{ScrollSpeed: options.ScrollSpeed}),
+		"Start",
+	}
+	y := int32(ui.winHeight) / 6
+	tex := ui.stringToTexture("Practice", menuColor, FontLarge)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+	y += h + h/2
+	ui.drawMenu(items, menu.selected, y, FontMedium)
+	ui.drawPracticeFooter(bestAccuracy(menu.stats, options)+"   ESC to go back", "")
+}
+
+func (ui *ui) drawPracticeSession() {
+	menu := ui.practice
+	session := menu.session
+	settings := session.Level.Settings
+	now := session.Level.SongPosition() + settings.InputOffset
+	ui.DrawBurst(session.Player, &session.Monster.Character, now, settings)
+
+	last := ""
+	if r := session.Last; r != nil {
+		last = "Last loop: " + r.Grade + " " + strconv.FormatFloat(r.Accuracy, 'f', 1, 64) + "%"
+		if menu.newBest {
+			last += " New best!"
+		}
+	}
+	ui.drawPracticeFooter(menu.options.Name()+"   "+bestAccuracy(menu.stats, menu.options)+"   ESC to stop", last)
+}
+
+// Small lines of text along the bottom of the screen
+func (ui *ui) drawPracticeFooter(lines ...string) {
+	y := int32(ui.winHeight)
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] == "" {
+			continue
+		}
+		tex := ui.stringToTexture(lines[i], menuColor, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		y -= h
+		ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+	}
+}
+
+func bestAccuracy(stats *game.PracticeStats, options game.PracticeOptions) string {
+	best, ok := stats.Best[options.Name()]
+	if !ok {
+		return "Best: none yet"
+	}
+	return "Best: " + strconv.FormatFloat(best, 'f', 1, 64) + "%"
+}
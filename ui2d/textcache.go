@@ -0,0 +1,106 @@
+package ui2d
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// How many strings stay drawn. Menus and the event log only need a few hundred at once.
+const textCacheSize = 512
+
+// textKey is everything that changes how a string looks
+type textKey struct {
+	s     string
+	size  FontSize
+	color sdl.Color
+}
+
+type textEntry struct {
+	key textKey
+	tex *sdl.Texture
+}
+
+// textCacheStats are for checking the cache isn't growing or thrashing, F3 shows them in game
+type textCacheStats struct {
+	Entries   int
+	Capacity  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+func (s textCacheStats) String() string {
+	return fmt.Sprintf("Text cache: %d/%d, %d hits, %d misses, %d evicted", s.Entries, s.Capacity, s.Hits, s.Misses, s.Evictions)
+}
+
+// textCache keeps textures for drawn strings, and destroys the least recently used one when it gets full
+type textCache struct {
+	capacity int
+	entries  map[textKey]*list.Element
+	order    *list.List // Most recently used at the front
+	destroy  func(*sdl.Texture)
+	stats    textCacheStats
+}
+
+func newTextCache(capacity int, destroy func(*sdl.Texture)) *textCache {
+	return &textCache{
+		capacity: capacity,
+		entries:  make(map[textKey]*list.Element),
+		order:    list.New(),
+		destroy:  destroy,
+		stats:    textCacheStats{Capacity: capacity},
+	}
+}
+
+func destroyTexture(tex *sdl.Texture) {
+	tex.Destroy()
+}
+
+func (c *textCache) get(key textKey) (*sdl.Texture, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*textEntry).tex, true
+}
+
+func (c *textCache) put(key textKey, tex *sdl.Texture) {
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*textEntry)
+		if entry.tex != tex {
+			c.destroy(entry.tex)
+			entry.tex = tex
+		}
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&textEntry{key, tex})
+	for c.order.Len() > c.capacity {
+		c.evict(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *textCache) evict(e *list.Element) {
+	entry := c.order.Remove(e).(*textEntry)
+	delete(c.entries, entry.key)
+	c.destroy(entry.tex)
+}
+
+// clear destroys every texture, when the fonts change. It doesn't count as evicting.
+func (c *textCache) clear() {
+	for c.order.Len() > 0 {
+		c.evict(c.order.Back())
+	}
+}
+
+func (c *textCache) Stats() textCacheStats {
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
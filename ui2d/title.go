@@ -0,0 +1,369 @@
+package ui2d
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Title menu entries
+const (
+	titleNewGame = iota
+	titleContinue
+	titleDeleteSave
+	titlePractice
+	titleOptions
+	titleQuit
+	numTitleItems
+)
+
+var titleItems = [numTitleItems]string{"New game", "Continue", "Delete broken save", "Practice", "Options", "Quit"}
+
+// Longest seed that can be typed in, so it always fits in an int64
+const maxSeedDigits = 9
+
+var (
+	menuColor     = sdl.Color{255, 255, 255, 0}
+	selectedColor = sdl.Color{255, 255, 128, 0}
+	noticeColor   = sdl.Color{255, 96, 96, 0}
+)
+
+// menuNotice is something that went wrong, shown on the screen it happened on until the player leaves it
+type menuNotice struct {
+	text  string
+	state uiState
+}
+
+// reportError tells the player about a file that couldn't be read or written, instead of crashing
+func (ui *ui) reportError(what string, err error) {
+	ui.notice = &menuNotice{what + ": " + err.Error(), ui.state}
+}
+
+// drawNotice shows the last error at the bottom of the screen it happened on
+func (ui *ui) drawNotice() {
+	if ui.notice == nil || ui.notice.state != ui.state {
+		ui.notice = nil
+		return
+	}
+	tex := ui.stringToTexture(ui.notice.text, noticeColor, FontSmall)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, int32(ui.winHeight) - h*2, w, h})
+}
+
+// What the game over screen shows after the game has gone
+type gameOver struct {
+	seed    int64
+	summary []string
+}
+
+// inMenus is true for the screens that don't need the dungeon
+func (ui *ui) inMenus() bool {
+	switch ui.state {
+	case UITitle, UINewGame, UIOptions, UIKeyBindings, UIGameOver, UIPractice:
+		return true
+	case UICalibration:
+		return ui.game == nil
+	}
+	return false
+}
+
+// updateMenus runs the screens that don't need the dungeon. Returns a game to start if one was picked.
+func (ui *ui) updateMenus() *game.Game {
+	var newGame *game.Game
+	if sdl.GetKeyboardFocus() == ui.window {
+		switch ui.state {
+		case UITitle:
+			newGame = ui.updateTitle()
+		case UINewGame:
+			newGame = ui.updateNewGame()
+		case UIOptions:
+			ui.updateOptions()
+		case UIKeyBindings:
+			ui.updateKeyBindings()
+		case UICalibration:
+			if calibrated := ui.updateCalibration(); calibrated != nil {
+				ui.saveSettings(calibrated.Settings)
+			}
+		case UIGameOver:
+			if ui.keyDownOnce(sdl.SCANCODE_RETURN) || ui.keyDownOnce(sdl.SCANCODE_ESCAPE) {
+				ui.gameOver = nil
+				ui.state = UITitle
+			}
+		case UIPractice:
+			ui.updatePractice()
+		}
+	}
+
+	ui.renderer.Clear()
+	switch ui.state {
+	case UITitle:
+		ui.DrawTitle()
+	case UINewGame:
+		ui.DrawNewGame()
+	case UIOptions:
+		ui.DrawOptions()
+	case UIKeyBindings:
+		ui.DrawKeyBindings()
+	case UICalibration:
+		ui.DrawCalibration()
+	case UIGameOver:
+		ui.DrawGameOver()
+	case UIPractice:
+		ui.DrawPractice()
+	}
+	ui.drawNotice()
+	ui.drawTextStats()
+	ui.renderer.Present()
+
+	// Update previous keyboard state
+	for i, v := range ui.keyboardState {
+		ui.prevKeyboardState[i] = v
+	}
+	return newGame
+}
+
+// Title entries that can be picked right now. Continue only shows up when there's a save,
+// and a save that wouldn't load can only be deleted.
+func (ui *ui) titleMenu() []int {
+	saved := ui.savePath != "" && game.SaveExists(ui.savePath)
+	return titleEntries(saved, ui.brokenSave)
+}
+
+func titleEntries(saved, broken bool) []int {
+	items := make([]int, 0, numTitleItems)
+	for i := 0; i < numTitleItems; i++ {
+		if i == titleContinue && (!saved || broken) || i == titleDeleteSave && (!saved || !broken) {
+			continue
+		}
+		items = append(items, i)
+	}
+	return items
+}
+
+func (ui *ui) updateTitle() *game.Game {
+	items := ui.titleMenu()
+	ui.titleSelection = min(ui.titleSelection, len(items)-1)
+	switch {
+	case ui.keyDownOnce(sdl.SCANCODE_UP):
+		ui.titleSelection = (ui.titleSelection + len(items) - 1) % len(items)
+	case ui.keyDownOnce(sdl.SCANCODE_DOWN):
+		ui.titleSelection = (ui.titleSelection + 1) % len(items)
+	case ui.keyDownOnce(sdl.SCANCODE_RETURN):
+		switch items[ui.titleSelection] {
+		case titleNewGame:
+			ui.seedText = strconv.FormatInt(game.RandomSeed(), 10)
+			classes, err := game.LoadClasses()
+			if err != nil {
+				ui.reportError("Couldn't load classes", err)
+				return nil
+			}
+			ui.classes, ui.classSelection = classes, 0
+			ui.state = UINewGame
+		case titleContinue:
+			g, err := game.LoadGame(1, ui.savePath)
+			if err != nil {
+				ui.brokenSave = true
+				ui.reportError("Couldn't continue", err)
+				return nil
+			}
+			return g
+		case titleDeleteSave:
+			if err := os.Remove(ui.savePath); err != nil {
+				ui.reportError("Couldn't delete the save", err)
+				return nil
+			}
+			ui.brokenSave = false
+		case titlePractice:
+			ui.openPractice(ui.settings)
+		case titleOptions:
+			ui.optionsSelection = 0
+			ui.state = UIOptions
+		case titleQuit:
+			sdl.PushEvent(&sdl.QuitEvent{Type: sdl.QUIT}) // Quit the same way closing the window does
+		}
+	}
+	return nil
+}
+
+// Pick a class, and type a seed in or keep the random one
+func (ui *ui) updateNewGame() *game.Game {
+	switch {
+	case ui.keyDownOnce(sdl.SCANCODE_ESCAPE):
+		ui.state = UITitle
+	case ui.keyDownOnce(sdl.SCANCODE_LEFT):
+		ui.classSelection = (ui.classSelection + len(ui.classes) - 1) % len(ui.classes)
+	case ui.keyDownOnce(sdl.SCANCODE_RIGHT):
+		ui.classSelection = (ui.classSelection + 1) % len(ui.classes)
+	case ui.keyDownOnce(sdl.SCANCODE_RETURN):
+		seed, _ := strconv.ParseInt(ui.seedText, 10, 64) // An empty seed is 0
+		g := game.NewSeededGame(1, seed)
+		if err := g.SetClass(ui.classes[ui.classSelection]); err != nil {
+			ui.state = UITitle
+			ui.reportError("Couldn't start as a "+ui.classes[ui.classSelection].Name, err)
+			return nil
+		}
+		return g
+	default:
+		for key := range ui.keyboardState {
+			if ui.keyDownOnce(uint8(key)) {
+				ui.seedText = editSeed(ui.seedText, uint8(key))
+			}
+		}
+	}
+	return nil
+}
+
+// editSeed types a digit or rubs one out
+func editSeed(text string, key uint8) string {
+	switch {
+	case key == sdl.SCANCODE_BACKSPACE && len(text) > 0:
+		return text[:len(text)-1]
+	case len(text) >= maxSeedDigits:
+		return text
+	case key == sdl.SCANCODE_0:
+		return text + "0"
+	case key >= sdl.SCANCODE_1 && key <= sdl.SCANCODE_9:
+		return text + strconv.Itoa(int(key-sdl.SCANCODE_1)+1) // Scancodes go 1 to 9, then 0
+	}
+	return text
+}
+
+// startGame hands the dungeon over to a game, and waits for its first level
+func (ui *ui) startGame(g *game.Game) *game.Level {
+	ui.game = g
+	ui.brokenSave = false // Replaced by this run's save
+	ui.inputChan = g.InputChan
+	ui.levelChan = g.LevelChans[0]
+	ui.settings, ui.settingsPath = g.Settings, g.SettingsPath
+	g.SetClock(ui.music) // Battles follow the music
+	ui.sounds.events = &game.EventQueue{}
+	g.Bus.Subscribe(ui.sounds.events.Record) // Before the game starts, so nothing is missed
+	go g.Run()
+
+	ui.state = UIMain
+	ui.centerX, ui.centerY = -1, -1
+	ui.battleResult = nil
+	ui.draggedItem = nil
+	ui.pendingNotes = ui.pendingNotes[:0]
+	return <-ui.levelChan
+}
+
+// stopGame tells the game to save and quit, and waits until it has. Returns why the save failed, if it did.
+func (ui *ui) stopGame() error {
+	if ui.game == nil {
+		return nil
+	}
+	quit := &game.Input{Typ: game.QuitGame}
+	for sent := false; !sent; {
+		// The game might be busy sending us a level
+		select {
+		case ui.inputChan <- quit:
+			sent = true
+		case <-ui.levelChan:
+		}
+	}
+	for range ui.levelChan {
+		// Wait for the save to finish
+	}
+	err := ui.game.SaveErr
+	ui.game = nil
+	ui.inputChan = nil
+	ui.levelChan = nil
+	return err
+}
+
+// endRun stops a game the player has died in, and shows how the run went
+func (ui *ui) endRun() {
+	ui.gameOver = &gameOver{seed: ui.game.Seed, summary: ui.game.Stats.Summary()}
+	ui.stopGame() // Dead players' saves get deleted, so there's nothing to fail
+	ui.state = UIGameOver
+}
+
+// DrawTitle shows the name of the game and the menu
+func (ui *ui) DrawTitle() {
+	y := ui.drawHeading("Lyn's Rhythm Dungeon", int32(ui.winHeight)/4)
+	items := ui.titleMenu()
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = titleItems[item]
+	}
+	ui.drawMenu(names, ui.titleSelection, y, FontMedium)
+}
+
+// DrawNewGame shows the class being picked and the seed being typed in
+func (ui *ui) DrawNewGame() {
+	y := ui.drawHeading("New game", int32(ui.winHeight)/4)
+	class := ui.classes[ui.classSelection]
+	ui.drawMenu([]string{class.Name, "Seed: " + ui.seedText + "_"}, 0, y, FontMedium)
+	_, lineHeight, _ := ui.fontMedium.SizeUTF8("A")
+	y = ui.drawLines(classSummary(class), y+int32(lineHeight)*5/2)
+	ui.drawLines([]string{"LEFT and RIGHT to pick a class, type a seed to replay a run", "ENTER to start, ESC to go back"}, y+int32(ui.winHeight)/16)
+}
+
+// classSummary is a class's description, stats and passive, a line each
+func classSummary(class *game.Class) []string {
+	lines := []string{
+		class.Description,
+		strconv.Itoa(class.Hitpoints) + " hitpoints, " + strconv.Itoa(class.Stamina) + " stamina, " + strconv.Itoa(class.SightRange) + " sight",
+	}
+	if p := class.Passive; p.Name != "" {
+		var does []string
+		if p.HealEvery > 0 {
+			does = append(does, "heal every "+strconv.Itoa(p.HealEvery)+" notes in a row")
+		}
+		if p.JudgeScale > 0 {
+			does = append(does, strconv.Itoa(int(p.JudgeScale*100))+"% judgement windows")
+		}
+		if p.BonusDamage > 0 {
+			does = append(does, "+"+strconv.Itoa(p.BonusDamage)+" damage")
+		}
+		lines = append(lines, p.Name+": "+strings.Join(does, ", "))
+	}
+	return lines
+}
+
+// DrawGameOver shows how the run went
+func (ui *ui) DrawGameOver() {
+	over := ui.gameOver
+	y := ui.drawHeading("You died", int32(ui.winHeight)/4)
+	lines := append([]string{"Seed: " + strconv.FormatInt(over.seed, 10)}, over.summary...)
+	y = ui.drawLines(lines, y)
+	ui.drawMenu([]string{"Back to the title"}, 0, y+int32(ui.winHeight)/16, FontMedium)
+}
+
+// drawHeading draws a big line of text, and returns where the rest can go
+func (ui *ui) drawHeading(heading string, y int32) int32 {
+	tex := ui.stringToTexture(heading, menuColor, FontLarge)
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+	return y + h*2
+}
+
+// drawLines draws centered lines of small text, and returns where they end
+func (ui *ui) drawLines(lines []string, y int32) int32 {
+	for _, line := range lines {
+		tex := ui.stringToTexture(line, menuColor, FontSmall)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+		y += h
+	}
+	return y
+}
+
+// drawMenu draws a centered list of lines, highlighting the selected one
+func (ui *ui) drawMenu(items []string, selected int, y int32, size FontSize) {
+	for i, item := range items {
+		color := menuColor
+		if i == selected {
+			color = selectedColor
+			item = "> " + item + " <"
+		}
+		tex := ui.stringToTexture(item, color, size)
+		_, _, w, h, _ := tex.Query()
+		ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth)/2 - w/2, y, w, h})
+		y += h
+	}
+}
@@ -2,6 +2,7 @@ package ui2d
 
 import (
 	"bufio"
+	"fmt"
 	"image/png"
 	"math"
 	"math/rand"
@@ -43,6 +44,7 @@ type sounds struct {
 	openingDoors []*mix.Chunk // Arrays to play randomly
 	footsteps    []*mix.Chunk
 	hitsound     *mix.Chunk
+	events       *game.EventQueue // What the run's bus has heard, played when the level comes in
 }
 
 func playRandomSound(chunks []*mix.Chunk, volume int) {
@@ -63,6 +65,14 @@ const (
 	UIMain uiState = iota
 	UIInventory
 	UIBattle
+	UICalibration
+	UITitle
+	UIPractice
+	UINewGame
+	UIOptions
+	UIGameOver
+	UIKeyBindings
+	UIMessageLog
 )
 
 type ui struct {
@@ -79,11 +89,36 @@ type ui struct {
 	noteskinIndex     map[rune][]sdl.Rect
 	prevKeyboardState []uint8
 	keyboardState     []uint8
-	centerX           int // Keep camera centered around player
+	keyBindings       keyBindings  // Keys for note columns in battles
+	actions           actionMap    // Keys for everything else in the dungeon
+	pads              *pads        // Gamepads and dance pads, which have their own bindings
+	pendingNotes      []game.Input // Note presses and releases waiting for a free frame
+	receptorPressed   [8]uint64    // Ticks when each column was last pressed, so receptors can flash
+	music             *musicClock  // The song battles are played against
+	calibration       *calibration
+	titleSelection    int
+	brokenSave        bool        // The save couldn't be loaded, so it can only be deleted
+	notice            *menuNotice // An error to show on the menu it happened on
+	seedText          string      // Seed being typed in for a new game
+	classes           []*game.Class
+	classSelection    int
+	optionsSelection  int
+	binding           *bindingMenu
+	messageLog        *messageLogView
+	practice          *practiceMenu
+	gameOver          *gameOver
+	battleResult      *game.BattleResult // How the last turn of a fight went
+	battleResultAt    uint64             // Ticks when the result was shown
+	centerX           int                // Keep camera centered around player
+	zoom              int                // Map tiles are this many times bigger, or 0 to follow the window size
 	centerY           int
 	r                 *rand.Rand       // RNG should not be shared aross UIs
+	game              *game.Game       // The game being played, or nil in the menus
 	levelChan         chan *game.Level // What level it's getting data from
 	inputChan         chan *game.Input
+	settings          *game.Settings // Shared with the game while one is running
+	settingsPath      string
+	savePath          string // Where runs are saved, or empty if they can't be
 	fontSmall         *ttf.Font
 	fontMedium        *ttf.Font
 	fontLarge         *ttf.Font
@@ -96,33 +131,38 @@ type ui struct {
 	playfieldBackground       *sdl.Texture
 	dimOverlay                *sdl.Texture
 
-	str2TexSmall  map[string]*sdl.Texture // String/texture cache
-	str2TexMedium map[string]*sdl.Texture // TODO(max): map string for size to eliminate redundancy
-	str2TexLarge  map[string]*sdl.Texture
+	textCache     *textCache // Textures for drawn strings
+	showTextStats bool
 
 	currentMouseState *mouseState
 	prevMouseState    *mouseState
 }
 
-// NewUI creates our UI struct
-func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
+// NewUI creates our UI struct. Games are started from the title menu.
+func NewUI() *ui {
 	ui := &ui{}
-	ui.state = UIMain
-	ui.str2TexSmall = make(map[string]*sdl.Texture)
-	ui.str2TexMedium = make(map[string]*sdl.Texture)
-	ui.str2TexLarge = make(map[string]*sdl.Texture)
-	ui.inputChan = inputChan
-	ui.levelChan = levelChan
-	ui.r = rand.New(rand.NewSource(3020)) // Each UI has its own random starting with the same seed
-	ui.winHeight = 480
-	ui.winWidth = 780
+	ui.state = UITitle
+	ui.textCache = newTextCache(textCacheSize, destroyTexture)
+	var err error
+	ui.settings, ui.settingsPath, err = game.LoadDefaultSettings()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't load settings, using defaults:", err)
+	}
+	ui.savePath, _ = game.DefaultSavePath() // No save path means no continuing
+	ui.r = rand.New(rand.NewSource(3020))   // Each UI has its own random starting with the same seed
+	ui.winHeight = ui.settings.Height
+	ui.winWidth = ui.settings.Width
+	ui.keyBindings = keyBindingsFrom(ui.settings.KeyBindings)
+	ui.actions = actionMapFrom(ui.settings.Actions)
+	ui.pads = newPads() // Pads already plugged in get added by their events
 
 	// Create a window.
-	window, err := sdl.CreateWindow("Lyn's Rhythm Dungeon", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(ui.winWidth), int32(ui.winHeight), sdl.WINDOW_SHOWN)
+	window, err := sdl.CreateWindow("Lyn's Rhythm Dungeon", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(ui.winWidth), int32(ui.winHeight), sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	if err != nil {
 		panic(err)
 	}
 	ui.window = window
+	window.SetMinimumSize(baseWidth/2, baseHeight/2)
 
 	// Create renderer.
 	ui.renderer, err = sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
@@ -138,8 +178,7 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 	ui.loadTextureIndex()
 
 	// Create noteskin texture.
-	ui.noteskinAtlas = ui.imgFileToTexture("ui2d/assets/noteskin.png")
-	ui.loadNoteskinIndex()
+	ui.noteskinAtlas, ui.noteskinIndex = LoadNoteskin(ui.renderer)
 
 	// Update keyboard state
 	ui.keyboardState = sdl.GetKeyboardState() // Updates by sdl
@@ -153,18 +192,7 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 	ui.centerY = -1
 
 	// Get the font sizes
-	ui.fontSmall, err = ttf.OpenFont("ui2d/assets/gothic.ttf", int(float64(ui.winWidth)*0.02))
-	if err != nil {
-		panic(err)
-	}
-	ui.fontMedium, err = ttf.OpenFont("ui2d/assets/gothic.ttf", 32)
-	if err != nil {
-		panic(err)
-	}
-	ui.fontLarge, err = ttf.OpenFont("ui2d/assets/gothic.ttf", 64)
-	if err != nil {
-		panic(err)
-	}
+	ui.openFonts()
 
 	// Draw console background
 	ui.eventBackground = ui.GetSinglePixelTex(&sdl.Color{0, 0, 0, 128})
@@ -194,18 +222,16 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 	}
 
 	// Load music
-	mus, err := mix.LoadMUS("ui2d/assets/dungeon-theme.ogg")
+	ui.music, err = playMusic(dungeonTheme)
 	if err != nil {
 		panic(err)
 	}
-	mus.Play(-1) // Loop forever
 
 	// Load hitsound
 	ui.sounds.hitsound, err = mix.LoadWAV("ui2d/assets/hitsound.ogg")
 	if err != nil {
 		panic(err)
 	}
-	ui.sounds.hitsound.Volume(40)
 
 	// Load footstep sounds
 	footstepBase := "ui2d/assets/footstep0"
@@ -229,6 +255,10 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 	}
 	ui.sounds.openingDoors = append(ui.sounds.openingDoors, doorOpen2)
 
+	ui.applyVolumes()
+	if ui.settings.Fullscreen {
+		ui.applyDisplay()
+	}
 	return ui
 }
 
@@ -244,28 +274,25 @@ const (
 	FontLarge
 )
 
+// stringToTexture draws a string once, and keeps it around while it keeps being drawn
 func (ui *ui) stringToTexture(s string, color sdl.Color, size FontSize) *sdl.Texture {
-	// Check if string exists in cache
-	var font *ttf.Font
+	key := textKey{s, size, color}
+	if tex, ok := ui.textCache.get(key); ok {
+		return tex
+	}
+	tex := ui.renderText(s, color, size)
+	ui.textCache.put(key, tex)
+	return tex
+}
+
+// renderText makes a new texture for a string. Whoever calls it has to destroy it.
+func (ui *ui) renderText(s string, color sdl.Color, size FontSize) *sdl.Texture {
+	font := ui.fontSmall
 	switch size {
-	case FontSmall:
-		font = ui.fontSmall
-		tex, exists := ui.str2TexSmall[s]
-		if exists {
-			return tex
-		}
 	case FontMedium:
 		font = ui.fontMedium
-		tex, exists := ui.str2TexMedium[s]
-		if exists {
-			return tex
-		}
 	case FontLarge:
 		font = ui.fontLarge
-		tex, exists := ui.str2TexLarge[s]
-		if exists {
-			return tex
-		}
 	}
 
 	// Create font surface
@@ -273,30 +300,36 @@ func (ui *ui) stringToTexture(s string, color sdl.Color, size FontSize) *sdl.Tex
 	if err != nil {
 		panic(err)
 	}
+	defer fontSurface.Free()
 
 	// Create font texture
 	tex, err := ui.renderer.CreateTextureFromSurface(fontSurface)
 	if err != nil {
 		panic(err)
 	}
+	return tex
+}
 
-	// Put texture in cache
-	switch size {
-	case FontSmall:
-		ui.str2TexSmall[s] = tex
-	case FontMedium:
-		ui.str2TexMedium[s] = tex
-	case FontLarge:
-		ui.str2TexLarge[s] = tex
+// drawTextStats shows how the text cache is doing in the corner, when F3 has turned it on
+func (ui *ui) drawTextStats() {
+	if !ui.showTextStats {
+		return
 	}
+	// Drawn without the cache, or the numbers changing would fill it up
+	tex := ui.renderText(ui.textCache.Stats().String(), menuColor, FontSmall)
+	defer tex.Destroy()
+	_, _, w, h, _ := tex.Query()
+	ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth) - w, 0, w, h})
+}
 
-	//tex.Destroy() // Always destroy texture or it will stay in memory indefinitely
-	return tex
+// LoadNoteskin loads the noteskin atlas and where each note is on it, so other tools can draw notes like the game does
+func LoadNoteskin(renderer *sdl.Renderer) (*sdl.Texture, map[rune][]sdl.Rect) {
+	return imgFileToTexture(renderer, "ui2d/assets/noteskin.png"), loadNoteskinIndex("ui2d/assets/noteskin-index.txt")
 }
 
-func (ui *ui) loadNoteskinIndex() {
-	ui.noteskinIndex = make(map[rune][]sdl.Rect) // 0, 4, 8, 16 ...
-	infile, err := os.Open("ui2d/assets/noteskin-index.txt")
+func loadNoteskinIndex(filename string) map[rune][]sdl.Rect {
+	noteskinIndex := make(map[rune][]sdl.Rect) // 0, 4, 8, 16 ...
+	infile, err := os.Open(filename)
 	if err != nil {
 		panic(err)
 	}
@@ -320,9 +353,10 @@ func (ui *ui) loadNoteskinIndex() {
 		}
 		var rects []sdl.Rect
 		rects = append(rects, sdl.Rect{int32(x * 24), int32(y * 20), 24, 20})
-		ui.noteskinIndex[tileRune] = rects
+		noteskinIndex[tileRune] = rects
 		x++
 	}
+	return noteskinIndex
 }
 
 func (ui *ui) loadTextureIndex() {
@@ -369,6 +403,10 @@ func (ui *ui) loadTextureIndex() {
 }
 
 func (ui *ui) imgFileToTexture(filename string) *sdl.Texture {
+	return imgFileToTexture(ui.renderer, filename)
+}
+
+func imgFileToTexture(renderer *sdl.Renderer, filename string) *sdl.Texture {
 	// Open
 	infile, err := os.Open(filename)
 	if err != nil {
@@ -404,7 +442,7 @@ func (ui *ui) imgFileToTexture(filename string) *sdl.Texture {
 
 	// Make an SDL2 texture out of pixels
 	// AGBR is backwards from way we will be filling in out bytes
-	tex, err := ui.renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STATIC, int32(w), int32(h))
+	tex, err := renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STATIC, int32(w), int32(h))
 	if err != nil {
 		panic(err)
 	}
@@ -461,8 +499,9 @@ func (ui *ui) Draw(level *game.Level) {
 	}
 
 	// Center based on width and height of screen
-	offsetX := int32((ui.winWidth / 2) - ui.centerX*32) // Cast int to int32 since we will always use it as int32
-	offsetY := int32((ui.winHeight / 2) - ui.centerY*32)
+	size := tileSize * ui.tileZoom()
+	offsetX := int32(ui.winWidth/2) - int32(ui.centerX)*size // Cast int to int32 since we will always use it as int32
+	offsetY := int32(ui.winHeight/2) - int32(ui.centerY)*size
 
 	// Clear before drawing tiles
 	ui.renderer.Clear()
@@ -476,7 +515,7 @@ func (ui *ui) Draw(level *game.Level) {
 				srcRects := ui.textureIndex[tile.Rune]
 				srcRect := srcRects[ui.r.Intn(len(srcRects))] // Random number between 1 and length of variations
 				if tile.Visible || tile.Seen {
-					dstRect := sdl.Rect{int32(x*32) + offsetX, int32(y*32) + offsetY, 32, 32}
+					dstRect := sdl.Rect{int32(x)*size + offsetX, int32(y)*size + offsetY, size, size}
 
 					// If debug map contains position we are about to draw, set color
 					pos := game.Pos{x, y}
@@ -515,7 +554,7 @@ func (ui *ui) Draw(level *game.Level) {
 		if level.Map[pos.Y][pos.X].Visible {
 			for _, item := range items {
 				itemSrcRect := ui.textureIndex[item.Rune][0]
-				ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+				ui.renderer.Copy(ui.textureAtlas, &itemSrcRect, &sdl.Rect{int32(pos.X)*size + offsetX, int32(pos.Y)*size + offsetY, size, size})
 			}
 		}
 	}
@@ -524,38 +563,16 @@ func (ui *ui) Draw(level *game.Level) {
 	for pos, monster := range level.Monsters {
 		if level.Map[pos.Y][pos.X].Visible {
 			monsterSrcRect := ui.textureIndex[monster.Rune][0]
-			ui.renderer.Copy(ui.textureAtlas, &monsterSrcRect, &sdl.Rect{int32(pos.X)*32 + offsetX, int32(pos.Y)*32 + offsetY, 32, 32})
+			ui.renderer.Copy(ui.textureAtlas, &monsterSrcRect, &sdl.Rect{int32(pos.X)*size + offsetX, int32(pos.Y)*size + offsetY, size, size})
 		}
 	}
 
 	// Draw player
 	playerSrcRect := ui.textureIndex[level.Player.Rune][0]
-	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{int32(level.Player.X)*32 + offsetX, int32(level.Player.Y)*32 + offsetY, 32, 32})
-
-	// Draw event console background
-	// nil for the source stretches one pixel to our dst
-	textStart := int32(float64(ui.winHeight) * 0.6)
-	textWidth := int32(float64(ui.winWidth) * 0.25)
-	ui.renderer.Copy(ui.eventBackground, nil, &sdl.Rect{0, textStart, textWidth, int32(ui.winHeight) - textStart})
-
-	// Draw events with scroll-up event circular buffer
-	i := level.EventPos // Start at the beginning of our events
-	count := 0
-	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A") // Ask how big the relative font is
-	for {
-		event := level.Events[i] // Draw that event
-		// Check if text has zero width. Not all 10 events will be filled.
-		if event != "" {
-			tex := ui.stringToTexture(event, sdl.Color{255, 0, 0, 0}, FontSmall)
-			_, _, w, h, _ := tex.Query()
-			ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(count*fontSizeY) + textStart, w, h})
-		}
-		i = (i + 1) % (len(level.Events))
-		count++
-		if i == level.EventPos {
-			break // If we get back to eventpos, we are finished
-		}
-	}
+	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{int32(level.Player.X)*size + offsetX, int32(level.Player.Y)*size + offsetY, size, size})
+
+	ui.drawConsole(level)
+	ui.drawStatus(level)
 
 	// Render Inventory UI
 	groundInvStart := int32(float64(ui.winWidth) * 0.9)
@@ -576,6 +593,40 @@ func (ui *ui) getGroundItemRect(i int) *sdl.Rect {
 	return &sdl.Rect{int32(ui.winWidth) - itemSize - int32(i)*itemSize, int32(ui.winHeight) - itemSize, itemSize, itemSize}
 }
 
+// End the player's turn once they run out of stamina or notes
+func (ui *ui) checkPlayerTurn(level *game.Level) {
+	if level.BurstOver() {
+		ui.showBattleResult(level.EndTurn())
+		ui.state = UIMain
+	}
+}
+
+// handleEvents goes to the battle screen if a fight started this turn
+func (ui *ui) handleEvents(level *game.Level) {
+	for _, e := range level.Events {
+		if e.Typ == game.Attack {
+			ui.state = UIBattle
+		}
+	}
+}
+
+// playSounds plays the sounds for everything the bus has heard since the last level came in
+func (ui *ui) playSounds(level *game.Level) {
+	player := &level.Player.Character
+	for _, e := range ui.sounds.events.Take() {
+		switch e.Typ {
+		case game.Move:
+			if e.Who == player {
+				playRandomSound(ui.sounds.footsteps, ui.settings.FootstepVolume)
+			}
+		case game.DoorOpen:
+			if e.Who == player {
+				playRandomSound(ui.sounds.openingDoors, ui.settings.DoorVolume)
+			}
+		}
+	}
+}
+
 func (ui *ui) keyDownOnce(key uint8) bool {
 	return ui.keyboardState[key] == 1 && ui.prevKeyboardState[key] == 0
 }
@@ -613,18 +664,38 @@ func (ui *ui) Run() {
 	for {
 		// Poll for events. Throws an error when not run on the main thread on OSX!
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			ui.pads.handleEvent(event, ui.settings)
 			switch e := event.(type) {
 			case *sdl.QuitEvent:
-				// Instead of returning, put input into channel
-				ui.inputChan <- &game.Input{Typ: game.QuitGame}
+				if err := ui.stopGame(); err != nil { // Let the game save first
+					fmt.Fprintln(os.Stderr, "Couldn't save the run:", err)
+				}
+				return
 			case *sdl.WindowEvent:
-				if e.Event == sdl.WINDOWEVENT_CLOSE {
-					ui.inputChan <- &game.Input{Typ: game.CloseWindow, LevelChannel: ui.levelChan} // Let game close that level channel
+				if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+					ui.resize(int(e.Data1), int(e.Data2))
+				}
+			case *sdl.KeyboardEvent:
+				if e.Type == sdl.KEYDOWN && e.Repeat == 0 && e.Keysym.Scancode == sdl.SCANCODE_F3 {
+					ui.showTextStats = !ui.showTextStats
 				}
 			}
 		}
 
 		ui.currentMouseState = getmouseState()
+		ui.music.loop()
+
+		// The menus and practice mode run on their own, away from the dungeon
+		if ui.inMenus() {
+			if g := ui.updateMenus(); g != nil {
+				newLevel = ui.startGame(g)
+				lastCombo = 0
+			}
+			ui.prevMouseState = ui.currentMouseState
+			ui.pads.endFrame()
+			sdl.Delay(10)
+			continue
+		}
 
 		// TODO(max): suspect quick keypresses sometimes cause channel gridlock
 		// Check if we have a new game state to draw
@@ -634,47 +705,37 @@ func (ui *ui) Run() {
 		// Don't wait on the channel
 		case newLevel, ok = <-ui.levelChan:
 			if ok {
-				// Visibility into game events
-				switch newLevel.LastEvent {
-				case game.Move:
-					// Play footesteps upon walking
-					playRandomSound(ui.sounds.footsteps, 5)
-				case game.OpenDoor:
-					playRandomSound(ui.sounds.openingDoors, 10)
-				case game.Attack:
-					if ui.state == UIBattle {
-						if newLevel.Battle.C1 == &newLevel.Player.Character && newLevel.Battle.C1.Burst.Combo != lastCombo {
-							// Player
+				fighting := ui.state == UIBattle
+				ui.handleEvents(newLevel)
+				ui.playSounds(newLevel)
+				if fighting && newLevel.Fighting() {
+					if newLevel.Battle.C1 == &newLevel.Player.Character {
+						// Player
+						if newLevel.Battle.C1.Burst.Combo != lastCombo {
 							newLevel.ResolveDamage()
 							playHitsound(ui.sounds.hitsound)
-							// Decide what to do when player stamina has reached 0
-							if newLevel.Battle.C1.Stamina <= 0 {
-								newLevel.Battle.C1.Stamina = newLevel.Battle.C1.MaxStamina
-								ui.state = UIMain
-								newLevel.LastEvent = game.Move
-							}
 						}
-						lastCombo = newLevel.Battle.C1.Burst.Combo // Prevent ghost notes from counting
-					} else {
-						ui.state = UIBattle
+						// Misses use stamina without changing the combo
+						ui.checkPlayerTurn(newLevel)
 					}
-				case game.Damage:
-					//fmt.Println("You should not be here. State is game.Damage")
-					// if newLevel.Battle.C1 != nil && newLevel.Battle.C2 != nil {
-					// 	newLevel.ResolveDamage()
-					// 	playHitsound(ui.sounds.hitsound)
-
-					// 	if ui.state == UIBattle && newLevel.Battle.C1.Stamina <= 0 {
-					// 		ui.state = UIMain
-					// 	}
-
-					// }
-				default:
+					lastCombo = newLevel.Battle.C1.Burst.Combo // Prevent ghost notes from counting
 				}
 			}
 		default:
 		}
 
+		if newLevel.Player.Hitpoints <= 0 {
+			ui.endRun()
+			continue
+		}
+
+		// Notes that scroll past are missed even if nothing is pressed
+		if ui.state == UIBattle && newLevel.Battle.C1 == &newLevel.Player.Character {
+			if newLevel.CheckMisses(&newLevel.Player.Character, newLevel.SongPosition()) > 0 {
+				ui.checkPlayerTurn(newLevel)
+			}
+		}
+
 		ui.Draw(newLevel)
 		var input game.Input
 		if ui.state == UIInventory {
@@ -709,7 +770,15 @@ func (ui *ui) Run() {
 				// Clear opponent's battle UI
 				ui.state = UIMain
 			}
+		} else if ui.state == UICalibration {
+			ui.DrawCalibration()
+		} else if ui.state == UIMessageLog {
+			ui.DrawMessageLog(newLevel)
 		}
+		if ui.state == UIMain && ui.battleResult != nil {
+			ui.DrawBattleResult()
+		}
+		ui.drawTextStats()
 		// TODO(max): calling present twice will cause flickering
 		ui.renderer.Present()
 
@@ -726,11 +795,10 @@ func (ui *ui) Run() {
 				playHitsound(ui.sounds.hitsound)
 				//fmt.Println(m.Typ, ui.state == UIBattle, newLevel.Battle.C1.Stamina)
 				m.Typ = game.NoInput
-				if newLevel.Battle.C1.Stamina <= 0 {
-					newLevel.Battle.C1.Stamina = newLevel.Battle.C1.MaxStamina
+				if newLevel.BurstOver() {
+					ui.showBattleResult(newLevel.EndTurn())
 					ui.state = UIMain
 					// TODO(max): 2nd rat doesn't die
-					newLevel.LastEvent = game.NoEvent
 				}
 			}
 		}
@@ -738,22 +806,67 @@ func (ui *ui) Run() {
 		// Handle keypresses if window is in focus
 		// Or else will crash because we are trying to send x3 input to all 3 windows at the same time
 		if sdl.GetKeyboardFocus() == ui.window && sdl.GetMouseFocus() == ui.window {
-			if ui.keyDownOnce(sdl.SCANCODE_UP) {
+			// Notes get their own bindings while the player is attacking
+			attacking := ui.state == UIBattle && newLevel.Battle.C1 == &newLevel.Player.Character
+			if !attacking {
+				ui.pendingNotes = ui.pendingNotes[:0]
+			}
+			if attacking {
+				ui.queueNoteInputs(burstKeys(newLevel.Player.Burst), newLevel.SongPosition())
+				if input.Typ == game.None && len(ui.pendingNotes) > 0 {
+					input = ui.pendingNotes[0]
+					ui.pendingNotes = ui.pendingNotes[1:]
+				}
+			} else if ui.state == UICalibration {
+				if calibrated := ui.updateCalibration(); calibrated != nil {
+					input = *calibrated
+				}
+			} else if ui.state == UIMessageLog {
+				ui.updateMessageLog(newLevel.Log)
+			} else if ui.actionDown(actionUp) {
 				input.Typ = game.Up
-			} else if ui.keyDownOnce(sdl.SCANCODE_DOWN) {
+			} else if ui.actionDown(actionDown) {
 				input.Typ = game.Down
-			} else if ui.keyDownOnce(sdl.SCANCODE_LEFT) {
+			} else if ui.actionDown(actionLeft) {
 				input.Typ = game.Left
-			} else if ui.keyDownOnce(sdl.SCANCODE_RIGHT) {
+			} else if ui.actionDown(actionRight) {
 				input.Typ = game.Right
-			} else if ui.keyDownOnce(sdl.SCANCODE_T) {
+			} else if ui.actionDown(actionUpLeft) {
+				input.Typ = game.UpLeft
+			} else if ui.actionDown(actionUpRight) {
+				input.Typ = game.UpRight
+			} else if ui.actionDown(actionDownLeft) {
+				input.Typ = game.DownLeft
+			} else if ui.actionDown(actionDownRight) {
+				input.Typ = game.DownRight
+			} else if ui.actionDown(actionTakeAll) {
 				input.Typ = game.TakeAll
-			} else if ui.keyDownOnce(sdl.SCANCODE_I) {
+			} else if ui.actionDown(actionInventory) {
 				if ui.state == UIMain {
 					ui.state = UIInventory
 				} else if ui.state == UIInventory {
 					ui.state = UIMain
 				}
+			} else if ui.actionDown(actionMessageLog) && ui.state == UIMain {
+				ui.openMessageLog()
+			} else if ui.actionDown(actionCalibrate) && ui.state == UIMain {
+				ui.startCalibration()
+			} else if ui.actionDown(actionSlower) && ui.state == UIMain && newLevel.Settings != nil {
+				input.Typ = game.UpdateSettings // Slower notes
+				input.Settings = changeScrollSpeed(newLevel.Settings, -1)
+			} else if ui.actionDown(actionFaster) && ui.state == UIMain && newLevel.Settings != nil {
+				input.Typ = game.UpdateSettings // Faster notes
+				input.Settings = changeScrollSpeed(newLevel.Settings, 1)
+			} else if ui.actionDown(actionZoomIn) && ui.state == UIMain {
+				ui.zoom = changeZoom(ui.zoom, ui.tileZoom(), 1)
+			} else if ui.actionDown(actionZoomOut) && ui.state == UIMain {
+				ui.zoom = changeZoom(ui.zoom, ui.tileZoom(), -1)
+			} else if ui.actionDown(actionMenu) && ui.state == UIMain {
+				err := ui.stopGame() // Saves the run so it can be continued
+				ui.state = UITitle
+				if err != nil {
+					ui.reportError("Couldn't save the run", err)
+				}
 			} else if ui.keyDownOnce(sdl.SCANCODE_P) {
 				//fmt.Println(newLevel.Player.Pos)
 			}
@@ -763,11 +876,15 @@ func (ui *ui) Run() {
 				ui.prevKeyboardState[i] = v
 			}
 
-			if input.Typ != game.None {
+			if input.Typ != game.None && ui.game != nil {
+				if input.Time == 0 {
+					input.Time = newLevel.SongPosition() // Notes are judged against the music
+				}
 				ui.inputChan <- &input
 			}
 		}
 		ui.prevMouseState = ui.currentMouseState
+		ui.pads.endFrame()
 		sdl.Delay(10) // Don't eat cpu waiting for inputs
 	}
 }
@@ -2,9 +2,11 @@ package ui2d
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
 
 	"github.com/maxproske/lyns-rhythm-dungeon/game"
+	"github.com/veandco/go-sdl2/sdl"
 )
 
 func TestInventoryRect(t *testing.T) {
@@ -127,7 +129,7 @@ func TestBattleState(t *testing.T) {
 					Name: "Player",
 				},
 				Burst: &game.Burst{
-					Notes:    []int{1, 2, 3},
+					Notes:    []game.Note{{Column: 1}, {Column: 2}, {Column: 3}},
 					MaxCombo: 3,
 					Combo:    0,
 				},
@@ -137,8 +139,8 @@ func TestBattleState(t *testing.T) {
 					Name: "Monster",
 				},
 			},
+			Active: true,
 		},
-		LastEvent: game.Attack,
 	}
 
 	// Test battle state transition
@@ -320,7 +322,7 @@ func TestBattleStateTransitions(t *testing.T) {
 					Name: "Player",
 				},
 				Burst: &game.Burst{
-					Notes:    []int{1, 2, 3},
+					Notes:    []game.Note{{Column: 1}, {Column: 2}, {Column: 3}},
 					MaxCombo: 3,
 				},
 				Stamina:    3,
@@ -332,8 +334,8 @@ func TestBattleStateTransitions(t *testing.T) {
 				},
 				Hitpoints: 3,
 			},
+			Active: true,
 		},
-		LastEvent: game.Attack,
 	}
 
 	// Test battle initiation
@@ -419,7 +421,7 @@ func TestBattleUIEdgeCases(t *testing.T) {
 			C1: &game.Character{
 				Entity: game.Entity{Name: "Player"},
 				Burst: &game.Burst{
-					Notes:    []int{},
+					Notes:    []game.Note{},
 					MaxCombo: 0,
 					Combo:    0,
 				},
@@ -439,7 +441,7 @@ func TestBattleUIEdgeCases(t *testing.T) {
 	}
 
 	// Test extremely long burst patterns
-	level.Battle.C1.Burst.Notes = make([]int, 100)
+	level.Battle.C1.Burst.Notes = make([]game.Note, 100)
 	level.Battle.C1.Burst.MaxCombo = 100
 	ui.state = UIBattle
 
@@ -628,3 +630,415 @@ func TestGetRuneFromNoteskinIndex(t *testing.T) {
 		}
 	}
 }
+
+func TestKeyModeLayouts(t *testing.T) {
+	bindings := defaultKeyBindings()
+	for _, keys := range game.KeyModes {
+		if len(bindings[keys]) != keys {
+			t.Errorf("%dK: expected bindings for %d columns, got %d", keys, keys, len(bindings[keys]))
+		}
+		// A key can only be bound to one column
+		seen := make(map[uint8]bool)
+		for col, scancodes := range bindings[keys] {
+			for _, scancode := range scancodes {
+				if seen[scancode] {
+					t.Errorf("%dK: scancode %d bound twice (column %d)", keys, scancode, col)
+				}
+				seen[scancode] = true
+			}
+		}
+		layout := getNoteskinLayout(keys)
+		if len(layout.colors) != keys {
+			t.Errorf("%dK: expected %d column colours, got %d", keys, keys, len(layout.colors))
+		}
+		for _, index := range layout.colors {
+			getRuneFromNoteskinIndex(index) // Panics if there's no sprite
+		}
+	}
+	if burstKeys(&game.Burst{Keys: 5}) != game.NumKeys {
+		t.Error("Expected an unknown key mode to fall back to the default")
+	}
+}
+
+func TestScrollSpeed(t *testing.T) {
+	b := &game.Burst{Start: 1000, BPM: 120}
+	// A beat away at 1x is 40px, and twice as far at 2x
+	if y := noteY(b, 1000, 500, 120); y != 40 {
+		t.Errorf("Expected a beat at 1x to be 40px, got %d", y)
+	}
+	if y := noteY(b, 1000, 500, 240); y != 80 {
+		t.Errorf("Expected a beat at 2x to be 80px, got %d", y)
+	}
+	// Notes that have gone past are above the receptors
+	if y := noteY(b, 2000, 500, 120); y != -40 {
+		t.Errorf("Expected a late note to be -40px, got %d", y)
+	}
+
+	settings := &game.Settings{}
+	for _, expected := range []string{"1.5x", "2x", "2.5x"} {
+		settings = changeScrollSpeed(settings, 1)
+		if name := speedModName(settings); name != expected {
+			t.Errorf("Expected %s, got %s", expected, name)
+		}
+	}
+	if settings = changeScrollSpeed(settings, -10); settings.ScrollSpeed != game.MinScrollSpeed {
+		t.Errorf("Expected speed to stop at %vx, got %v", game.MinScrollSpeed, settings.ScrollSpeed)
+	}
+	if name := speedModName(&game.Settings{ScrollSpeed: 3, ConstantBPM: 400}); name != "C400" {
+		t.Errorf("Expected C400, got %s", name)
+	}
+	if settings = changeScrollSpeed(&game.Settings{ConstantBPM: 400, GlobalOffset: 20}, 1); settings.ConstantBPM != 0 || settings.GlobalOffset != 20 {
+		t.Errorf("Expected stepping the speed to turn off the CMod and keep the offset, got %+v", settings)
+	}
+}
+
+func TestChangePracticeOption(t *testing.T) {
+	options := game.PracticeOptions{Keys: 8, ScrollSpeed: game.MaxScrollSpeed}
+	options = changePracticeOption(options, practiceKeys, 1)
+	if options.Keys != game.KeyModes[0] {
+		t.Errorf("Expected keys to wrap around to %d, got %d", game.KeyModes[0], options.Keys)
+	}
+	options = changePracticeOption(options, practiceSpeed, 1)
+	if options.ScrollSpeed != game.MinScrollSpeed {
+		t.Errorf("Expected speed to wrap around to %v, got %v", game.MinScrollSpeed, options.ScrollSpeed)
+	}
+	options = changePracticeOption(options, practiceMonster, -1)
+	if options.Monster != len(game.PracticeMonsters)-1 {
+		t.Errorf("Expected monster to wrap around to the last one, got %d", options.Monster)
+	}
+	options = changePracticeOption(options, practiceDifficulty, -1)
+	if options.Difficulty != game.MaxPracticeDifficulty {
+		t.Errorf("Expected difficulty to wrap around to %d, got %d", game.MaxPracticeDifficulty, options.Difficulty)
+	}
+}
+
+func TestEditSeed(t *testing.T) {
+	seed := ""
+	for _, key := range []uint8{sdl.SCANCODE_4, sdl.SCANCODE_0, sdl.SCANCODE_A, sdl.SCANCODE_9, sdl.SCANCODE_BACKSPACE, sdl.SCANCODE_2} {
+		seed = editSeed(seed, key)
+	}
+	if seed != "402" {
+		t.Errorf("Expected 402, got %q", seed)
+	}
+	if seed = editSeed("123456789", sdl.SCANCODE_1); seed != "123456789" {
+		t.Errorf("Expected the seed to stop at %d digits, got %q", maxSeedDigits, seed)
+	}
+	if seed = editSeed("", sdl.SCANCODE_BACKSPACE); seed != "" {
+		t.Errorf("Expected nothing to rub out, got %q", seed)
+	}
+}
+
+func TestChangeOption(t *testing.T) {
+	settings := &game.Settings{GlobalOffset: 10, ScrollSpeed: 2}
+	if changed := changeOption(settings, optionAudioOffset, -1); changed.GlobalOffset != 10-offsetStep || changed.ScrollSpeed != 2 {
+		t.Errorf("Expected only the audio offset to go down, got %+v", changed)
+	}
+	if changed := changeOption(settings, optionInputOffset, 1); changed.InputOffset != offsetStep {
+		t.Errorf("Expected the input offset to go up, got %+v", changed)
+	}
+	if changed := changeOption(settings, optionScrollSpeed, 1); changed.ScrollSpeed != 2+game.ScrollSpeedStep {
+		t.Errorf("Expected the speed to go up, got %+v", changed)
+	}
+	if settings.GlobalOffset != 10 || settings.ScrollSpeed != 2 {
+		t.Error("Expected the old settings to be left alone")
+	}
+}
+
+func TestChangeDisplayAndVolume(t *testing.T) {
+	settings := game.DefaultSettings()
+	if changed := changeOption(settings, optionResolution, -1); changed.Width != game.Resolutions[len(game.Resolutions)-1].Width {
+		t.Errorf("Expected the resolution to wrap around to the biggest one, got %dx%d", changed.Width, changed.Height)
+	}
+	if changed := changeOption(settings, optionFullscreen, -1); !changed.Fullscreen {
+		t.Error("Expected fullscreen to toggle either way")
+	}
+	if changed := changeOption(settings, optionMusicVolume, 1); changed.MusicVolume != game.MaxVolume {
+		t.Errorf("Expected the music to stop at %d, got %d", game.MaxVolume, changed.MusicVolume)
+	}
+	if changed := changeOption(settings, optionFootstepVolume, -1); changed.FootstepVolume != 0 {
+		t.Errorf("Expected footsteps to go quiet, got %d", changed.FootstepVolume)
+	}
+}
+
+func TestBindKey(t *testing.T) {
+	settings := game.DefaultSettings()
+	bindings := keyBindingsFrom(settings.KeyBindings)
+
+	// D moves from the left column to the right one
+	changed := setColumnKeys(settings, 4, addKey(bindings[4], 3, sdl.SCANCODE_D))
+	if settings.KeyBindings != nil {
+		t.Error("Expected the old settings to be left alone")
+	}
+	bindings = keyBindingsFrom(changed.KeyBindings)
+	if fmt.Sprint(bindings[4][3]) != fmt.Sprint([]uint8{sdl.SCANCODE_RIGHT, sdl.SCANCODE_K, sdl.SCANCODE_D}) {
+		t.Errorf("Expected D on the right too, got %s", keyNames(bindings[4][3]))
+	}
+	if fmt.Sprint(bindings[4][0]) != fmt.Sprint([]uint8{sdl.SCANCODE_LEFT}) {
+		t.Errorf("Expected D to leave the left column, got %s", keyNames(bindings[4][0]))
+	}
+	if fmt.Sprint(bindings[6]) != fmt.Sprint(defaultKeyBindings()[6]) {
+		t.Error("Expected other key modes to keep their keys")
+	}
+
+	changed = setColumnKeys(changed, 4, clearKeys(bindings[4], 1))
+	if len(keyBindingsFrom(changed.KeyBindings)[4][1]) != 0 {
+		t.Error("Expected the column to be cleared")
+	}
+	changed = resetKeys(changed, 4)
+	if fmt.Sprint(keyBindingsFrom(changed.KeyBindings)[4]) != fmt.Sprint(defaultKeyBindings()[4]) {
+		t.Error("Expected reset to bring the default keys back")
+	}
+}
+
+func TestActionMap(t *testing.T) {
+	actions := defaultActionMap()
+	if len(actions) != int(numActions) {
+		t.Fatalf("Expected every action to have default keys, got %d of %d", len(actions), numActions)
+	}
+
+	// Exploring and battles are separate, so D can walk right and still be a column
+	if keyNames(actions[actionRight]) != "Right / D" {
+		t.Errorf("Expected arrows and WASD, got %s", keyNames(actions[actionRight]))
+	}
+
+	// Put I on walking up as well, and take it off the inventory
+	settings := setActionKeys(game.DefaultSettings(), addKey(actions, int(actionUp), sdl.SCANCODE_I))
+	if fmt.Sprint(settings.Actions["up"]) != "[Up W I]" {
+		t.Errorf("Expected actions saved by name, got %v", settings.Actions)
+	}
+	actions = actionMapFrom(settings.Actions)
+	if len(actions[actionInventory]) != 0 || len(actions[actionUp]) != 3 {
+		t.Errorf("Expected I to move to walking up, got %s and %s", keyNames(actions[actionUp]), keyNames(actions[actionInventory]))
+	}
+
+	// Older settings files only have some actions
+	actions = actionMapFrom(map[string][]string{"take_all": {"G"}})
+	if keyNames(actions[actionTakeAll]) != "G" || keyNames(actions[actionUp]) != "Up / W" {
+		t.Errorf("Expected missing actions to keep their defaults, got %v", actions)
+	}
+	if resetActions(settings).Actions != nil {
+		t.Error("Expected reset to forget every action")
+	}
+}
+
+func TestDancePadEvents(t *testing.T) {
+	settings := game.DefaultSettings()
+	pads := newPads()
+	pads.add(&pad{id: 3, guid: "dancepad", name: "Dance pad"}, settings)
+	ui := &ui{
+		pads:              pads,
+		actions:           defaultActionMap(),
+		keyBindings:       defaultKeyBindings(),
+		keyboardState:     make([]uint8, sdl.NUM_SCANCODES),
+		prevKeyboardState: make([]uint8, sdl.NUM_SCANCODES),
+	}
+
+	// The up panel walks up and hits the third column
+	pads.handleEvent(&sdl.JoyButtonEvent{Type: sdl.JOYBUTTONDOWN, Which: 3, Button: 2, State: sdl.PRESSED}, settings)
+	if !ui.actionDown(actionUp) || ui.actionDown(actionDown) {
+		t.Error("Expected the up panel to walk up")
+	}
+	ui.queueNoteInputs(4, 100)
+	if len(ui.pendingNotes) != 1 || ui.pendingNotes[0].Typ != game.NotePressed || ui.pendingNotes[0].Column != 2 {
+		t.Errorf("Expected a press on column 2, got %+v", ui.pendingNotes)
+	}
+
+	// Holding it down doesn't press it again
+	pads.endFrame()
+	ui.pendingNotes = ui.pendingNotes[:0]
+	pads.handleEvent(&sdl.JoyButtonEvent{Type: sdl.JOYBUTTONDOWN, Which: 3, Button: 2, State: sdl.PRESSED}, settings)
+	if ui.actionDown(actionUp) {
+		t.Error("Expected a held panel to only count once")
+	}
+	pads.handleEvent(&sdl.JoyButtonEvent{Type: sdl.JOYBUTTONUP, Which: 3, Button: 2, State: sdl.RELEASED}, settings)
+	ui.queueNoteInputs(4, 200)
+	if len(ui.pendingNotes) != 1 || ui.pendingNotes[0].Typ != game.NoteReleased {
+		t.Errorf("Expected a release, got %+v", ui.pendingNotes)
+	}
+
+	// Hats press two directions at once, and sticks need pushing past the threshold
+	pads.endFrame()
+	pads.handleEvent(&sdl.JoyHatEvent{Type: sdl.JOYHATMOTION, Which: 3, Hat: 0, Value: sdl.HAT_LEFTUP}, settings)
+	pads.handleEvent(&sdl.JoyAxisEvent{Type: sdl.JOYAXISMOTION, Which: 3, Axis: 1, Value: axisThreshold / 2}, settings)
+	pads.handleEvent(&sdl.JoyAxisEvent{Type: sdl.JOYAXISMOTION, Which: 3, Axis: 0, Value: -32768}, settings)
+	if fmt.Sprint(pads.byID[3].pressed) != "[hat0up hat0left axis0-]" {
+		t.Errorf("Expected the hat and one axis, got %v", pads.byID[3].pressed)
+	}
+	if pads.last != pads.byID[3] {
+		t.Error("Expected the pad to be the last one used")
+	}
+
+	// Events from pads that aren't plugged in are ignored, and unplugging forgets the pad
+	pads.handleEvent(&sdl.JoyButtonEvent{Type: sdl.JOYBUTTONDOWN, Which: 9, Button: 0, State: sdl.PRESSED}, settings)
+	pads.handleEvent(&sdl.JoyDeviceRemovedEvent{Type: sdl.JOYDEVICEREMOVED, Which: 3}, settings)
+	if len(pads.byID) != 0 || pads.last != nil {
+		t.Error("Expected the pad to be unplugged")
+	}
+}
+
+func TestPadBindingsPerDevice(t *testing.T) {
+	settings := game.DefaultSettings()
+	bindings := defaultPadBindings(false)
+	bindings.columns[4] = addKey(bindings.columns[4], 0, "button5")
+	settings = setPadBindings(settings, "left", bindings.save())
+
+	// Changing the live bindings afterwards doesn't reach into the saved ones
+	bindings.columns[4] = addKey(bindings.columns[4], 1, "button6")
+	bindings.columns[6] = nil
+	if saved := settings.Pads["left"].Columns; len(saved[6]) == 0 || fmt.Sprint(saved[4][1]) != "[button1]" {
+		t.Errorf("Expected the saved bindings to be a copy, got %v", saved)
+	}
+
+	pads := newPads()
+	pads.add(&pad{id: 1, guid: "left"}, settings)
+	pads.add(&pad{id: 2, guid: "right"}, settings)
+	pads.add(&pad{id: 3, guid: "gamepad", gamepad: true}, settings)
+	if fmt.Sprint(pads.byID[1].bindings.columns[4][0]) != "[button0 button5]" {
+		t.Errorf("Expected the changed pad to have its own buttons, got %v", pads.byID[1].bindings.columns[4])
+	}
+	if fmt.Sprint(pads.byID[2].bindings.columns[4][0]) != "[button0]" {
+		t.Errorf("Expected other pads to keep the defaults, got %v", pads.byID[2].bindings.columns[4])
+	}
+	if fmt.Sprint(pads.byID[3].bindings.actions[actionUp]) != "[dpup lefty-]" {
+		t.Errorf("Expected gamepads to walk with the dpad and stick, got %v", pads.byID[3].bindings.actions[actionUp])
+	}
+
+	// Gamepads are read by their gamepad names, not as a plain joystick
+	pads.handleEvent(&sdl.JoyButtonEvent{Type: sdl.JOYBUTTONDOWN, Which: 3, Button: 0, State: sdl.PRESSED}, settings)
+	if len(pads.byID[3].pressed) != 0 {
+		t.Errorf("Expected joystick events from a gamepad to be ignored, got %v", pads.byID[3].pressed)
+	}
+	pads.handleEvent(&sdl.ControllerAxisEvent{Type: sdl.CONTROLLERAXISMOTION, Which: 3, Axis: sdl.CONTROLLER_AXIS_LEFTY, Value: -20000}, settings)
+	if !pads.actionDown(actionUp) {
+		t.Error("Expected pushing the stick up to walk up")
+	}
+
+	settings = setPadBindings(settings, "left", nil)
+	pads.rebind(settings)
+	if fmt.Sprint(pads.byID[1].bindings.columns[4][0]) != "[button0]" {
+		t.Error("Expected forgetting a pad to bring its defaults back")
+	}
+}
+
+func TestLayoutScale(t *testing.T) {
+	tests := []struct {
+		w, h int
+		want int32
+	}{
+		{780, 480, 1},
+		{500, 300, 1}, // Never smaller than the base layout
+		{1600, 1000, 2},
+		{1920, 480, 1}, // The shorter side decides
+		{3200, 2000, 4},
+	}
+	for _, tt := range tests {
+		if got := layoutScale(tt.w, tt.h); got != tt.want {
+			t.Errorf("layoutScale(%d, %d) = %d, want %d", tt.w, tt.h, got, tt.want)
+		}
+	}
+
+	small, medium, large := fontSizes(1560, 960)
+	if small != 31 || medium != 64 || large != 128 {
+		t.Errorf("Expected fonts to grow with the window, got %d %d %d", small, medium, large)
+	}
+}
+
+func TestChangeZoom(t *testing.T) {
+	if got := changeZoom(0, 2, 1); got != 3 {
+		t.Errorf("Expected zooming in from the window's zoom to give 3, got %d", got)
+	}
+	if got := changeZoom(1, 1, -1); got != 1 {
+		t.Errorf("Expected zoom to stop at 1, got %d", got)
+	}
+	if got := changeZoom(maxZoom, 1, 1); got != maxZoom {
+		t.Errorf("Expected zoom to stop at %d, got %d", maxZoom, got)
+	}
+}
+
+func TestTextCacheEviction(t *testing.T) {
+	var destroyed []*sdl.Texture
+	cache := newTextCache(2, func(tex *sdl.Texture) { destroyed = append(destroyed, tex) })
+	a, b, c := new(sdl.Texture), new(sdl.Texture), new(sdl.Texture)
+	white := sdl.Color{255, 255, 255, 0}
+
+	cache.put(textKey{"a", FontSmall, white}, a)
+	cache.put(textKey{"b", FontSmall, white}, b)
+	if _, ok := cache.get(textKey{"a", FontSmall, white}); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	if _, ok := cache.get(textKey{"a", FontMedium, white}); ok {
+		t.Error("Expected a different size to be a different entry")
+	}
+	if _, ok := cache.get(textKey{"a", FontSmall, selectedColor}); ok {
+		t.Error("Expected a different color to be a different entry")
+	}
+
+	// b is the least recently used now
+	cache.put(textKey{"c", FontSmall, white}, c)
+	if _, ok := cache.get(textKey{"b", FontSmall, white}); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if len(destroyed) != 1 || destroyed[0] != b {
+		t.Errorf("Expected only b to be destroyed, got %v", destroyed)
+	}
+
+	stats := cache.Stats()
+	want := textCacheStats{Entries: 2, Capacity: 2, Hits: 1, Misses: 3, Evictions: 1}
+	if stats != want {
+		t.Errorf("Expected %v, got %v", want, stats)
+	}
+
+	cache.clear()
+	if cache.Stats().Entries != 0 || len(destroyed) != 3 {
+		t.Errorf("Expected clearing to destroy everything, got %v", cache.Stats())
+	}
+}
+
+func TestMessageLogScrolling(t *testing.T) {
+	messages := make([]game.Message, 10)
+	for i := range messages {
+		messages[i].Text = strconv.Itoa(i)
+	}
+	if got := visibleMessages(messages, 0, 4); got[0].Text != "6" || got[3].Text != "9" {
+		t.Errorf("Expected the newest page at the bottom, got %v", got)
+	}
+	if got := visibleMessages(messages, 2, 4); got[0].Text != "4" || got[3].Text != "7" {
+		t.Errorf("Expected scrolling up to show older messages, got %v", got)
+	}
+	if got := clampScroll(100, 10, 4); got != 6 {
+		t.Errorf("Expected scrolling to stop at the oldest page, got %d", got)
+	}
+	if got := clampScroll(-1, 10, 4); got != 0 {
+		t.Errorf("Expected scrolling to stop at the newest message, got %d", got)
+	}
+	if got := visibleMessages(messages[:2], 5, 4); len(got) != 2 {
+		t.Errorf("Expected short logs to fit on one page, got %v", got)
+	}
+}
+
+func TestClassSummary(t *testing.T) {
+	plain := classSummary(&game.Class{Description: "Plain.", Hitpoints: 20, Stamina: 2, SightRange: 7})
+	if len(plain) != 2 || plain[1] != "20 hitpoints, 2 stamina, 7 sight" {
+		t.Errorf("Expected a description and stats, got %v", plain)
+	}
+	duelist := classSummary(&game.Class{Passive: game.Passive{Name: "Riposte", JudgeScale: 0.75, BonusDamage: 1}})
+	if len(duelist) != 3 || duelist[2] != "Riposte: 75% judgement windows, +1 damage" {
+		t.Errorf("Expected the passive spelled out, got %v", duelist)
+	}
+}
+
+func TestTitleEntries(t *testing.T) {
+	tests := []struct {
+		saved, broken bool
+		want          []int
+	}{
+		{false, false, []int{titleNewGame, titlePractice, titleOptions, titleQuit}},
+		{true, false, []int{titleNewGame, titleContinue, titlePractice, titleOptions, titleQuit}},
+		{true, true, []int{titleNewGame, titleDeleteSave, titlePractice, titleOptions, titleQuit}},
+	}
+	for _, tt := range tests {
+		if got := titleEntries(tt.saved, tt.broken); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Saved %v broken %v: expected %v, got %v", tt.saved, tt.broken, tt.want, got)
+		}
+	}
+}